@@ -0,0 +1,290 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/emulator"
+	"github.com/swensone/gorito/screen"
+)
+
+type status struct {
+	Mode   string `json:"mode"`
+	Paused bool   `json:"paused"`
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	var st status
+	if s.do(w, func() error {
+		mode := s.emu.Mode()
+		st = status{Mode: mode.String(), Paused: s.emu.Paused()}
+		return nil
+	}) {
+		writeJSON(w, st)
+	}
+}
+
+func (s *Server) pause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.do(w, func() error {
+			s.emu.SetPaused(paused)
+			return nil
+		}) {
+			ok(w)
+		}
+	}
+}
+
+func (s *Server) reset(w http.ResponseWriter, r *http.Request) {
+	if s.do(w, s.emu.Restart) {
+		ok(w)
+	}
+}
+
+func (s *Server) load(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "api.ch8"
+	}
+	if s.do(w, func() error {
+		if err := s.emu.Replace(name, data); err != nil {
+			return errors.Mark(err, errBadRequest)
+		}
+		return nil
+	}) {
+		ok(w)
+	}
+}
+
+func (s *Server) quit(w http.ResponseWriter, r *http.Request) {
+	s.input.send(emulator.Event{Type: emulator.EVENT_QUIT})
+	ok(w)
+}
+
+func (s *Server) key(down bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		k, err := strconv.ParseUint(r.PathValue("key"), 16, 4)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.Newf("invalid key %s, keys are 0-f", r.PathValue("key")))
+			return
+		}
+		s.input.SetKey(uint8(k), down)
+		ok(w)
+	}
+}
+
+type registers struct {
+	V     [16]uint8 `json:"v"`
+	I     uint32    `json:"i"`
+	PC    uint16    `json:"pc"`
+	SP    uint8     `json:"sp"`
+	Stack []uint16  `json:"stack"`
+	Delay uint8     `json:"delay"`
+	Sound uint8     `json:"sound"`
+}
+
+// registerUpdate holds the registers to change, V is keyed by register number
+type registerUpdate struct {
+	V     map[int]uint8 `json:"v"`
+	I     *uint32       `json:"i"`
+	PC    *uint16       `json:"pc"`
+	SP    *uint8        `json:"sp"`
+	Delay *uint8        `json:"delay"`
+	Sound *uint8        `json:"sound"`
+}
+
+func (s *Server) registers(w http.ResponseWriter, r *http.Request) {
+	var regs registers
+	if s.do(w, func() error {
+		regs = registers{
+			V:     s.emu.Registers(),
+			I:     s.emu.Index(),
+			PC:    s.emu.PC(),
+			SP:    s.emu.SP(),
+			Stack: s.emu.Stack(),
+		}
+		regs.Delay, regs.Sound = s.emu.Timers()
+		return nil
+	}) {
+		writeJSON(w, regs)
+	}
+}
+
+func (s *Server) setRegisters(w http.ResponseWriter, r *http.Request) {
+	var update registerUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	for x := range update.V {
+		if x < 0 || x > 15 {
+			writeError(w, http.StatusBadRequest, errors.Newf("invalid register v%d", x))
+			return
+		}
+	}
+
+	if s.do(w, func() error {
+		if update.SP != nil {
+			if err := s.emu.SetSP(*update.SP); err != nil {
+				return errors.Mark(err, errBadRequest)
+			}
+		}
+		for x, val := range update.V {
+			s.emu.SetRegister(uint8(x), val)
+		}
+		if update.I != nil {
+			s.emu.SetIndex(*update.I)
+		}
+		if update.PC != nil {
+			s.emu.SetPC(*update.PC)
+		}
+		delay, sound := s.emu.Timers()
+		if update.Delay != nil {
+			delay = *update.Delay
+		}
+		if update.Sound != nil {
+			sound = *update.Sound
+		}
+		s.emu.SetTimers(delay, sound)
+		return nil
+	}) {
+		ok(w)
+	}
+}
+
+type memory struct {
+	Addr uint32 `json:"addr"`
+	Data string `json:"data"`
+}
+
+// inMemory checks a range fits in the platform's memory, it must be called from the emulator's goroutine
+func (s *Server) inMemory(addr uint32, length int) error {
+	if size := s.emu.Platform().MemorySize; uint64(addr)+uint64(length) > uint64(size) {
+		return errors.Mark(errors.Newf("%d bytes at %X is past the end of %d bytes of memory", length, addr, size), errBadRequest)
+	}
+	return nil
+}
+
+func (s *Server) memory(w http.ResponseWriter, r *http.Request) {
+	addr, err := strconv.ParseUint(r.URL.Query().Get("addr"), 0, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid addr"))
+		return
+	}
+	length, err := strconv.ParseUint(r.URL.Query().Get("length"), 0, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid length"))
+		return
+	}
+
+	var data []byte
+	if s.do(w, func() error {
+		if err := s.inMemory(uint32(addr), int(length)); err != nil {
+			return err
+		}
+		data = s.emu.ReadMemory(uint32(addr), int(length))
+		return nil
+	}) {
+		writeJSON(w, memory{Addr: uint32(addr), Data: hex.EncodeToString(data)})
+	}
+}
+
+func (s *Server) setMemory(w http.ResponseWriter, r *http.Request) {
+	var m memory
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	data, err := hex.DecodeString(m.Data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid data"))
+		return
+	}
+
+	if s.do(w, func() error {
+		if err := s.inMemory(m.Addr, len(data)); err != nil {
+			return err
+		}
+		s.emu.WriteMemory(m.Addr, data)
+		return nil
+	}) {
+		ok(w)
+	}
+}
+
+func (s *Server) screenshot(w http.ResponseWriter, r *http.Request) {
+	scale := uint64(1)
+	if q := r.URL.Query().Get("scale"); q != "" {
+		var err error
+		if scale, err = strconv.ParseUint(q, 10, 8); err != nil || scale == 0 {
+			writeError(w, http.StatusBadRequest, errors.New("invalid scale"))
+			return
+		}
+	}
+
+	var img *image.RGBA
+	if !s.do(w, func() error {
+		gfx, xres, yres := s.emu.Screen()
+		img = screen.Image(gfx, xres, yres, int(scale))
+		return nil
+	}) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, img)
+}
+
+type screenshotFile struct {
+	File string `json:"file"`
+}
+
+func (s *Server) saveScreenshot(w http.ResponseWriter, r *http.Request) {
+	var file string
+	if s.do(w, func() error {
+		var err error
+		file, err = s.emu.Screenshot()
+		return err
+	}) {
+		writeJSON(w, screenshotFile{File: file})
+	}
+}
+
+func (s *Server) state(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if s.do(w, func() error {
+		return s.emu.SaveState(&buf)
+	}) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(buf.Bytes())
+	}
+}
+
+func (s *Server) setState(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if s.do(w, func() error {
+		if err := s.emu.LoadState(bytes.NewReader(data)); err != nil {
+			return errors.Mark(err, errBadRequest)
+		}
+		return nil
+	}) {
+		ok(w)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/swensone/gorito/emulator"
+)
+
+// Input adds the keys held and the events sent through the api to another input. It's given to the emulator in place
+// of that input.
+type Input struct {
+	inner emulator.Input
+
+	mu     sync.Mutex
+	keys   [16]bool
+	events []emulator.Event
+}
+
+// NewInput wraps inner, which may be nil when there's no other input
+func NewInput(inner emulator.Input) *Input {
+	return &Input{inner: inner}
+}
+
+// Poll implements emulator.Input, a key is down if it's down in either input
+func (in *Input) Poll() emulator.InputState {
+	var state emulator.InputState
+	if in.inner != nil {
+		state = in.inner.Poll()
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for k, down := range in.keys {
+		state.Keys[k] = state.Keys[k] || down
+	}
+	state.Events = append(state.Events, in.events...)
+	in.events = nil
+	return state
+}
+
+// SetKey holds a key down or releases it
+func (in *Input) SetKey(k uint8, down bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.keys[k&0x0F] = down
+}
+
+// send delivers an event on the next poll
+func (in *Input) send(ev emulator.Event) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.events = append(in.events, ev)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/emulator"
+)
+
+// MAX_BODY is the largest request body accepted, enough for a megachip rom or save state
+const MAX_BODY = 64 << 20
+
+// errBadRequest marks errors caused by the request rather than the emulator
+var errBadRequest = errors.New("bad request")
+
+// Server is a local http api for scripting a running emulator. Requests are json unless noted:
+//
+//	GET  /status               mode and whether paused
+//	POST /pause, /resume       pause or resume execution
+//	POST /reset                restart the current program
+//	POST /load?name=game.ch8   load the program in the body, the name picks save state files and octo source
+//	POST /quit                 stop the emulator
+//	POST /keys/{key}/press     hold a key on the hex keypad, 0-f
+//	POST /keys/{key}/release   release a key
+//	GET  /registers            read V0-VF, I, PC, SP, the stack and the timers
+//	PUT  /registers            change the registers given, leaving the rest
+//	GET  /memory?addr=&length= read memory, the data is hex
+//	PUT  /memory               write memory, as {"addr": 512, "data": "a2b4"}
+//	GET  /screenshot?scale=    the screen as a png, scaled up by a whole number
+//	POST /screenshot           save a screenshot, like the hotkey, returning the file as {"file": "..."}
+//	GET  /state                a save state, as binary
+//	PUT  /state                restore a save state from the body
+type Server struct {
+	emu   *emulator.Emulator
+	input *Input
+	l     net.Listener
+	srv   *http.Server
+	log   *slog.Logger
+}
+
+// Listen starts listening on a tcp address like localhost:8080. input must be the input the emulator was created
+// with, for pressing keys. Serve must be called to handle requests.
+func Listen(addr string, emu *emulator.Emulator, input *Input, log *slog.Logger) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on %s", addr)
+	}
+	s := &Server{emu: emu, input: input, l: l, log: log}
+	s.srv = &http.Server{Handler: s.routes()}
+	return s, nil
+}
+
+// Addr returns the address being listened on
+func (s *Server) Addr() net.Addr {
+	return s.l.Addr()
+}
+
+// Serve handles requests until the server is closed
+func (s *Server) Serve() error {
+	if err := s.srv.Serve(s.l); !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "api server failed")
+	}
+	return nil
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", s.status)
+	mux.HandleFunc("POST /pause", s.pause(true))
+	mux.HandleFunc("POST /resume", s.pause(false))
+	mux.HandleFunc("POST /reset", s.reset)
+	mux.HandleFunc("POST /load", s.load)
+	mux.HandleFunc("POST /quit", s.quit)
+	mux.HandleFunc("POST /keys/{key}/press", s.key(true))
+	mux.HandleFunc("POST /keys/{key}/release", s.key(false))
+	mux.HandleFunc("GET /registers", s.registers)
+	mux.HandleFunc("PUT /registers", s.setRegisters)
+	mux.HandleFunc("GET /memory", s.memory)
+	mux.HandleFunc("PUT /memory", s.setMemory)
+	mux.HandleFunc("GET /screenshot", s.screenshot)
+	mux.HandleFunc("POST /screenshot", s.saveScreenshot)
+	mux.HandleFunc("GET /state", s.state)
+	mux.HandleFunc("PUT /state", s.setState)
+	return http.MaxBytesHandler(mux, MAX_BODY)
+}
+
+// do runs fn on the emulator's goroutine, writing an error response if it fails
+func (s *Server) do(w http.ResponseWriter, fn func() error) bool {
+	err := s.emu.Do(fn)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errBadRequest):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, emulator.ErrNotRunning):
+		writeError(w, http.StatusServiceUnavailable, err)
+	default:
+		s.log.Error("api request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// ok is the response to requests with nothing else to say
+func ok(w http.ResponseWriter) {
+	writeJSON(w, map[string]bool{"ok": true})
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/emulator"
+	"github.com/swensone/gorito/types"
+)
+
+// testROM saves v0 to 0x300, then sets v2 once key 5 is pressed
+var testROM = []byte{
+	0x60, 0x05, // 200: v0 := 5
+	0xA3, 0x00, // 202: i := 0x300
+	0xF0, 0x55, // 204: save v0
+	0x61, 0x05, // 206: v1 := 5
+	0xE1, 0x9E, // 208: if v1 -key then
+	0x12, 0x08, // 20A: jump 208
+	0x62, 0x01, // 20C: v2 := 1
+	0x12, 0x0E, // 20E: jump 20E
+}
+
+// call is a request and the response expected, body holds a string the response must contain. The request is retried
+// until it matches, for changes that take a few instructions to show.
+type call struct {
+	method string
+	path   string
+	body   string
+	status int
+	want   string
+}
+
+func TestServer(t *testing.T) {
+	tests := []struct {
+		name  string
+		calls []call
+	}{
+		{
+			"status",
+			[]call{
+				{"GET", "/status", "", 200, `{"mode":"chip-8","paused":false}`},
+			},
+		},
+		{
+			"pause and resume",
+			[]call{
+				{"POST", "/pause", "", 200, `{"ok":true}`},
+				{"GET", "/status", "", 200, `"paused":true`},
+				{"POST", "/resume", "", 200, `{"ok":true}`},
+				{"GET", "/status", "", 200, `"paused":false`},
+			},
+		},
+		{
+			"registers",
+			[]call{
+				{"GET", "/registers", "", 200, `"pc":520`},
+				{"GET", "/registers", "", 200, `"v":[5,5,0,0,0,0,0,0,0,0,0,0,0,0,0,0],"i":769`},
+				{"POST", "/pause", "", 200, `{"ok":true}`},
+				{"PUT", "/registers", `{"v":{"3":7},"i":1024,"delay":9}`, 200, `{"ok":true}`},
+				{"GET", "/registers", "", 200, `"v":[5,5,0,7,0,0,0,0,0,0,0,0,0,0,0,0],"i":1024`},
+				{"GET", "/registers", "", 200, `"delay":9`},
+				{"PUT", "/registers", `{"v":{"16":1}}`, 400, `invalid register v16`},
+				{"PUT", "/registers", `{"sp":13}`, 400, `deeper than the 12 level stack`},
+			},
+		},
+		{
+			"memory",
+			[]call{
+				{"GET", "/memory?addr=0x200&length=4", "", 200, `{"addr":512,"data":"6005a300"}`},
+				{"GET", "/memory?addr=0x300&length=1", "", 200, `"data":"05"`},
+				{"PUT", "/memory", `{"addr":768,"data":"abcd"}`, 200, `{"ok":true}`},
+				{"GET", "/memory?addr=768&length=2", "", 200, `"data":"abcd"`},
+				{"GET", "/memory?addr=0xfff&length=2", "", 400, `past the end of 4096 bytes`},
+				{"PUT", "/memory", `{"addr":768,"data":"xyz"}`, 400, `invalid data`},
+			},
+		},
+		{
+			"keys",
+			[]call{
+				{"POST", "/keys/5/press", "", 200, `{"ok":true}`},
+				{"GET", "/registers", "", 200, `"v":[5,5,1,`},
+				{"POST", "/keys/5/release", "", 200, `{"ok":true}`},
+				{"POST", "/keys/g/press", "", 400, `invalid key g`},
+			},
+		},
+		{
+			"load",
+			[]call{
+				{"POST", "/load?name=other.ch8", "\x61\x42\x12\x02", 200, `{"ok":true}`},
+				{"GET", "/registers", "", 200, `"v":[0,66,`},
+				{"POST", "/load?name=other.8o", ": main v1 := 7 loop again", 200, `{"ok":true}`},
+				{"GET", "/registers", "", 200, `"v":[0,7,`},
+				{"POST", "/load?name=broken.8o", "v1 := ", 400, `broken.8o:1`},
+				{"POST", "/load", strings.Repeat("\x00", 4000), 400, `rom is 4000 bytes`},
+			},
+		},
+		{
+			"reset",
+			[]call{
+				{"POST", "/pause", "", 200, `{"ok":true}`},
+				{"PUT", "/registers", `{"v":{"0":1}}`, 200, `{"ok":true}`},
+				{"POST", "/reset", "", 200, `{"ok":true}`},
+				{"GET", "/registers", "", 200, `"v":[5,5,`},
+			},
+		},
+		{
+			"screenshot",
+			[]call{
+				{"GET", "/screenshot", "", 200, "\x89PNG"},
+				{"GET", "/screenshot?scale=4", "", 200, "\x89PNG"},
+				{"GET", "/screenshot?scale=0", "", 400, "invalid scale"},
+				{"POST", "/screenshot", "", 200, "gorito-screenshots/test-"},
+			},
+		},
+		{
+			"state",
+			[]call{
+				{"GET", "/state", "", 200, "GRTS"},
+				{"PUT", "/state", "bogus", 400, `not a gorito save state`},
+			},
+		},
+		{
+			"quit",
+			[]call{
+				{"POST", "/quit", "", 200, `{"ok":true}`},
+				{"GET", "/status", "", 503, `emulator isn't running`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			rom := filepath.Join(dir, "test.ch8")
+			if err := os.WriteFile(rom, testROM, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			input := NewInput(nil)
+			e, err := emulator.New(emulator.EmulatorConfig{
+				Savefile: filepath.Join(dir, "saves.json"),
+				Mode:     types.MODE_CHIP8,
+				Quirks:   types.QuirksForMode(types.MODE_CHIP8),
+				Speed:    6000,
+			}, nil, nil, input, slog.Default())
+			if err != nil {
+				t.Fatal(err)
+			}
+			ran := make(chan error, 1)
+			go func() {
+				ran <- e.Run(rom)
+			}()
+
+			s := &Server{emu: e, input: input, log: slog.Default()}
+			ts := httptest.NewServer(s.routes())
+			defer ts.Close()
+
+			for _, c := range tt.calls {
+				status, body := 0, ""
+				for start := time.Now(); time.Since(start) < time.Second; time.Sleep(5 * time.Millisecond) {
+					status, body = request(t, ts.URL, c)
+					if status == c.status && strings.Contains(body, c.want) {
+						break
+					}
+				}
+				assert.Equal(t, status, c.status, c.method+" "+c.path)
+				assert.Equal(t, strings.Contains(body, c.want), true, body)
+			}
+
+			input.send(emulator.Event{Type: emulator.EVENT_QUIT})
+			if err := <-ran; err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func request(t *testing.T, url string, c call) (int, string) {
+	req, err := http.NewRequest(c.method, url+c.path, strings.NewReader(c.body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, string(body)
+}
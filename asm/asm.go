@@ -0,0 +1,442 @@
+package asm
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// MEMORY_SIZE is the addressable memory of the largest non-megachip platform, xo-chip
+	MEMORY_SIZE = 0x10000
+	// MAX_EXPANSIONS stops runaway recursive macros
+	MAX_EXPANSIONS = 100000
+)
+
+// Program is an assembled rom along with its symbols
+type Program struct {
+	Start   int
+	ROM     []byte
+	Symbols []Symbol
+}
+
+type macro struct {
+	args []string
+	body []token
+}
+
+// fixup patches a forward reference once every label is known
+type fixup struct {
+	tok   token
+	apply func(value int) error
+}
+
+type loop struct {
+	start  int
+	breaks []int
+}
+
+// pendingTokens is the rest of a token stream that a macro expansion interrupted
+type pendingTokens struct {
+	tokens []token
+	pos    int
+}
+
+type assembler struct {
+	file    string
+	tokens  []token
+	pos     int
+	pending []pendingTokens // resumed in reverse order as each expansion is used up
+	last    token
+
+	mem     []byte
+	written []bool
+	emitted int // bytes written so far
+	here    int
+	start   int
+
+	// as in octo, execution starts at main. a jump to it is reserved at the start address and dropped when main comes
+	// first.
+	mainJump bool
+	main     token
+
+	labels      map[string]int
+	consts      map[string]float64
+	aliases     map[string]uint8
+	macros      map[string]macro
+	breakpoints map[string]int
+	fixups      []fixup
+	expansions  int
+
+	// open control flow, loops and if ... begin blocks
+	loops    []loop
+	branches []int
+}
+
+// Assemble assembles octo source into a rom loaded at start, 0x200 on most platforms. file is only used in errors. The
+// source must define main, where the rom starts running.
+func Assemble(file string, src []byte, start int) (*Program, error) {
+	a := &assembler{
+		file:        file,
+		tokens:      tokenize(string(src)),
+		mem:         make([]byte, MEMORY_SIZE),
+		written:     make([]bool, MEMORY_SIZE),
+		here:        start,
+		start:       start,
+		labels:      map[string]int{},
+		consts:      map[string]float64{},
+		aliases:     map[string]uint8{"compare-temp": 0xF, "unpack-hi": 0x0, "unpack-lo": 0x1},
+		macros:      map[string]macro{},
+		breakpoints: map[string]int{},
+	}
+	if err := a.emit(0x10, 0x00); err != nil {
+		return nil, err
+	}
+	a.mainJump = true
+
+	for !a.eof() {
+		if err := a.statement(a.next()); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(a.loops) > 0 {
+		return nil, a.errorf("loop without a matching again")
+	}
+	if len(a.branches) > 0 {
+		return nil, a.errorf("begin without a matching end")
+	}
+
+	for _, f := range a.fixups {
+		addr, ok := a.labels[f.tok.text]
+		if !ok {
+			return nil, a.errorAt(f.tok, "undefined name %s", f.tok.text)
+		}
+		if err := f.apply(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	main, ok := a.labels["main"]
+	if !ok {
+		return nil, errors.Newf("%s: program is missing a main label", a.file)
+	}
+	if a.mainJump {
+		if err := a.patchNNN(a.main, a.start, main); err != nil {
+			return nil, err
+		}
+	}
+
+	return a.program()
+}
+
+// program collects the assembled bytes from the start address up to the last byte written
+func (a *assembler) program() (*Program, error) {
+	end := a.start
+	for addr, w := range a.written {
+		if !w {
+			continue
+		}
+		if addr < a.start {
+			return nil, errors.Newf("%s: data written at 0x%03X, before the start address 0x%03X", a.file, addr, a.start)
+		}
+		end = addr + 1
+	}
+
+	p := &Program{
+		Start: a.start,
+		ROM:   append([]byte{}, a.mem[a.start:end]...),
+	}
+	for name, addr := range a.labels {
+		p.Symbols = append(p.Symbols, Symbol{Name: name, Kind: SYMBOL_LABEL, Value: addr})
+	}
+	for name, v := range a.consts {
+		p.Symbols = append(p.Symbols, Symbol{Name: name, Kind: SYMBOL_CONST, Value: toInt(v)})
+	}
+	for name, addr := range a.breakpoints {
+		p.Symbols = append(p.Symbols, Symbol{Name: name, Kind: SYMBOL_BREAKPOINT, Value: addr})
+	}
+	sortSymbols(p.Symbols)
+
+	return p, nil
+}
+
+func (a *assembler) errorf(format string, args ...interface{}) error {
+	return a.errorAt(a.last, format, args...)
+}
+
+func (a *assembler) errorAt(t token, format string, args ...interface{}) error {
+	return errors.Newf("%s:%d: %s", a.file, t.line, fmt.Sprintf(format, args...))
+}
+
+func (a *assembler) eof() bool {
+	for a.pos >= len(a.tokens) && len(a.pending) > 0 {
+		p := a.pending[len(a.pending)-1]
+		a.pending = a.pending[:len(a.pending)-1]
+		a.tokens, a.pos = p.tokens, p.pos
+	}
+	return a.pos >= len(a.tokens)
+}
+
+// next consumes a token, returning an empty one at the end of the file
+func (a *assembler) next() token {
+	if a.eof() {
+		return token{line: a.last.line}
+	}
+	a.last = a.tokens[a.pos]
+	a.pos++
+	return a.last
+}
+
+func (a *assembler) peek() string {
+	if a.eof() {
+		return ""
+	}
+	return a.tokens[a.pos].text
+}
+
+// expect consumes a token that must be text
+func (a *assembler) expect(text string) error {
+	t := a.next()
+	if t.text != text {
+		return a.errorAt(t, "expected %s, found %s", text, describe(t))
+	}
+	return nil
+}
+
+func describe(t token) string {
+	if t.text == "" {
+		return "end of file"
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+// name consumes a token used to define a new name
+func (a *assembler) name() (token, error) {
+	t := a.next()
+	if t.text == "" {
+		return t, a.errorAt(t, "expected a name, found end of file")
+	}
+	if _, ok := a.registerFor(t.text); ok {
+		return t, a.errorAt(t, "register %s can't be used as a name", t.text)
+	}
+	if _, err := parseNumber(t.text); err == nil {
+		return t, a.errorAt(t, "number %s can't be used as a name", t.text)
+	}
+	return t, nil
+}
+
+// defineMain defines main, dropping the reserved jump to it when nothing else has been written yet
+func (a *assembler) defineMain(t token) error {
+	a.main = t
+	if a.mainJump && a.emitted == 2 && (a.here == a.start+2 || a.here == a.start) {
+		a.written[a.start], a.written[a.start+1] = false, false
+		a.emitted = 0
+		a.here = a.start
+		a.mainJump = false
+	}
+	return a.defineLabel(t, a.here)
+}
+
+func (a *assembler) defineLabel(t token, addr int) error {
+	if _, ok := a.labels[t.text]; ok {
+		return a.errorAt(t, "label %s is already defined", t.text)
+	}
+	if _, ok := a.consts[t.text]; ok {
+		return a.errorAt(t, "%s is already defined as a constant", t.text)
+	}
+	a.labels[t.text] = addr
+	return nil
+}
+
+// emit writes bytes at the current address
+func (a *assembler) emit(data ...byte) error {
+	for _, b := range data {
+		if a.here >= MEMORY_SIZE {
+			return a.errorf("program doesn't fit in %d bytes", MEMORY_SIZE)
+		}
+		if a.written[a.here] {
+			return a.errorf("data overlaps at 0x%03X", a.here)
+		}
+		a.mem[a.here] = b
+		a.written[a.here] = true
+		a.emitted++
+		a.here++
+	}
+	return nil
+}
+
+// registerFor returns the register a name refers to, either vX or an alias
+func (a *assembler) registerFor(s string) (uint8, bool) {
+	if r, ok := a.aliases[s]; ok {
+		return r, true
+	}
+	if len(s) == 2 && (s[0] == 'v' || s[0] == 'V') {
+		if r, err := strconv.ParseUint(s[1:], 16, 8); err == nil {
+			return uint8(r), true
+		}
+	}
+	return 0, false
+}
+
+func (a *assembler) isRegister() bool {
+	_, ok := a.registerFor(a.peek())
+	return ok
+}
+
+// register consumes a register
+func (a *assembler) register() (uint8, error) {
+	t := a.next()
+	r, ok := a.registerFor(t.text)
+	if !ok {
+		return 0, a.errorAt(t, "expected a register, found %s", describe(t))
+	}
+	return r, nil
+}
+
+// resolve consumes a number, constant, defined label or { calc } expression. ok is false when t is a name that hasn't
+// been defined, which may be a label later in the file.
+func (a *assembler) resolve() (v float64, t token, ok bool, err error) {
+	t = a.next()
+	if t.text == "{" {
+		v, err = a.calc()
+		return v, t, true, err
+	}
+	if n, err := parseNumber(t.text); err == nil {
+		return float64(n), t, true, nil
+	}
+	if c, ok := a.consts[t.text]; ok {
+		return c, t, true, nil
+	}
+	if addr, ok := a.labels[t.text]; ok {
+		return float64(addr), t, true, nil
+	}
+	if t.text == "" {
+		return 0, t, false, a.errorAt(t, "expected a value, found end of file")
+	}
+	if _, ok := a.registerFor(t.text); ok {
+		return 0, t, false, a.errorAt(t, "expected a value, found register %s", t.text)
+	}
+	return 0, t, false, nil
+}
+
+// value consumes a value that must already be defined, checking it's in the range min to max
+func (a *assembler) value(min, max int) (int, error) {
+	v, t, ok, err := a.resolve()
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, a.errorAt(t, "undefined name %s", t.text)
+	}
+	return a.checkRange(t, toInt(v), min, max)
+}
+
+func (a *assembler) checkRange(t token, v, min, max int) (int, error) {
+	if v < min || v > max {
+		return 0, a.errorAt(t, "value %d is out of range, must be between %d and %d", v, min, max)
+	}
+	return v, nil
+}
+
+// byteValue consumes an 8 bit value, negative values are stored as two's complement
+func (a *assembler) byteValue() (byte, error) {
+	v, err := a.value(-128, 255)
+	return byte(v), err
+}
+
+// reference consumes an address, which may be a label defined later in the file. apply is called with the address
+// once it's known and should check its range.
+func (a *assembler) reference(apply func(t token, addr int) error) error {
+	v, t, ok, err := a.resolve()
+	if err != nil {
+		return err
+	}
+	if ok {
+		return apply(t, toInt(v))
+	}
+	a.fixups = append(a.fixups, fixup{tok: t, apply: func(addr int) error { return apply(t, addr) }})
+	return nil
+}
+
+// addressInstruction emits an instruction taking a 12 bit address, like 1NNN or ANNN
+func (a *assembler) addressInstruction(op byte) error {
+	addr := a.here
+	if err := a.emit(op<<4, 0x00); err != nil {
+		return err
+	}
+	return a.reference(func(t token, v int) error {
+		return a.patchNNN(t, addr, v)
+	})
+}
+
+// patchNNN fills in the 12 bit address of the instruction at addr
+func (a *assembler) patchNNN(t token, addr, v int) error {
+	if _, err := a.checkRange(t, v, 0, 0xFFF); err != nil {
+		return err
+	}
+	a.mem[addr] = a.mem[addr]&0xF0 | byte(v>>8)
+	a.mem[addr+1] = byte(v)
+	return nil
+}
+
+// expand substitutes the arguments of a macro invocation into its body and splices it into the token stream
+func (a *assembler) expand(t token, m macro) error {
+	a.expansions++
+	if a.expansions > MAX_EXPANSIONS {
+		return a.errorAt(t, "too many macro expansions, is %s recursive?", t.text)
+	}
+
+	args := map[string]string{}
+	for _, name := range m.args {
+		arg := a.next()
+		if arg.text == "" {
+			return a.errorAt(arg, "macro %s expects %d arguments", t.text, len(m.args))
+		}
+		args[name] = arg.text
+	}
+
+	body := make([]token, 0, len(m.body))
+	for _, bt := range m.body {
+		if arg, ok := args[bt.text]; ok {
+			bt.text = arg
+		}
+		body = append(body, bt)
+	}
+	// the interrupted stream is only kept if anything is left in it, so a macro ending in an invocation doesn't grow
+	// the stack
+	if a.pos < len(a.tokens) {
+		a.pending = append(a.pending, pendingTokens{a.tokens, a.pos})
+	}
+	a.tokens, a.pos = body, 0
+	return nil
+}
+
+// parseNumber parses decimal, 0x hex and 0b binary numbers, with an optional minus sign
+func parseNumber(s string) (int, error) {
+	digits, negative := strings.CutPrefix(s, "-")
+	base := 10
+	if d, ok := strings.CutPrefix(digits, "0x"); ok {
+		digits, base = d, 16
+	} else if d, ok := strings.CutPrefix(digits, "0b"); ok {
+		digits, base = d, 2
+	}
+	if digits == "" || digits[0] == '-' || digits[0] == '+' {
+		return 0, errors.Newf("invalid number %s", s)
+	}
+	n, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		n = -n
+	}
+	return int(n), nil
+}
+
+func toInt(v float64) int {
+	return int(math.Floor(v))
+}
@@ -0,0 +1,149 @@
+package asm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{
+			"instructions",
+			": main clear v0 := 5 v1 += -1 v2 -= 1 v3 ^= v4 i := 0x300 sprite v0 v1 15 return",
+			[]byte{0x00, 0xE0, 0x60, 0x05, 0x71, 0xFF, 0x72, 0xFF, 0x83, 0x43, 0xA3, 0x00, 0xD0, 0x1F, 0x00, 0xEE},
+		},
+		{
+			"labels and forward references",
+			": main jump later 0xAA : later :call main later",
+			[]byte{0x12, 0x03, 0xAA, 0x22, 0x00, 0x22, 0x03},
+		},
+		{
+			"constants, aliases and calc",
+			": main :const speed 3 :alias x v5 :calc double { speed * 2 } x := double x += speed :byte { 1 << 4 + 1 }",
+			[]byte{0x65, 0x06, 0x75, 0x03, 0x20},
+		},
+		{
+			"macros",
+			": main :macro set reg val { reg := val } set v1 7 set v2 0b101",
+			[]byte{0x61, 0x07, 0x62, 0x05},
+		},
+		{
+			"nested macros",
+			": main :macro one r { r += 1 } :macro two r { one r one r } :macro set r { r := } two v1 set v2 9 one v3",
+			[]byte{0x71, 0x01, 0x71, 0x01, 0x62, 0x09, 0x73, 0x01},
+		},
+		{
+			"org and next",
+			": main jump 0x202 :org 0x206 : loop2 :next target v0 := 1 i := target",
+			[]byte{0x12, 0x02, 0x00, 0x00, 0x00, 0x00, 0x60, 0x01, 0xA2, 0x07},
+		},
+		{
+			"if then",
+			": main if v0 == 1 then v1 := 2 if v0 != v2 then return if v3 key then return",
+			[]byte{0x40, 0x01, 0x61, 0x02, 0x50, 0x20, 0x00, 0xEE, 0xE3, 0xA1, 0x00, 0xEE},
+		},
+		{
+			"ordered comparison",
+			": main if v1 > v2 then return",
+			[]byte{0x8F, 0x20, 0x8F, 0x15, 0x3F, 0x01, 0x00, 0xEE},
+		},
+		{
+			"if begin else end",
+			": main if v0 == 0 begin v1 := 1 else v1 := 2 end",
+			[]byte{0x30, 0x00, 0x12, 0x08, 0x61, 0x01, 0x12, 0x0A, 0x61, 0x02},
+		},
+		{
+			"loops",
+			": main loop v0 += 1 while v0 != 10 again",
+			[]byte{0x70, 0x01, 0x40, 0x0A, 0x12, 0x08, 0x12, 0x00},
+		},
+		{
+			"xo-chip",
+			": main i := long data save v1 - v3 plane 3 audio pitch := v2 : data 0x01",
+			[]byte{0xF0, 0x00, 0x02, 0x0C, 0x51, 0x32, 0xF3, 0x01, 0xF0, 0x02, 0xF2, 0x3A, 0x01},
+		},
+		{
+			"code before main",
+			": sub return : main :call sub",
+			[]byte{0x12, 0x04, 0x00, 0xEE, 0x22, 0x02},
+		},
+		{
+			"data before main",
+			": sprite 0xF0 0x90 : main i := sprite",
+			[]byte{0x12, 0x04, 0xF0, 0x90, 0xA2, 0x02},
+		},
+		{
+			"constants before main",
+			":const lives 3 : main v0 := lives",
+			[]byte{0x60, 0x03},
+		},
+		{
+			"unpack",
+			": main :unpack 0xA data : data 0x01",
+			[]byte{0x60, 0xA2, 0x61, 0x04, 0x01},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Assemble("test.8o", []byte(tt.src), 0x200)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, p.ROM, tt.want)
+		})
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"undefined label", "v0 := 1\njump nowhere", "test.8o:2: undefined name nowhere"},
+		{"duplicate label", ": a : a", "label a is already defined"},
+		{"byte out of range", "v0 := 256", "value 256 is out of range"},
+		{"overlap", "0x01 :org 0x200 0x02", "data overlaps at 0x200"},
+		{"unclosed loop", "loop v0 += 1", "loop without a matching again"},
+		{"unclosed begin", "if v0 == 1 begin", "begin without a matching end"},
+		{"bad comparison", "if v0 =~ 1 then", "unknown comparison '=~'"},
+		{"assert", ":assert \"too big\" { 2 > 3 }", "too big"},
+		{"recursive macro", ": main :macro spin { spin } spin", "too many macro expansions, is spin recursive?"},
+		{"missing main", "v0 := 1", "test.8o: program is missing a main label"},
+		{"main out of jump range", ":org 0x1000 : main v0 := 1", "value 4096 is out of range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Assemble("test.8o", []byte(tt.src), 0x200)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			assert.Matches(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	p, err := Assemble("test.8o", []byte(":const lives 3 : main :breakpoint start clear : sub return"), 0x200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteSymbols(&buf); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, buf.String(), ""+
+		"0x0003 const      lives\n"+
+		"0x0200 label      main\n"+
+		"0x0200 breakpoint start\n"+
+		"0x0202 label      sub\n")
+}
@@ -0,0 +1,130 @@
+package asm
+
+import (
+	"math"
+)
+
+// calc evaluation follows octo: there is no operator precedence and expressions are evaluated right to left, so
+// { 2 * 3 + 1 } is 8. Use parentheses to group.
+
+var unaryOps = map[string]func(float64) float64{
+	"-":     func(v float64) float64 { return -v },
+	"~":     func(v float64) float64 { return float64(^int64(v)) },
+	"!":     func(v float64) float64 { return boolValue(v == 0) },
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"exp":   math.Exp,
+	"log":   math.Log,
+	"abs":   math.Abs,
+	"sqrt":  math.Sqrt,
+	"ceil":  math.Ceil,
+	"floor": math.Floor,
+	"sign": func(v float64) float64 {
+		if v == 0 {
+			return 0
+		}
+		return math.Copysign(1, v)
+	},
+}
+
+var binaryOps = map[string]func(float64, float64) float64{
+	"+":   func(a, b float64) float64 { return a + b },
+	"-":   func(a, b float64) float64 { return a - b },
+	"*":   func(a, b float64) float64 { return a * b },
+	"/":   func(a, b float64) float64 { return a / b },
+	"%":   math.Mod,
+	"&":   func(a, b float64) float64 { return float64(int64(a) & int64(b)) },
+	"|":   func(a, b float64) float64 { return float64(int64(a) | int64(b)) },
+	"^":   func(a, b float64) float64 { return float64(int64(a) ^ int64(b)) },
+	"<<":  func(a, b float64) float64 { return float64(int64(a) << uint64(b)) },
+	">>":  func(a, b float64) float64 { return float64(int64(a) >> uint64(b)) },
+	"pow": math.Pow,
+	"min": math.Min,
+	"max": math.Max,
+	"<":   func(a, b float64) float64 { return boolValue(a < b) },
+	">":   func(a, b float64) float64 { return boolValue(a > b) },
+	"<=":  func(a, b float64) float64 { return boolValue(a <= b) },
+	">=":  func(a, b float64) float64 { return boolValue(a >= b) },
+	"==":  func(a, b float64) float64 { return boolValue(a == b) },
+	"!=":  func(a, b float64) float64 { return boolValue(a != b) },
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// calc evaluates an expression up to the closing brace, the opening brace has already been consumed
+func (a *assembler) calc() (float64, error) {
+	v, err := a.expr()
+	if err != nil {
+		return 0, err
+	}
+	return v, a.expect("}")
+}
+
+func (a *assembler) expr() (float64, error) {
+	left, err := a.term()
+	if err != nil {
+		return 0, err
+	}
+	op, ok := binaryOps[a.peek()]
+	if !ok {
+		return left, nil
+	}
+	a.next()
+	right, err := a.expr()
+	if err != nil {
+		return 0, err
+	}
+	return op(left, right), nil
+}
+
+func (a *assembler) term() (float64, error) {
+	t := a.next()
+	switch t.text {
+	case "(":
+		v, err := a.expr()
+		if err != nil {
+			return 0, err
+		}
+		return v, a.expect(")")
+	case "@":
+		// the byte assembled at an address
+		addr, err := a.term()
+		if err != nil {
+			return 0, err
+		}
+		return float64(a.mem[toInt(addr)&(MEMORY_SIZE-1)]), nil
+	case "HERE":
+		return float64(a.here), nil
+	case "PI":
+		return math.Pi, nil
+	case "E":
+		return math.E, nil
+	}
+	if op, ok := unaryOps[t.text]; ok {
+		v, err := a.term()
+		if err != nil {
+			return 0, err
+		}
+		return op(v), nil
+	}
+
+	if n, err := parseNumber(t.text); err == nil {
+		return float64(n), nil
+	}
+	if c, ok := a.consts[t.text]; ok {
+		return c, nil
+	}
+	if addr, ok := a.labels[t.text]; ok {
+		return float64(addr), nil
+	}
+	if t.text == "" {
+		return 0, a.errorAt(t, "unterminated expression")
+	}
+	return 0, a.errorAt(t, "undefined name %s in expression", t.text)
+}
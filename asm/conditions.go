@@ -0,0 +1,114 @@
+package asm
+
+// condition is the test in an if or while statement: vx op vy, vx op nn, or vx key / vx -key
+type condition struct {
+	x     byte
+	op    string
+	y     byte
+	isReg bool
+	nn    byte
+}
+
+func (a *assembler) condition() (condition, error) {
+	var c condition
+	var err error
+	if c.x, err = a.register(); err != nil {
+		return c, err
+	}
+
+	op := a.next()
+	c.op = op.text
+	switch c.op {
+	case "key", "-key":
+		return c, nil
+	case "==", "!=", "<", ">", "<=", ">=":
+	default:
+		return c, a.errorAt(op, "unknown comparison %s", describe(op))
+	}
+
+	if a.isRegister() {
+		c.isReg = true
+		c.y, err = a.register()
+	} else {
+		c.nn, err = a.byteValue()
+	}
+	return c, err
+}
+
+// emitCondition emits the skip for a condition. The skip passes over the next instruction when the condition is false,
+// which makes the next instruction run only when it's true. When negated it's the other way around, which is used to
+// jump past the body of a block when its condition is false.
+func (a *assembler) emitCondition(c condition, negated bool) error {
+	// the inverse of each comparison, for negated conditions
+	inverse := map[string]string{
+		"key": "-key", "-key": "key",
+		"==": "!=", "!=": "==",
+		"<": ">=", ">=": "<",
+		">": "<=", "<=": ">",
+	}
+	op := c.op
+	if negated {
+		op = inverse[op]
+	}
+
+	switch op {
+	case "key":
+		return a.emit(0xE0|c.x, 0xA1)
+	case "-key":
+		return a.emit(0xE0|c.x, 0x9E)
+	case "==":
+		if c.isReg {
+			return a.emit(0x90|c.x, c.y<<4)
+		}
+		return a.emit(0x40|c.x, c.nn)
+	case "!=":
+		if c.isReg {
+			return a.emit(0x50|c.x, c.y<<4)
+		}
+		return a.emit(0x30|c.x, c.nn)
+	}
+
+	// ordered comparisons subtract in the compare-temp register, vf by default, and test the resulting flag.
+	// temp := y; temp -= x sets the flag when y >= x, temp =- x sets it when x >= y.
+	temp := a.aliases["compare-temp"]
+	if c.isReg {
+		if err := a.emit(0x80|temp, c.y<<4); err != nil {
+			return err
+		}
+	} else {
+		if err := a.emit(0x60|temp, c.nn); err != nil {
+			return err
+		}
+	}
+	switch op {
+	case ">":
+		return a.emit(0x80|temp, c.x<<4|0x5, 0x3F, 0x01)
+	case "<=":
+		return a.emit(0x80|temp, c.x<<4|0x5, 0x4F, 0x01)
+	case "<":
+		return a.emit(0x80|temp, c.x<<4|0x7, 0x3F, 0x01)
+	default: // >=
+		return a.emit(0x80|temp, c.x<<4|0x7, 0x4F, 0x01)
+	}
+}
+
+// ifStatement handles if ... then statement and if ... begin ... else ... end blocks
+func (a *assembler) ifStatement() error {
+	c, err := a.condition()
+	if err != nil {
+		return err
+	}
+
+	t := a.next()
+	switch t.text {
+	case "then":
+		return a.emitCondition(c, false)
+	case "begin":
+		if err := a.emitCondition(c, true); err != nil {
+			return err
+		}
+		a.branches = append(a.branches, a.here)
+		return a.emit(0x10, 0x00)
+	}
+	return a.errorAt(t, "expected then or begin, found %s", describe(t))
+}
@@ -0,0 +1,493 @@
+package asm
+
+import (
+	"strings"
+)
+
+// simple instructions that take no operands
+var simpleInstructions = map[string][]byte{
+	"clear":        {0x00, 0xE0},
+	"return":       {0x00, 0xEE},
+	";":            {0x00, 0xEE},
+	"scroll-right": {0x00, 0xFB},
+	"scroll-left":  {0x00, 0xFC},
+	"exit":         {0x00, 0xFD},
+	"lores":        {0x00, 0xFE},
+	"hires":        {0x00, 0xFF},
+	"audio":        {0xF0, 0x02},
+}
+
+// instructions taking a single register as FXNN
+var registerInstructions = map[string]byte{
+	"bcd":       0x33,
+	"save":      0x55,
+	"load":      0x65,
+	"saveflags": 0x75,
+	"loadflags": 0x85,
+}
+
+// assignments to the timers and pitch register, as FXNN
+var timerInstructions = map[string]byte{
+	"delay":  0x15,
+	"buzzer": 0x18,
+	"pitch":  0x3A,
+}
+
+// register to register arithmetic as 8XYN
+var arithmeticInstructions = map[string]byte{
+	":=":  0x0,
+	"|=":  0x1,
+	"&=":  0x2,
+	"^=":  0x3,
+	"+=":  0x4,
+	"-=":  0x5,
+	">>=": 0x6,
+	"=-":  0x7,
+	"<<=": 0xE,
+}
+
+func (a *assembler) statement(t token) error {
+	if code, ok := simpleInstructions[t.text]; ok {
+		return a.emit(code...)
+	}
+	if nn, ok := registerInstructions[t.text]; ok {
+		return a.registerInstruction(t, nn)
+	}
+	if nn, ok := timerInstructions[t.text]; ok {
+		if err := a.expect(":="); err != nil {
+			return err
+		}
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		return a.emit(0xF0|x, nn)
+	}
+	if x, ok := a.registerFor(t.text); ok {
+		return a.assignment(x)
+	}
+
+	switch t.text {
+	case ":":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		if name.text == "main" {
+			return a.defineMain(name)
+		}
+		return a.defineLabel(name, a.here)
+	case ":next":
+		// a label on the second byte of the next instruction, for self modifying code
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		return a.defineLabel(name, a.here+1)
+	case ":const":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		v, vt, ok, err := a.resolve()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return a.errorAt(vt, "undefined name %s", vt.text)
+		}
+		return a.defineConst(name, v)
+	case ":calc":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		if err := a.expect("{"); err != nil {
+			return err
+		}
+		v, err := a.calc()
+		if err != nil {
+			return err
+		}
+		return a.defineConst(name, v)
+	case ":alias":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		a.aliases[name.text] = x
+		return nil
+	case ":macro":
+		return a.defineMacro()
+	case ":org":
+		addr, err := a.value(0, MEMORY_SIZE-1)
+		if err != nil {
+			return err
+		}
+		a.here = addr
+		return nil
+	case ":byte":
+		b, err := a.byteValue()
+		if err != nil {
+			return err
+		}
+		return a.emit(b)
+	case ":pointer":
+		addr := a.here
+		if err := a.emit(0x00, 0x00); err != nil {
+			return err
+		}
+		return a.reference(func(t token, v int) error {
+			return a.patchLong(t, addr, v)
+		})
+	case ":call":
+		return a.addressInstruction(0x2)
+	case ":unpack":
+		return a.unpack()
+	case ":breakpoint":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		a.breakpoints[name.text] = a.here
+		return nil
+	case ":monitor":
+		// monitors only matter to octo's debugger, skip the address and length or format
+		a.next()
+		a.next()
+		return nil
+	case ":assert":
+		message := "assertion failed"
+		if strings.HasPrefix(a.peek(), "\"") {
+			message = strings.Trim(a.next().text, "\"")
+		}
+		if err := a.expect("{"); err != nil {
+			return err
+		}
+		v, err := a.calc()
+		if err != nil {
+			return err
+		}
+		if v == 0 {
+			return a.errorAt(t, "%s", message)
+		}
+		return nil
+	case "jump":
+		return a.addressInstruction(0x1)
+	case "jump0":
+		return a.addressInstruction(0xB)
+	case "i":
+		return a.indexAssignment()
+	case "sprite":
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		y, err := a.register()
+		if err != nil {
+			return err
+		}
+		n, err := a.value(0, 15)
+		if err != nil {
+			return err
+		}
+		return a.emit(0xD0|x, y<<4|byte(n))
+	case "scroll-down", "scroll-up":
+		n, err := a.value(0, 15)
+		if err != nil {
+			return err
+		}
+		if t.text == "scroll-down" {
+			return a.emit(0x00, 0xC0|byte(n))
+		}
+		return a.emit(0x00, 0xD0|byte(n))
+	case "plane":
+		n, err := a.value(0, 15)
+		if err != nil {
+			return err
+		}
+		return a.emit(0xF0|byte(n), 0x01)
+	case "if":
+		return a.ifStatement()
+	case "else":
+		if len(a.branches) == 0 {
+			return a.errorAt(t, "else without a matching begin")
+		}
+		addr := a.here
+		if err := a.emit(0x10, 0x00); err != nil {
+			return err
+		}
+		if err := a.patchNNN(t, a.branches[len(a.branches)-1], a.here); err != nil {
+			return err
+		}
+		a.branches[len(a.branches)-1] = addr
+		return nil
+	case "end":
+		if len(a.branches) == 0 {
+			return a.errorAt(t, "end without a matching begin")
+		}
+		addr := a.branches[len(a.branches)-1]
+		a.branches = a.branches[:len(a.branches)-1]
+		return a.patchNNN(t, addr, a.here)
+	case "loop":
+		a.loops = append(a.loops, loop{start: a.here})
+		return nil
+	case "while":
+		if len(a.loops) == 0 {
+			return a.errorAt(t, "while outside of a loop")
+		}
+		c, err := a.condition()
+		if err != nil {
+			return err
+		}
+		if err := a.emitCondition(c, true); err != nil {
+			return err
+		}
+		a.loops[len(a.loops)-1].breaks = append(a.loops[len(a.loops)-1].breaks, a.here)
+		return a.emit(0x10, 0x00)
+	case "again":
+		if len(a.loops) == 0 {
+			return a.errorAt(t, "again without a matching loop")
+		}
+		l := a.loops[len(a.loops)-1]
+		a.loops = a.loops[:len(a.loops)-1]
+		if err := a.emit(0x10, 0x00); err != nil {
+			return err
+		}
+		if err := a.patchNNN(t, a.here-2, l.start); err != nil {
+			return err
+		}
+		for _, addr := range l.breaks {
+			if err := a.patchNNN(t, addr, a.here); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if m, ok := a.macros[t.text]; ok {
+		return a.expand(t, m)
+	}
+	if strings.HasPrefix(t.text, ":") {
+		return a.errorAt(t, "unsupported directive %s", t.text)
+	}
+
+	// bare numbers are data, and anything else is a call to a label, which may not be defined yet
+	if _, err := parseNumber(t.text); err == nil || t.text == "{" {
+		a.pos--
+		b, err := a.byteValue()
+		if err != nil {
+			return err
+		}
+		return a.emit(b)
+	}
+	if _, ok := a.consts[t.text]; ok {
+		return a.errorAt(t, "constant %s can't be used as a statement", t.text)
+	}
+	a.pos--
+	return a.addressInstruction(0x2)
+}
+
+func (a *assembler) defineConst(t token, v float64) error {
+	if _, ok := a.labels[t.text]; ok {
+		return a.errorAt(t, "%s is already defined as a label", t.text)
+	}
+	a.consts[t.text] = v
+	return nil
+}
+
+func (a *assembler) defineMacro() error {
+	name, err := a.name()
+	if err != nil {
+		return err
+	}
+
+	var m macro
+	for {
+		t := a.next()
+		if t.text == "" {
+			return a.errorAt(t, "macro %s has no body", name.text)
+		}
+		if t.text == "{" {
+			break
+		}
+		m.args = append(m.args, t.text)
+	}
+
+	depth := 1
+	for {
+		t := a.next()
+		switch t.text {
+		case "":
+			return a.errorAt(name, "macro %s is missing a closing brace", name.text)
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+		m.body = append(m.body, t)
+	}
+
+	a.macros[name.text] = m
+	return nil
+}
+
+// registerInstruction handles the FXNN register instructions, and the xo-chip save and load of a range of registers
+func (a *assembler) registerInstruction(t token, nn byte) error {
+	x, err := a.register()
+	if err != nil {
+		return err
+	}
+	if a.peek() != "-" || (t.text != "save" && t.text != "load") {
+		return a.emit(0xF0|x, nn)
+	}
+
+	a.next()
+	y, err := a.register()
+	if err != nil {
+		return err
+	}
+	n := byte(0x2)
+	if t.text == "load" {
+		n = 0x3
+	}
+	return a.emit(0x50|x, y<<4|n)
+}
+
+// assignment handles statements starting with a register
+func (a *assembler) assignment(x byte) error {
+	op := a.next()
+	if op.text == ":=" {
+		switch a.peek() {
+		case "random":
+			a.next()
+			nn, err := a.byteValue()
+			if err != nil {
+				return err
+			}
+			return a.emit(0xC0|x, nn)
+		case "key":
+			a.next()
+			return a.emit(0xF0|x, 0x0A)
+		case "delay":
+			a.next()
+			return a.emit(0xF0|x, 0x07)
+		}
+	}
+
+	if n, ok := arithmeticInstructions[op.text]; ok && a.isRegister() {
+		y, err := a.register()
+		if err != nil {
+			return err
+		}
+		return a.emit(0x80|x, y<<4|n)
+	}
+
+	switch op.text {
+	case ":=":
+		nn, err := a.byteValue()
+		if err != nil {
+			return err
+		}
+		return a.emit(0x60|x, nn)
+	case "+=":
+		nn, err := a.byteValue()
+		if err != nil {
+			return err
+		}
+		return a.emit(0x70|x, nn)
+	case "-=":
+		nn, err := a.byteValue()
+		if err != nil {
+			return err
+		}
+		return a.emit(0x70|x, -nn)
+	}
+	return a.errorAt(op, "unknown operator %s", describe(op))
+}
+
+// indexAssignment handles statements starting with i
+func (a *assembler) indexAssignment() error {
+	op := a.next()
+	switch op.text {
+	case "+=":
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		return a.emit(0xF0|x, 0x1E)
+	case ":=":
+	default:
+		return a.errorAt(op, "unknown operator %s", describe(op))
+	}
+
+	switch a.peek() {
+	case "hex", "bighex":
+		kind := a.next()
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		if kind.text == "hex" {
+			return a.emit(0xF0|x, 0x29)
+		}
+		return a.emit(0xF0|x, 0x30)
+	case "long":
+		a.next()
+		addr := a.here + 2
+		if err := a.emit(0xF0, 0x00, 0x00, 0x00); err != nil {
+			return err
+		}
+		return a.reference(func(t token, v int) error {
+			return a.patchLong(t, addr, v)
+		})
+	}
+	return a.addressInstruction(0xA)
+}
+
+// patchLong fills in a 16 bit address at addr
+func (a *assembler) patchLong(t token, addr, v int) error {
+	if _, err := a.checkRange(t, v, 0, 0xFFFF); err != nil {
+		return err
+	}
+	a.mem[addr] = byte(v >> 8)
+	a.mem[addr+1] = byte(v)
+	return nil
+}
+
+// unpack loads the unpack-hi and unpack-lo registers with an address, combining the high nibble with n unless the
+// address is long
+func (a *assembler) unpack() error {
+	var nibble byte
+	max := 0xFFF
+	if a.peek() == "long" {
+		a.next()
+		max = 0xFFFF
+	} else {
+		n, err := a.value(0, 15)
+		if err != nil {
+			return err
+		}
+		nibble = byte(n) << 4
+	}
+
+	addr := a.here
+	if err := a.emit(0x60|a.aliases["unpack-hi"], 0x00, 0x60|a.aliases["unpack-lo"], 0x00); err != nil {
+		return err
+	}
+	return a.reference(func(t token, v int) error {
+		if _, err := a.checkRange(t, v, 0, max); err != nil {
+			return err
+		}
+		a.mem[addr+1] = nibble | byte(v>>8)
+		a.mem[addr+3] = byte(v)
+		return nil
+	})
+}
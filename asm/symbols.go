@@ -0,0 +1,59 @@
+package asm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// SymbolKind identifies what a symbol names
+type SymbolKind int
+
+const (
+	SYMBOL_LABEL      SymbolKind = iota // an address defined with : or :next
+	SYMBOL_CONST                        // a value defined with :const or :calc
+	SYMBOL_BREAKPOINT                   // an address marked with :breakpoint
+)
+
+var symbolkindmap = map[SymbolKind]string{
+	SYMBOL_LABEL:      "label",
+	SYMBOL_CONST:      "const",
+	SYMBOL_BREAKPOINT: "breakpoint",
+}
+
+func (k SymbolKind) String() string {
+	return symbolkindmap[k]
+}
+
+type Symbol struct {
+	Name  string
+	Kind  SymbolKind
+	Value int
+}
+
+// sortSymbols orders symbols by value, then kind and name
+func sortSymbols(symbols []Symbol) {
+	sort.Slice(symbols, func(i, j int) bool {
+		if symbols[i].Value != symbols[j].Value {
+			return symbols[i].Value < symbols[j].Value
+		}
+		if symbols[i].Kind != symbols[j].Kind {
+			return symbols[i].Kind < symbols[j].Kind
+		}
+		return symbols[i].Name < symbols[j].Name
+	})
+}
+
+// WriteSymbols writes the program's symbol table, one "value kind name" line per symbol
+func (p *Program) WriteSymbols(w io.Writer) error {
+	for _, s := range p.Symbols {
+		value := fmt.Sprintf("0x%04X", s.Value)
+		if s.Value < 0 {
+			value = fmt.Sprintf("-0x%04X", -s.Value)
+		}
+		if _, err := fmt.Fprintf(w, "%s %-10s %s\n", value, s.Kind.String(), s.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package asm
+
+import "strings"
+
+type token struct {
+	text string
+	line int
+}
+
+// tokenize splits octo source into whitespace separated tokens and drops comments. Quoted strings are kept as single
+// tokens, braces and parentheses are always tokens of their own.
+func tokenize(src string) []token {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case isSpace(c):
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' && src[j] != '\n' {
+				j++
+			}
+			if j < len(src) && src[j] == '"' {
+				j++
+			}
+			tokens = append(tokens, token{src[i:j], line})
+			i = j
+		case strings.IndexByte("{}()", c) >= 0:
+			tokens = append(tokens, token{string(c), line})
+			i++
+		default:
+			j := i
+			for j < len(src) && !isSpace(src[j]) && src[j] != '\n' && strings.IndexByte("{}()#\"", src[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, token{src[i:j], line})
+			i = j
+		}
+	}
+	return tokens
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r'
+}
@@ -4,64 +4,64 @@ import (
 	"math"
 
 	"github.com/gopxl/beep/v2"
-	"github.com/gopxl/beep/v2/effects"
-	"github.com/gopxl/beep/v2/generators"
 	"github.com/gopxl/beep/v2/speaker"
+
+	"github.com/swensone/gorito/synth"
+)
+
+const (
+	SAMPLE_RATE = beep.SampleRate(48000)
+	// BEEP_VOLUME is the level of the beeper, in powers of 2 below full scale
+	BEEP_VOLUME = -10
 )
 
+// Audio plays the emulator's sound on the speaker. The sound is generated by a synth.Synth, the same as recordings.
 type Audio struct {
-	streamer  *effects.Volume
-	frequency float64
-	pattern   [16]uint8
+	synth *synth.Synth
 }
 
 func New(frequency uint8) *Audio {
 	a := &Audio{
-		pattern:   [16]uint8{},
-		frequency: 440,
-	}
-
-	sr := beep.SampleRate(48000)
-	speaker.Init(sr, 4800)
-
-	square, err := generators.SquareTone(sr, a.frequency)
-	if err != nil {
-		panic(err)
-	}
-	a.streamer = &effects.Volume{
-		Streamer: square,
-		Base:     2,
-		Volume:   -10,
-		Silent:   true,
+		synth: synth.New(int(SAMPLE_RATE), math.Exp2(BEEP_VOLUME)),
 	}
 
-	speaker.Play(a.streamer)
+	speaker.Init(SAMPLE_RATE, 4800)
+	speaker.Play(&streamer{synth: a.synth})
 
 	return a
 }
 
+// locked runs fn on the synth while the speaker isn't streaming from it
+func (a *Audio) locked(fn func(s *synth.Synth)) {
+	speaker.Lock()
+	defer speaker.Unlock()
+	fn(a.synth)
+}
+
 func (a *Audio) Play() {
-	if !a.streamer.Silent {
-		return
-	}
-	a.streamer.Silent = false
-	speaker.Play(a.streamer)
+	a.locked(func(s *synth.Synth) { s.Play() })
 }
 
 func (a *Audio) Stop() {
-	if a.streamer.Silent {
-		return
-	}
-	a.streamer.Silent = true
-	speaker.Play(a.streamer)
+	a.locked(func(s *synth.Synth) { s.Stop() })
 }
 
 func (a *Audio) LoadPattern(p [16]uint8) {
-	copy(a.pattern[:], p[:])
+	a.locked(func(s *synth.Synth) { s.LoadPattern(p) })
 }
 
 func (a *Audio) SetPitch(pitch uint8) {
-	a.frequency = float64(4000 * math.Exp2(float64((pitch-64)/48)))
+	a.locked(func(s *synth.Synth) { s.SetPitch(pitch) })
+}
+
+// PlaySample plays 8 bit unsigned samples recorded at rate, replacing any sample already playing
+func (a *Audio) PlaySample(rate int, samples []uint8, loop bool) {
+	a.locked(func(s *synth.Synth) { s.PlaySample(rate, samples, loop) })
+}
+
+// StopSample stops the sample started by PlaySample
+func (a *Audio) StopSample() {
+	a.locked(func(s *synth.Synth) { s.StopSample() })
 }
 
 func (a *Audio) Close() {
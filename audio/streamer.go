@@ -0,0 +1,25 @@
+package audio
+
+import "github.com/swensone/gorito/synth"
+
+// streamer plays a synth's mono output on both channels
+type streamer struct {
+	synth *synth.Synth
+	buf   []float64
+}
+
+func (s *streamer) Stream(samples [][2]float64) (int, bool) {
+	if cap(s.buf) < len(samples) {
+		s.buf = make([]float64, len(samples))
+	}
+	buf := s.buf[:len(samples)]
+	s.synth.Render(buf)
+	for i, v := range buf {
+		samples[i] = [2]float64{v, v}
+	}
+	return len(samples), true
+}
+
+func (s *streamer) Err() error {
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/swensone/gorito/asm"
+	"github.com/swensone/gorito/types"
+)
+
+// asmCommand assembles octo source: gorito asm [flags] game.8o
+func asmCommand(args []string) int {
+	f := pflag.NewFlagSet("asm", pflag.ContinueOnError)
+	f.Usage = func() {
+		fmt.Println("usage: gorito asm [flags] source.8o")
+		fmt.Println(f.FlagUsages())
+	}
+	output := f.StringP("output", "o", "", "rom file, defaults to the source file with a .ch8 extension")
+	symbols := f.StringP("symbols", "s", "", "symbol table file, defaults to the rom file with a .sym extension, set to an empty string to skip")
+	modeName := f.StringP("mode", "m", "", fmt.Sprintf("mode the rom is for, which sets its start address. defaults to the rom's extension or superchip, possible values: %s", strings.Join(types.SupportedModes(), ", ")))
+	if err := f.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+		slog.Error("error parsing flags", slog.Any("error", err))
+		return 2
+	}
+	if f.NArg() != 1 {
+		f.Usage()
+		return 2
+	}
+	source := f.Arg(0)
+
+	if *output == "" {
+		*output = replaceExt(source, ".ch8")
+	}
+	if !f.Changed("symbols") {
+		*symbols = replaceExt(*output, ".sym")
+	}
+	mode := modeForROM(*output, types.MODE_SUPERCHIP)
+	if *modeName != "" {
+		m, err := types.ModeFromString(*modeName)
+		if err != nil {
+			slog.Error("invalid mode", slog.Any("error", err))
+			return 2
+		}
+		mode = m
+	}
+
+	src, err := os.ReadFile(source)
+	if err != nil {
+		slog.Error("failed to read source", slog.Any("error", err))
+		return 1
+	}
+	program, err := asm.Assemble(source, src, types.PlatformForMode(mode).Start)
+	if err != nil {
+		slog.Error("failed to assemble", slog.Any("error", err))
+		return 1
+	}
+
+	if err := os.WriteFile(*output, program.ROM, 0o644); err != nil {
+		slog.Error("failed to write rom", slog.Any("error", err))
+		return 1
+	}
+	if *symbols != "" {
+		out, err := os.Create(*symbols)
+		if err != nil {
+			slog.Error("failed to create symbol table", slog.Any("error", err))
+			return 1
+		}
+		defer out.Close()
+		if err := program.WriteSymbols(out); err != nil {
+			slog.Error("failed to write symbol table", slog.Any("error", err))
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/swensone/gorito/debugger"
+	"github.com/swensone/gorito/emulator"
+	"github.com/swensone/gorito/tui"
+	"github.com/swensone/gorito/types"
+)
+
+// debugCommand runs a rom in the terminal debugger: gorito debug [flags] game.ch8
+func debugCommand(args []string) int {
+	f := pflag.NewFlagSet("debug", pflag.ContinueOnError)
+	f.Usage = func() {
+		fmt.Println("usage: gorito debug [flags] rom")
+		fmt.Println(f.FlagUsages())
+	}
+	modeName := f.StringP("mode", "m", "", fmt.Sprintf("emulator mode, defaults to the rom's extension or superchip, possible values: %s", strings.Join(types.SupportedModes(), ", ")))
+	speed := f.Uint32P("speed", "s", 600, "speed in cycles per second")
+	breaks := f.StringSliceP("break", "b", nil, "addresses to break at, in hex")
+	classes := f.StringSlice("break-on", nil, "instruction classes to break on: draw, key_wait, timer")
+	logFile := f.String("log", "", "file to write the log to, the terminal is taken by the debugger")
+	if err := f.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+		slog.Error("error parsing flags", slog.Any("error", err))
+		return 2
+	}
+	if f.NArg() != 1 {
+		f.Usage()
+		return 2
+	}
+	rom := f.Arg(0)
+
+	mode := modeForROM(rom, types.MODE_SUPERCHIP)
+	if *modeName != "" {
+		m, err := types.ModeFromString(*modeName)
+		if err != nil {
+			slog.Error("invalid mode", slog.Any("error", err))
+			return 2
+		}
+		mode = m
+	}
+
+	var logOut io.Writer = io.Discard
+	if *logFile != "" {
+		out, err := os.Create(*logFile)
+		if err != nil {
+			slog.Error("failed to create log file", slog.Any("error", err))
+			return 1
+		}
+		defer out.Close()
+		logOut = out
+	}
+	log := slog.New(slog.NewTextHandler(logOut, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	bg := types.Color{R: 0x08, G: 0x08, B: 0x08}
+	display := tui.NewDisplay()
+	input := tui.NewInput()
+	emu, err := emulator.New(
+		emulator.EmulatorConfig{
+			Mode:   mode,
+			Quirks: types.QuirksForMode(mode),
+			Speed:  *speed,
+			ColorMap: map[uint8]types.Color{
+				0: bg,
+				1: {R: 0x1e, G: 0x81, B: 0xb0},
+				2: {R: 0xea, G: 0xb6, B: 0x76},
+				3: {R: 0x87, G: 0x3e, B: 0x23},
+			},
+			// break into the debugger on stack faults rather than ending the session
+			Faults: types.FaultPolicies{
+				types.FAULT_STACK_OVERFLOW:  types.FAULT_BREAK,
+				types.FAULT_STACK_UNDERFLOW: types.FAULT_BREAK,
+			},
+		},
+		display,
+		nil,
+		input,
+		log,
+	)
+	if err != nil {
+		slog.Error("failure while creating cpu emulator", "error", err)
+		return 1
+	}
+
+	// start stopped on the first instruction, with any breakpoints from the command line
+	dbg := debugger.New(emu)
+	dbg.Pause()
+	for _, b := range *breaks {
+		addr, err := strconv.ParseUint(strings.TrimPrefix(b, "0x"), 16, 16)
+		if err != nil {
+			slog.Error("invalid breakpoint address", "address", b)
+			return 2
+		}
+		dbg.AddBreakpoint(uint16(addr), nil)
+	}
+	for _, c := range *classes {
+		class, err := debugger.ClassFromString(c)
+		if err != nil {
+			slog.Error("invalid instruction class", slog.Any("error", err))
+			return 2
+		}
+		dbg.BreakOnClass(class, true)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- emu.Run(rom)
+	}()
+
+	if err := tui.New(emu, dbg, display, input, mode, bg).Run(done); err != nil {
+		slog.Error("error returned from cpu run", "error", err)
+		return 1
+	}
+	return 0
+}
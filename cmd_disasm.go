@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/swensone/gorito/disasm"
+	"github.com/swensone/gorito/types"
+)
+
+// disasmCommand disassembles a rom: gorito disasm [flags] game.ch8
+func disasmCommand(args []string) int {
+	f := pflag.NewFlagSet("disasm", pflag.ContinueOnError)
+	f.Usage = func() {
+		fmt.Println("usage: gorito disasm [flags] rom")
+		fmt.Println(f.FlagUsages())
+	}
+	modeName := f.StringP("mode", "m", "", fmt.Sprintf("emulator mode, defaults to the rom's extension or superchip, possible values: %s", strings.Join(types.SupportedModes(), ", ")))
+	octo := f.Bool("octo", false, "write octo source that reassembles to the rom instead of a listing")
+	output := f.StringP("output", "o", "", "output file, defaults to stdout")
+	if err := f.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+		slog.Error("error parsing flags", slog.Any("error", err))
+		return 2
+	}
+	if f.NArg() != 1 {
+		f.Usage()
+		return 2
+	}
+	rom := f.Arg(0)
+
+	mode := modeForROM(rom, types.MODE_SUPERCHIP)
+	if *modeName != "" {
+		m, err := types.ModeFromString(*modeName)
+		if err != nil {
+			slog.Error("invalid mode", slog.Any("error", err))
+			return 2
+		}
+		mode = m
+	}
+
+	data, err := os.ReadFile(rom)
+	if err != nil {
+		slog.Error("failed to read rom", slog.Any("error", err))
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		out, err := os.Create(*output)
+		if err != nil {
+			slog.Error("failed to create output file", slog.Any("error", err))
+			return 1
+		}
+		defer out.Close()
+		w = out
+	}
+
+	d := disasm.New(mode)
+	if *octo {
+		err = d.Octo(w, data)
+	} else {
+		err = d.Listing(w, data)
+	}
+	if err != nil {
+		slog.Error("failed to disassemble rom", slog.Any("error", err))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+
+	"github.com/swensone/gorito/romtest"
+)
+
+// testCommand runs rom regression specs: gorito test [flags] dir|spec...
+func testCommand(args []string) int {
+	f := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	f.Usage = func() {
+		fmt.Println("usage: gorito test [flags] dir|spec...")
+		fmt.Println(f.FlagUsages())
+	}
+	update := f.Bool("update", false, "write the golden screens instead of comparing with them")
+	verbose := f.BoolP("verbose", "v", false, "show the emulator's log")
+	if err := f.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+		slog.Error("error parsing flags", slog.Any("error", err))
+		return 2
+	}
+	if f.NArg() == 0 {
+		f.Usage()
+		return 2
+	}
+
+	// specs are the yaml files in the directories given, or the files themselves
+	var specs []string
+	for _, arg := range f.Args() {
+		if err := filepath.WalkDir(arg, func(fpath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			// a spec named on the command line is run whatever it's called
+			if ext := filepath.Ext(fpath); fpath == arg || ext == ".yaml" || ext == ".yml" {
+				specs = append(specs, fpath)
+			}
+			return nil
+		}); err != nil {
+			slog.Error("failed to find specs", slog.Any("error", err))
+			return 1
+		}
+	}
+
+	opts := romtest.Options{Update: *update}
+	if *verbose {
+		opts.Log = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+
+	passed, failed := 0, 0
+	for _, fpath := range specs {
+		var r *romtest.Result
+		s, err := romtest.Load(fpath)
+		if err != nil {
+			r = &romtest.Result{Name: fpath, Err: err}
+		} else {
+			r = romtest.Run(s, opts)
+		}
+		printResult(os.Stdout, fpath, r)
+		if r.Passed() {
+			passed++
+		} else {
+			failed++
+		}
+	}
+
+	fmt.Printf("%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func printResult(w io.Writer, fpath string, r *romtest.Result) {
+	if r.Passed() {
+		fmt.Fprintf(w, "PASS  %s (%s, %d frames)\n", r.Name, fpath, r.Frames)
+		return
+	}
+
+	fmt.Fprintf(w, "FAIL  %s (%s, %d frames)\n", r.Name, fpath, r.Frames)
+	if r.Err != nil {
+		fmt.Fprintf(w, "      %v\n", r.Err)
+	}
+	for _, failure := range r.Failures {
+		fmt.Fprintf(w, "      %s\n", failure)
+	}
+}
@@ -22,38 +22,70 @@ import (
 )
 
 type Config struct {
-	Savefile   string      `yaml:"savefile,omitempty"`
-	Level      slog.Level  `yaml:"level,omitempty"`
-	Opcodes    bool        `yaml:"opcodes,omitempty"`
-	Mode       types.Mode  `yaml:"mode,omitempty"`
-	Speed      uint32      `yaml:"speed,omitempty"`
-	ROM        string      `yaml:"rom,omitempty"`
-	Width      int32       `yaml:"width,omitempty"`
-	Height     int32       `yaml:"height,omitempty"`
-	Fullscreen bool        `yaml:"fullscreen,omitempty"`
-	BG         types.Color `yaml:"bg,omitempty"`
-	FG1        types.Color `yaml:"fg1,omitempty"`
-	FG2        types.Color `yaml:"fg2,omitempty"`
-	FG3        types.Color `yaml:"fg3,omitempty"`
+	Savefile        string               `yaml:"savefile,omitempty"`
+	Level           slog.Level           `yaml:"level,omitempty"`
+	Opcodes         bool                 `yaml:"opcodes,omitempty"`
+	Mode            types.Mode           `yaml:"mode,omitempty"`
+	Speed           uint32               `yaml:"speed,omitempty"`
+	ROM             string               `yaml:"rom,omitempty"`
+	Width           int32                `yaml:"width,omitempty"`
+	Height          int32                `yaml:"height,omitempty"`
+	Fullscreen      bool                 `yaml:"fullscreen,omitempty"`
+	BG              types.Color          `yaml:"bg,omitempty"`
+	FG1             types.Color          `yaml:"fg1,omitempty"`
+	FG2             types.Color          `yaml:"fg2,omitempty"`
+	FG3             types.Color          `yaml:"fg3,omitempty"`
+	Rewind          uint32               `yaml:"rewind,omitempty"`
+	Quirks          types.QuirkOverrides `yaml:"quirks,omitempty"`
+	Faults          types.FaultPolicies  `yaml:"faults,omitempty"`
+	GDB             string               `yaml:"gdb,omitempty"`
+	API             string               `yaml:"api,omitempty"`
+	MovieRecord     string               `yaml:"movie_record,omitempty" json:"movie_record,omitempty"`
+	MoviePlay       string               `yaml:"movie_play,omitempty" json:"movie_play,omitempty"`
+	Seed            int64                `yaml:"seed,omitempty"`
+	RNG             string               `yaml:"rng,omitempty"`
+	VIP             string               `yaml:"vip,omitempty"`
+	IPF             uint32               `yaml:"ipf,omitempty"`
+	VSync           bool                 `yaml:"vsync,omitempty"`
+	Turbo           float64              `yaml:"turbo,omitempty"`
+	SlowMotion      float64              `yaml:"slow_motion,omitempty" json:"slow_motion,omitempty"`
+	Headless        bool                 `yaml:"headless,omitempty"`
+	Frames          uint32               `yaml:"frames,omitempty"`
+	PNG             string               `yaml:"png,omitempty"`
+	Text            string               `yaml:"text,omitempty"`
+	Dump            string               `yaml:"dump,omitempty"`
+	Screenshots     string               `yaml:"screenshots,omitempty"`
+	ScreenshotScale int                  `yaml:"screenshot_scale,omitempty" json:"screenshot_scale,omitempty"`
+	Record          string               `yaml:"record,omitempty"`
+	RecordFormat    string               `yaml:"record_format,omitempty" json:"record_format,omitempty"`
+	WAV             string               `yaml:"wav,omitempty"`
 }
 
-func Parse() (*Config, error) {
+// Parse loads the config from the defaults, the config file and the command line in args. A rom may be given as the
+// only argument instead of with --rom.
+func Parse(args []string) (*Config, error) {
 	k := koanf.New(".")
 	// set up some decent defaults
 	k.Load(confmap.Provider(map[string]interface{}{
-		"savefile":   "~/.config/gorito-storage.json",
-		"config":     "~/.config/gorito.yaml",
-		"level":      "INFO",
-		"opcodes":    false,
-		"mode":       "superchip",
-		"speed":      600,
-		"width":      1280,
-		"height":     640,
-		"fullscreen": false,
-		"bg":         "080808",
-		"fg1":        "1e81b0",
-		"fg2":        "eab676",
-		"fg3":        "873e23",
+		"savefile":         "~/.config/gorito-storage.json",
+		"config":           "~/.config/gorito.yaml",
+		"level":            "INFO",
+		"opcodes":          false,
+		"mode":             "superchip",
+		"speed":            600,
+		"width":            1280,
+		"height":           640,
+		"fullscreen":       false,
+		"bg":               "080808",
+		"fg1":              "1e81b0",
+		"fg2":              "eab676",
+		"fg3":              "873e23",
+		"rewind":           600,
+		"rng":              "pcg",
+		"turbo":            4,
+		"slow_motion":      0.25,
+		"screenshot_scale": 1,
+		"record_format":    "gif",
 	}, "."), nil)
 
 	// Parse command line flags
@@ -69,7 +101,11 @@ func Parse() (*Config, error) {
 	f.BoolP("opcodes", "o", false, "log opcodes, extremely noisy")
 	f.StringP("mode", "m", "", fmt.Sprintf("emulator mode, possible values: %s", strings.Join(types.SupportedModes(), ", ")))
 	f.Uint16P("speed", "s", 0, "speed in cycles per seond")
-	f.StringP("rom", "r", "", "path to the rom you want to load")
+	f.Uint32("ipf", 0, "instructions per frame, overrides speed when set")
+	f.Bool("vsync", false, "pace frames with the display's refresh instead of the clock")
+	f.Float64("turbo", 0, "speed multiplier while tab is held")
+	f.Float64("slow-motion", 0, "speed multiplier while backquote is held")
+	f.StringP("rom", "r", "", "path to the rom you want to load, octo source with a .8o extension is assembled and reloaded on save")
 	f.IntP("width", "x", 0, "window width")
 	f.IntP("height", "y", 0, "window height")
 	f.BoolP("fullscreen", "f", false, "display full screen")
@@ -77,12 +113,50 @@ func Parse() (*Config, error) {
 	f.String("fg1", "", "foreground 1 color in hex")
 	f.String("fg2", "", "foreground 2 color in hex, only used in xo-chip")
 	f.String("fg3", "", "foreground 3 color in hex, only used in xo-chip")
-	if err := f.Parse(os.Args[1:]); err != nil {
+	f.Uint32("rewind", 0, "number of frames kept for rewinding with backspace, 0 to disable")
+	f.Bool("quirk-shift-vy", false, "8XY6/8XYE shift VY into VX instead of shifting VX in place")
+	f.Bool("quirk-mem-increment-i", false, "FX55/FX65 leave I pointing past the last register")
+	f.Bool("quirk-mem-increment-x", false, "FX55/FX65 increment I by X rather than X+1")
+	f.Bool("quirk-vf-reset", false, "8XY1/8XY2/8XY3 reset VF to 0")
+	f.Bool("quirk-jump-vx", false, "BNNN works as BXNN, jumping to XNN plus VX")
+	f.Bool("quirk-display-wait", false, "DXYN waits for the vertical blank before drawing in low resolution")
+	f.Bool("quirk-sprite-wrap", false, "sprites wrap around the screen edges instead of being clipped")
+	f.Bool("quirk-lores-16x16", false, "DXY0 draws a 16x16 sprite in low resolution mode")
+	f.Bool("quirk-lores-8x16", false, "DXY0 draws an 8x16 sprite in low resolution mode")
+	f.Bool("quirk-key-wait-release", false, "FX0A completes when the key is released instead of pressed")
+	f.Bool("quirk-half-pixel-scroll", false, "scrolling in low resolution moves by high resolution pixels")
+	f.Bool("quirk-collision-rows", false, "VF counts the colliding or clipped rows in high resolution")
+	f.String("gdb", "", "address to listen for gdb on, e.g. localhost:1234. the rom waits for gdb to attach and continue")
+	f.String("api", "", "address to serve the http control api on, e.g. localhost:8080")
+	f.String("movie-record", "", "record the keypad to a movie file")
+	f.String("movie-play", "", "play the keypad back from a movie file, using the mode, quirks and speed it was recorded with")
+	f.Int64("seed", 0, "seed for the random numbers from CXNN, picked from the clock when 0")
+	f.String("rng", "", "random number generator for CXNN, pcg or vip")
+	f.String("vip", "", "image of the 512 byte cosmac vip chip-8 interpreter, needed by the vip random number generator")
+	f.Bool("headless", false, "run without a display, audio or keyboard as fast as possible, for ci")
+	f.Uint32("frames", 0, "number of frames to run before stopping, 0 to run until the program exits")
+	f.String("png", "", "write the screen to a png file when the run stops")
+	f.String("text", "", "write the screen as text when the run stops, - for stdout")
+	f.String("dump", "", "write the registers and memory as json when the run stops, - for stdout")
+	f.String("screenshots", "", "directory screenshots (F12) and recordings (F11) are saved to, next to the save file by default")
+	f.Int("screenshot-scale", 0, "scale screenshots and recordings up by this factor")
+	f.String("record", "", "record the screen to a .gif, .png (apng) or .y4m file and the sound to a .wav next to it")
+	f.String("record-format", "", "format of recordings started with F11, gif, apng or y4m")
+	f.String("wav", "", "capture the sound to a 48khz wav file, also when headless")
+	f.StringToString("fault", nil, "fault policies as kind=policy, kinds: stack_overflow, stack_underflow, memory_bounds, unknown_opcode; policies: halt, continue, break")
+	if err := f.Parse(args); err != nil {
 		return nil, err
 	}
+	if f.NArg() > 1 {
+		return nil, fmt.Errorf("expected a single rom, got %s", strings.Join(f.Args(), " "))
+	}
 
 	// clean up the yaml config file path and load
-	configFile := path.Clean(k.String("config"))
+	configFile := k.String("config")
+	if f.Changed("config") {
+		configFile, _ = f.GetString("config")
+	}
+	configFile = path.Clean(configFile)
 	if strings.HasPrefix(configFile, "~/") {
 		home, _ := os.UserHomeDir()
 		configFile = filepath.Join(home, configFile[2:])
@@ -95,11 +169,36 @@ func Parse() (*Config, error) {
 		}
 	}
 
-	// load flags and merge into default
-	if err := k.Load(posflag.Provider(f, ".", k), nil); err != nil {
+	// load flags and merge into default. quirk and fault flags map onto the quirks and faults sections of the config
+	// file, and are only loaded when set so that unset quirks keep the mode's preset. dashes in other flags become the
+	// underscores used in the config file.
+	if err := k.Load(posflag.ProviderWithFlag(f, ".", k, func(flag *pflag.Flag) (string, interface{}) {
+		if flag.Name == "fault" {
+			if !flag.Changed {
+				return "", nil
+			}
+			faults := map[string]interface{}{}
+			policies, _ := f.GetStringToString(flag.Name)
+			for kind, policy := range policies {
+				faults[kind] = policy
+			}
+			return "faults", faults
+		}
+		if quirk, ok := strings.CutPrefix(flag.Name, "quirk-"); ok {
+			if !flag.Changed {
+				return "", nil
+			}
+			return "quirks." + strings.ReplaceAll(quirk, "-", "_"), posflag.FlagVal(f, flag)
+		}
+		return strings.ReplaceAll(flag.Name, "-", "_"), posflag.FlagVal(f, flag)
+	}), nil); err != nil {
 		return nil, err
 	}
 
+	if f.NArg() == 1 {
+		k.Set("rom", f.Arg(0))
+	}
+
 	data, err := k.Marshal(kjson.Parser())
 	if err != nil {
 		return nil, err
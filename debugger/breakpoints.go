@@ -0,0 +1,172 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/emulator"
+)
+
+// REG_I selects the I register in a condition, 0-15 select V0-VF
+const REG_I = 16
+
+// Condition compares a register against a value, e.g. v3 == 5 or i >= 0x300
+type Condition struct {
+	Register int
+	Op       string
+	Value    uint32
+}
+
+var compare = map[string]func(a, b uint32) bool{
+	"==": func(a, b uint32) bool { return a == b },
+	"!=": func(a, b uint32) bool { return a != b },
+	"<":  func(a, b uint32) bool { return a < b },
+	"<=": func(a, b uint32) bool { return a <= b },
+	">":  func(a, b uint32) bool { return a > b },
+	">=": func(a, b uint32) bool { return a >= b },
+}
+
+// ParseCondition parses a condition like "v3 == 5", "vA>=0x10" or "i != 0x300"
+func ParseCondition(s string) (*Condition, error) {
+	s = strings.ReplaceAll(s, " ", "")
+	opStart := strings.IndexAny(s, "=!<>")
+	if opStart < 0 {
+		return nil, errors.Newf("condition %q has no comparison", s)
+	}
+	opEnd := opStart
+	for opEnd < len(s) && strings.IndexByte("=!<>", s[opEnd]) >= 0 {
+		opEnd++
+	}
+
+	c := &Condition{Op: s[opStart:opEnd]}
+	if _, ok := compare[c.Op]; !ok {
+		return nil, errors.Newf("unknown comparison %s", c.Op)
+	}
+
+	reg := strings.ToLower(s[:opStart])
+	switch {
+	case reg == "i":
+		c.Register = REG_I
+	case len(reg) == 2 && reg[0] == 'v':
+		r, err := strconv.ParseUint(reg[1:], 16, 8)
+		if err != nil {
+			return nil, errors.Newf("unknown register %s", reg)
+		}
+		c.Register = int(r)
+	default:
+		return nil, errors.Newf("unknown register %s", reg)
+	}
+
+	v, err := strconv.ParseUint(s[opEnd:], 0, 32)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid value %s", s[opEnd:])
+	}
+	c.Value = uint32(v)
+	return c, nil
+}
+
+func (c *Condition) String() string {
+	reg := "i"
+	if c.Register != REG_I {
+		reg = fmt.Sprintf("v%X", c.Register)
+	}
+	return fmt.Sprintf("%s %s 0x%X", reg, c.Op, c.Value)
+}
+
+// Eval tests the condition against the emulator's registers
+func (c *Condition) Eval(e *emulator.Emulator) bool {
+	v := e.Index()
+	if c.Register != REG_I {
+		v = uint32(e.Registers()[c.Register&0xF])
+	}
+	return compare[c.Op](v, c.Value)
+}
+
+// Breakpoint stops before the instruction at Addr runs, if Cond is nil or true
+type Breakpoint struct {
+	ID   int
+	Addr uint16
+	Cond *Condition
+	Hits int
+}
+
+// WatchKind selects which memory accesses a watchpoint stops on
+type WatchKind int
+
+const (
+	WATCH_READ   WatchKind = 1 << iota // reads by an instruction, instruction fetches don't count
+	WATCH_WRITE                        // writes
+	WATCH_ACCESS = WATCH_READ | WATCH_WRITE
+)
+
+var watchkindmap = map[WatchKind]string{
+	WATCH_READ:   "read",
+	WATCH_WRITE:  "write",
+	WATCH_ACCESS: "access",
+}
+
+func (k WatchKind) String() string {
+	return watchkindmap[k]
+}
+
+// Watchpoint stops after an instruction accesses Len bytes of memory from Addr
+type Watchpoint struct {
+	ID   int
+	Addr uint32
+	Len  int
+	Kind WatchKind
+	Hits int
+}
+
+func (w *Watchpoint) matches(addr uint32, write bool) bool {
+	kind := WATCH_READ
+	if write {
+		kind = WATCH_WRITE
+	}
+	return w.Kind&kind != 0 && addr >= w.Addr && addr < w.Addr+uint32(w.Len)
+}
+
+// Class groups instructions that can be broken on as a whole
+type Class int
+
+const (
+	CLASS_DRAW     Class = iota // DXYN sprite draws
+	CLASS_KEY_WAIT              // FX0A waiting for a key
+	CLASS_TIMER                 // FX07, FX15 and FX18 reading or setting the timers
+)
+
+var classmap = map[Class]string{
+	CLASS_DRAW:     "draw",
+	CLASS_KEY_WAIT: "key_wait",
+	CLASS_TIMER:    "timer",
+}
+
+func (c Class) String() string {
+	return classmap[c]
+}
+
+// ClassFromString returns the class with the given name
+func ClassFromString(s string) (Class, error) {
+	for class, name := range classmap {
+		if name == s {
+			return class, nil
+		}
+	}
+	return 0, errors.Newf("unknown instruction class: %s", s)
+}
+
+// classOf returns the class of an instruction, if it has one
+func classOf(op emulator.Op) (Class, bool) {
+	switch op {
+	case emulator.OP_DRW:
+		return CLASS_DRAW, true
+	case emulator.OP_LD_KEY:
+		return CLASS_KEY_WAIT, true
+	case emulator.OP_LD_VX_DT, emulator.OP_LD_DT, emulator.OP_LD_ST:
+		return CLASS_TIMER, true
+	}
+	return 0, false
+}
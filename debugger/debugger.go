@@ -0,0 +1,445 @@
+package debugger
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/emulator"
+)
+
+// ErrRunning is returned when stepping while the emulator is running
+var ErrRunning = errors.New("emulator is running, pause it first")
+
+// ErrNotStepping is returned when a change can't be made because the emulator stopped checking in with the debugger,
+// for example when it has finished
+var ErrNotStepping = errors.New("emulator isn't stepping")
+
+// MODIFY_TIMEOUT is how long Modify waits for the emulator to check in
+const MODIFY_TIMEOUT = time.Second
+
+// StopReason says why execution stopped
+type StopReason int
+
+const (
+	STOP_PAUSE      StopReason = iota // Pause was called
+	STOP_BREAKPOINT                   // a breakpoint was hit, Stop.ID is the breakpoint
+	STOP_WATCHPOINT                   // a watchpoint was hit, Stop.ID is the watchpoint and Stop.Addr the access
+	STOP_CLASS                        // an instruction of a class being broken on is next, see Stop.Class
+	STOP_STEP                         // a step or run to address finished
+	STOP_FAULT                        // a fault with the break policy was raised, see Stop.Fault
+)
+
+var stopreasonmap = map[StopReason]string{
+	STOP_PAUSE:      "pause",
+	STOP_BREAKPOINT: "breakpoint",
+	STOP_WATCHPOINT: "watchpoint",
+	STOP_CLASS:      "class",
+	STOP_STEP:       "step",
+	STOP_FAULT:      "fault",
+}
+
+func (r StopReason) String() string {
+	return stopreasonmap[r]
+}
+
+// Stop describes where and why execution stopped. Execution is always stopped before the instruction at PC runs.
+type Stop struct {
+	Reason StopReason
+	PC     uint16
+	ID     int
+	Addr   uint32
+	Write  bool
+	Class  Class
+	Fault  *emulator.Fault
+}
+
+// step modes, for resuming until a condition other than a breakpoint
+type stepMode int
+
+const (
+	stepNone stepMode = iota
+	stepInto          // stop before the next instruction
+	stepOver          // stop when pc reaches target with the stack back at sp
+	stepOut           // stop once the stack is shallower than sp
+	runTo             // stop when pc reaches target
+)
+
+// Debugger controls an emulator's execution with breakpoints, watchpoints and stepping. Its methods are safe to call
+// from any goroutine, while the emulator runs in its own. Emulator state should only be inspected or changed while
+// stopped, waiting for a Stop from Stops after Pause.
+type Debugger struct {
+	emu *emulator.Emulator
+
+	mu          sync.Mutex
+	nextID      int
+	breakpoints map[int]*Breakpoint
+	byAddr      map[uint16][]*Breakpoint
+	watchpoints map[int]*Watchpoint
+	classes     map[Class]bool
+
+	stopped bool
+	stop    Stop
+	stopIn  emulator.Instruction
+	pending *Stop // stop taken before the next instruction, from a watchpoint, fault or pause
+	resumed bool  // the instruction at the stop runs once without breaking again
+	step    stepMode
+	target  uint16
+	sp      uint8
+
+	edits []edit
+
+	stops chan Stop
+}
+
+// edit is a change to the emulator's state, made from the emulator's goroutine
+type edit struct {
+	fn   func()
+	done chan struct{}
+}
+
+// New creates a debugger and attaches it to the emulator. It should be called before the emulator starts running.
+func New(emu *emulator.Emulator) *Debugger {
+	d := &Debugger{
+		emu:         emu,
+		nextID:      1,
+		breakpoints: map[int]*Breakpoint{},
+		byAddr:      map[uint16][]*Breakpoint{},
+		watchpoints: map[int]*Watchpoint{},
+		classes:     map[Class]bool{},
+		stops:       make(chan Stop, 16),
+	}
+	emu.SetDebugger(d)
+	return d
+}
+
+// Stops delivers every stop as it happens. Stops are dropped if nothing reads them.
+func (d *Debugger) Stops() <-chan Stop {
+	return d.stops
+}
+
+// Stopped returns the current stop, and false if the emulator is running
+func (d *Debugger) Stopped() (Stop, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stop, d.stopped
+}
+
+// Break implements emulator.Debugger, it's called before every instruction
+func (d *Debugger) Break(in emulator.Instruction) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pc := d.emu.PC()
+	if d.stopped {
+		// pick up any changes to the pc since the last check in, then make the queued ones
+		d.stop.PC, d.stopIn = pc, in
+		for _, ed := range d.edits {
+			ed.fn()
+			close(ed.done)
+		}
+		d.edits = nil
+		return true
+	}
+	if d.pending != nil {
+		s := *d.pending
+		d.pending = nil
+		s.PC = pc
+		d.halt(s, in)
+		return true
+	}
+	if d.resumed {
+		d.resumed = false
+		return false
+	}
+
+	switch d.step {
+	case stepInto:
+		d.halt(Stop{Reason: STOP_STEP, PC: pc}, in)
+		return true
+	case stepOver:
+		if pc == d.target && d.emu.SP() == d.sp {
+			d.halt(Stop{Reason: STOP_STEP, PC: pc}, in)
+			return true
+		}
+	case stepOut:
+		if d.emu.SP() < d.sp {
+			d.halt(Stop{Reason: STOP_STEP, PC: pc}, in)
+			return true
+		}
+	case runTo:
+		if pc == d.target {
+			d.halt(Stop{Reason: STOP_STEP, PC: pc}, in)
+			return true
+		}
+	}
+
+	for _, bp := range d.byAddr[pc] {
+		if bp.Cond == nil || bp.Cond.Eval(d.emu) {
+			bp.Hits++
+			d.halt(Stop{Reason: STOP_BREAKPOINT, PC: pc, ID: bp.ID}, in)
+			return true
+		}
+	}
+
+	if class, ok := classOf(in.Op); ok && d.classes[class] {
+		d.halt(Stop{Reason: STOP_CLASS, PC: pc, Class: class}, in)
+		return true
+	}
+
+	return false
+}
+
+// MemoryAccess implements emulator.Debugger, it checks the watchpoints for every memory access
+func (d *Debugger) MemoryAccess(addr uint32, write bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.pending != nil {
+		return
+	}
+	for _, wp := range d.watchpoints {
+		if wp.matches(addr, write) {
+			wp.Hits++
+			d.pending = &Stop{Reason: STOP_WATCHPOINT, ID: wp.ID, Addr: addr, Write: write}
+			return
+		}
+	}
+}
+
+// Fault implements emulator.Debugger, stopping before the instruction after the fault
+func (d *Debugger) Fault(f *emulator.Fault) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending = &Stop{Reason: STOP_FAULT, Fault: f}
+}
+
+// halt stops execution, the lock must be held
+func (d *Debugger) halt(s Stop, in emulator.Instruction) {
+	d.stopped = true
+	d.stop = s
+	d.stopIn = in
+	d.step = stepNone
+	select {
+	case d.stops <- s:
+	default:
+	}
+}
+
+// resume starts execution in a step mode, the lock must be held
+func (d *Debugger) resume(mode stepMode) {
+	d.step = mode
+	if d.stopped {
+		d.stopped = false
+		d.resumed = true
+	}
+}
+
+// Pause stops execution before the next instruction
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.stopped && d.pending == nil {
+		d.pending = &Stop{Reason: STOP_PAUSE}
+	}
+}
+
+// Continue resumes execution until the next breakpoint, watchpoint or class break
+func (d *Debugger) Continue() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resume(stepNone)
+}
+
+// StepInto runs a single instruction
+func (d *Debugger) StepInto() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.stopped {
+		return ErrRunning
+	}
+	d.resume(stepInto)
+	return nil
+}
+
+// StepOver runs a single instruction, running the whole subroutine when it's a 2NNN call
+func (d *Debugger) StepOver() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.stopped {
+		return ErrRunning
+	}
+	if d.stopIn.Op != emulator.OP_CALL {
+		d.resume(stepInto)
+		return nil
+	}
+	d.target = d.stop.PC + d.stopIn.Length
+	d.sp = d.emu.SP()
+	d.resume(stepOver)
+	return nil
+}
+
+// StepOut runs until the current subroutine returns with 00EE
+func (d *Debugger) StepOut() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.stopped {
+		return ErrRunning
+	}
+	d.sp = d.emu.SP()
+	d.resume(stepOut)
+	return nil
+}
+
+// RunTo resumes execution until pc reaches addr, or a breakpoint is hit first
+func (d *Debugger) RunTo(addr uint16) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.target = addr
+	d.resume(runTo)
+}
+
+// Modify runs fn on the emulator's goroutine the next time it checks in while stopped, so registers and memory can be
+// changed without racing it. It waits for fn to run, returning ErrRunning if the emulator isn't stopped. fn must not
+// call the debugger.
+func (d *Debugger) Modify(fn func()) error {
+	d.mu.Lock()
+	if !d.stopped {
+		d.mu.Unlock()
+		return ErrRunning
+	}
+	done := make(chan struct{})
+	d.edits = append(d.edits, edit{fn: fn, done: done})
+	d.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(MODIFY_TIMEOUT):
+	}
+
+	// drop the change rather than making it at some later point, unless it ran while timing out
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.edits {
+		if d.edits[i].done == done {
+			d.edits = append(d.edits[:i], d.edits[i+1:]...)
+			return ErrNotStepping
+		}
+	}
+	return nil
+}
+
+// AddBreakpoint sets a breakpoint at addr, with an optional condition. It returns the breakpoint's id.
+func (d *Debugger) AddBreakpoint(addr uint16, cond *Condition) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bp := &Breakpoint{ID: d.nextID, Addr: addr, Cond: cond}
+	d.nextID++
+	d.breakpoints[bp.ID] = bp
+	d.byAddr[addr] = append(d.byAddr[addr], bp)
+	return bp.ID
+}
+
+// RemoveBreakpoint removes a breakpoint by id, returning false if there isn't one
+func (d *Debugger) RemoveBreakpoint(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bp, ok := d.breakpoints[id]
+	if !ok {
+		return false
+	}
+	delete(d.breakpoints, id)
+
+	bps := d.byAddr[bp.Addr]
+	for i := range bps {
+		if bps[i].ID == id {
+			bps = append(bps[:i], bps[i+1:]...)
+			break
+		}
+	}
+	if len(bps) == 0 {
+		delete(d.byAddr, bp.Addr)
+	} else {
+		d.byAddr[bp.Addr] = bps
+	}
+	return true
+}
+
+// ToggleBreakpoint removes every breakpoint at addr, or adds an unconditional one if there are none. It returns true
+// if a breakpoint was added.
+func (d *Debugger) ToggleBreakpoint(addr uint16) bool {
+	d.mu.Lock()
+	bps := append([]*Breakpoint{}, d.byAddr[addr]...)
+	d.mu.Unlock()
+
+	if len(bps) == 0 {
+		d.AddBreakpoint(addr, nil)
+		return true
+	}
+	for _, bp := range bps {
+		d.RemoveBreakpoint(bp.ID)
+	}
+	return false
+}
+
+// Breakpoints returns a copy of the breakpoints, ordered by id
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var bps []Breakpoint
+	for _, bp := range d.breakpoints {
+		bps = append(bps, *bp)
+	}
+	sort.Slice(bps, func(i, j int) bool { return bps[i].ID < bps[j].ID })
+	return bps
+}
+
+// AddWatchpoint watches length bytes of memory from addr, returning the watchpoint's id
+func (d *Debugger) AddWatchpoint(addr uint32, length int, kind WatchKind) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	wp := &Watchpoint{ID: d.nextID, Addr: addr, Len: max(length, 1), Kind: kind}
+	d.nextID++
+	d.watchpoints[wp.ID] = wp
+	return wp.ID
+}
+
+// RemoveWatchpoint removes a watchpoint by id, returning false if there isn't one
+func (d *Debugger) RemoveWatchpoint(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.watchpoints[id]; !ok {
+		return false
+	}
+	delete(d.watchpoints, id)
+	return true
+}
+
+// Watchpoints returns a copy of the watchpoints, ordered by id
+func (d *Debugger) Watchpoints() []Watchpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var wps []Watchpoint
+	for _, wp := range d.watchpoints {
+		wps = append(wps, *wp)
+	}
+	sort.Slice(wps, func(i, j int) bool { return wps[i].ID < wps[j].ID })
+	return wps
+}
+
+// BreakOnClass enables or disables stopping before every instruction in a class
+func (d *Debugger) BreakOnClass(class Class, enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.classes[class] = enabled
+}
+
+// BreaksOnClass returns true if execution stops before instructions in a class
+func (d *Debugger) BreaksOnClass(class Class) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.classes[class]
+}
@@ -0,0 +1,223 @@
+package debugger
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/emulator"
+	"github.com/swensone/gorito/types"
+)
+
+// testROM calls a subroutine that increments v0 and saves it to 0x300, then sets the delay timer and loops
+var testROM = []byte{
+	0x60, 0x05, // 200: v0 := 5
+	0xA3, 0x00, // 202: i := 0x300
+	0x22, 0x0A, // 204: call 20A
+	0xF0, 0x15, // 206: delay := v0
+	0x12, 0x08, // 208: jump 208
+	0x70, 0x01, // 20A: v0 += 1
+	0xF0, 0x55, // 20C: save v0
+	0x00, 0xEE, // 20E: return
+}
+
+func newTestDebugger(t *testing.T) (*Debugger, *emulator.Emulator) {
+	e, err := emulator.New(emulator.EmulatorConfig{
+		Savefile: filepath.Join(t.TempDir(), "saves.json"),
+		Mode:     types.MODE_CHIP8,
+		Quirks:   types.QuirksForMode(types.MODE_CHIP8),
+		Speed:    600,
+	}, nil, nil, nil, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.LoadROM(testROM); err != nil {
+		t.Fatal(err)
+	}
+	return New(e), e
+}
+
+// run steps the emulator until the debugger stops it, or up to 100 instructions
+func run(t *testing.T, d *Debugger, e *emulator.Emulator) (Stop, bool) {
+	for range 100 {
+		if err := e.Step(); err != nil {
+			t.Fatal(err)
+		}
+		if s, ok := d.Stopped(); ok {
+			return s, true
+		}
+	}
+	return Stop{}, false
+}
+
+func cond(t *testing.T, s string) *Condition {
+	c, err := ParseCondition(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDebugger(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, d *Debugger, e *emulator.Emulator)
+		stopped bool
+		want    Stop
+		v0      uint8
+	}{
+		{
+			"breakpoint",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.AddBreakpoint(0x20A, nil)
+			},
+			true,
+			Stop{Reason: STOP_BREAKPOINT, PC: 0x20A, ID: 1},
+			5,
+		},
+		{
+			"conditional breakpoint",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.AddBreakpoint(0x20C, cond(t, "v0 == 6"))
+			},
+			true,
+			Stop{Reason: STOP_BREAKPOINT, PC: 0x20C, ID: 1},
+			6,
+		},
+		{
+			"conditional breakpoint not hit",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.AddBreakpoint(0x20C, cond(t, "i != 0x300"))
+			},
+			false,
+			Stop{},
+			6,
+		},
+		{
+			"write watchpoint",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.AddWatchpoint(0x300, 1, WATCH_WRITE)
+			},
+			true,
+			Stop{Reason: STOP_WATCHPOINT, PC: 0x20E, ID: 1, Addr: 0x300, Write: true},
+			6,
+		},
+		{
+			"read watchpoint ignores instruction fetches",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.AddWatchpoint(0x200, 0x10, WATCH_READ)
+			},
+			false,
+			Stop{},
+			6,
+		},
+		{
+			"timer class",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.BreakOnClass(CLASS_TIMER, true)
+			},
+			true,
+			Stop{Reason: STOP_CLASS, PC: 0x206, Class: CLASS_TIMER},
+			6,
+		},
+		{
+			"step into",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.AddBreakpoint(0x204, nil)
+				run(t, d, e)
+				d.StepInto()
+			},
+			true,
+			Stop{Reason: STOP_STEP, PC: 0x20A},
+			5,
+		},
+		{
+			"step over",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.AddBreakpoint(0x204, nil)
+				run(t, d, e)
+				d.StepOver()
+			},
+			true,
+			Stop{Reason: STOP_STEP, PC: 0x206},
+			6,
+		},
+		{
+			"step out",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.AddBreakpoint(0x20A, nil)
+				run(t, d, e)
+				d.StepOut()
+			},
+			true,
+			Stop{Reason: STOP_STEP, PC: 0x206},
+			6,
+		},
+		{
+			"run to",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.RunTo(0x20C)
+			},
+			true,
+			Stop{Reason: STOP_STEP, PC: 0x20C},
+			6,
+		},
+		{
+			"continue past a breakpoint",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.AddBreakpoint(0x204, nil)
+				run(t, d, e)
+				d.Continue()
+			},
+			false,
+			Stop{},
+			6,
+		},
+		{
+			"pause",
+			func(t *testing.T, d *Debugger, e *emulator.Emulator) {
+				d.Pause()
+			},
+			true,
+			Stop{Reason: STOP_PAUSE, PC: 0x200},
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, e := newTestDebugger(t)
+			tt.setup(t, d, e)
+
+			s, stopped := run(t, d, e)
+			assert.Equal(t, stopped, tt.stopped)
+			assert.Equal(t, s, tt.want)
+			assert.Equal(t, e.Registers()[0], tt.v0)
+		})
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		cond string
+		want *Condition
+		ok   bool
+	}{
+		{"v3 == 5", &Condition{Register: 3, Op: "==", Value: 5}, true},
+		{"vA>=0x10", &Condition{Register: 0xA, Op: ">=", Value: 0x10}, true},
+		{"i != 0x300", &Condition{Register: REG_I, Op: "!=", Value: 0x300}, true},
+		{"vg == 1", nil, false},
+		{"v1 =< 1", nil, false},
+		{"v1", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cond, func(t *testing.T) {
+			c, err := ParseCondition(tt.cond)
+			assert.Equal(t, err == nil, tt.ok)
+			assert.Equal(t, c, tt.want)
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/swensone/gorito/emulator"
+	"github.com/swensone/gorito/types"
+)
+
+// Disassembler turns roms back into instructions for a mode
+type Disassembler struct {
+	decoder *emulator.Decoder
+	start   int
+}
+
+func New(mode types.Mode) *Disassembler {
+	platform := types.PlatformForMode(mode)
+	return &Disassembler{
+		decoder: emulator.DecoderFor(platform),
+		start:   platform.Start,
+	}
+}
+
+// Listing writes every instruction in the rom with its address and raw bytes, decoding it as code from start to end
+func (d *Disassembler) Listing(w io.Writer, rom []byte) error {
+	bw := bufio.NewWriter(w)
+	for pc := 0; pc < len(rom); {
+		if pc+1 >= len(rom) {
+			// an odd trailing byte can't be an instruction
+			fmt.Fprintf(bw, "%04X  %02X         0x%02X\n", d.start+pc, rom[pc], rom[pc])
+			break
+		}
+
+		raw, text, length := d.Instruction(rom, pc)
+		fmt.Fprintf(bw, "%04X  %-9s  %s\n", d.start+pc, raw, text)
+		pc += length
+	}
+
+	return bw.Flush()
+}
+
+// Instruction disassembles the instruction at offset in mem, returning its raw bytes in hex, its mnemonic and its
+// length. Addresses are written as numbers.
+func (d *Disassembler) Instruction(mem []byte, offset int) (string, string, int) {
+	in := d.decoder.DecodeAt(mem, offset)
+	text, _ := mnemonic(in, func(addr uint32) string {
+		return fmt.Sprintf("0x%03X", addr)
+	})
+	raw := fmt.Sprintf("%04X", in.Opcode)
+	if in.Length == 4 {
+		raw += fmt.Sprintf(" %04X", in.NNNN)
+	}
+	return raw, text, int(in.Length)
+}
+
+// Octo writes the rom as octo source that assembles back to the same bytes. Code is found by following jumps, calls
+// and skips from the start address, everything that can't be reached is written as byte literals. Jump and call
+// targets get generated labels, as do I targets inside the rom.
+func (d *Disassembler) Octo(w io.Writer, rom []byte) error {
+	code, targets := d.trace(rom)
+
+	// label the targets inside the rom, except those in the middle of an instruction which are left as numbers
+	labels := map[int]string{}
+	for addr, kind := range targets {
+		offset := addr - d.start
+		if offset < 0 || offset >= len(rom) || code[offset] == codeBody {
+			continue
+		}
+		if kind == targetCode {
+			labels[addr] = fmt.Sprintf("code_%03X", addr)
+		} else {
+			labels[addr] = fmt.Sprintf("data_%03X", addr)
+		}
+	}
+	labels[d.start] = "main"
+
+	label := func(addr uint32) string {
+		if name, ok := labels[int(addr)]; ok {
+			return name
+		}
+		return fmt.Sprintf("0x%03X", addr)
+	}
+
+	bw := bufio.NewWriter(w)
+	if d.start != 0x200 {
+		fmt.Fprintf(bw, ":org 0x%03X\n", d.start)
+	}
+
+	var data []string
+	flush := func() {
+		for i := 0; i < len(data); i += 8 {
+			end := min(i+8, len(data))
+			fmt.Fprintf(bw, "\t%s\n", strings.Join(data[i:end], " "))
+		}
+		data = data[:0]
+	}
+
+	for pc := 0; pc < len(rom); {
+		addr := d.start + pc
+		if name, ok := labels[addr]; ok {
+			flush()
+			fmt.Fprintf(bw, ": %s\n", name)
+		}
+
+		if code[pc] != codeStart {
+			data = append(data, fmt.Sprintf("0x%02X", rom[pc]))
+			pc++
+			continue
+		}
+		flush()
+
+		in := d.decoder.DecodeAt(rom, pc)
+		text, ok := mnemonic(in, label)
+		if ok {
+			fmt.Fprintf(bw, "\t%s\n", text)
+		} else {
+			// write the raw bytes, with the mnemonic as a comment
+			var bytes []string
+			for i := range int(in.Length) {
+				bytes = append(bytes, fmt.Sprintf("0x%02X", rom[pc+i]))
+			}
+			fmt.Fprintf(bw, "\t%s # %s\n", strings.Join(bytes, " "), text)
+		}
+		pc += int(in.Length)
+	}
+	flush()
+
+	return bw.Flush()
+}
+
+// code map values, tracking which bytes of the rom are instructions
+const (
+	codeNone  = iota // not reached, treated as data
+	codeStart        // first byte of an instruction
+	codeBody         // later byte of an instruction
+)
+
+// target kinds
+const (
+	targetCode = iota
+	targetData
+)
+
+// trace follows every path through the program from the start address, returning a map of which rom bytes are code
+// and the addresses referenced by jumps, calls and I loads
+func (d *Disassembler) trace(rom []byte) ([]int, map[int]int) {
+	code := make([]int, len(rom))
+	targets := map[int]int{}
+	dataTarget := func(addr int) {
+		// code targets win over data targets at the same address
+		if _, ok := targets[addr]; !ok {
+			targets[addr] = targetData
+		}
+	}
+
+	queue := []int{d.start}
+	for len(queue) > 0 {
+		addr := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		pc := addr - d.start
+		if pc < 0 || pc+1 >= len(rom) || code[pc] != codeNone {
+			continue
+		}
+		in := d.decoder.DecodeAt(rom, pc)
+		if pc+int(in.Length) > len(rom) {
+			continue
+		}
+		code[pc] = codeStart
+		for i := 1; i < int(in.Length); i++ {
+			code[pc+i] = codeBody
+		}
+		next := addr + int(in.Length)
+
+		switch in.Op {
+		case emulator.OP_JP, emulator.OP_JP_V0:
+			// for jump0 the table itself is code, but where it leads can't be known without running the program
+			targets[int(in.NNN())] = targetCode
+			queue = append(queue, int(in.NNN()))
+		case emulator.OP_CALL:
+			targets[int(in.NNN())] = targetCode
+			queue = append(queue, int(in.NNN()), next)
+		case emulator.OP_SE_NN, emulator.OP_SNE_NN, emulator.OP_SE_VY, emulator.OP_SNE_VY, emulator.OP_SKP,
+			emulator.OP_SKNP, emulator.OP_SKP2, emulator.OP_SKNP2:
+			// the skipped instruction may be 4 bytes long
+			skipped := d.decoder.DecodeAt(rom, next-d.start)
+			queue = append(queue, next, next+int(skipped.Length))
+		case emulator.OP_LD_I:
+			dataTarget(int(in.NNN()))
+			queue = append(queue, next)
+		case emulator.OP_LD_I_LONG:
+			dataTarget(int(in.NNNN))
+			queue = append(queue, next)
+		case emulator.OP_RET, emulator.OP_EXIT:
+		default:
+			queue = append(queue, next)
+		}
+	}
+
+	return code, targets
+}
@@ -0,0 +1,148 @@
+package disasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/asm"
+	"github.com/swensone/gorito/types"
+)
+
+func TestListing(t *testing.T) {
+	tests := []struct {
+		name string
+		mode types.Mode
+		rom  []byte
+		want string
+	}{
+		{
+			"chip-8",
+			types.MODE_CHIP8,
+			[]byte{0x60, 0x05, 0xA2, 0x34, 0xD0, 0x15, 0x12, 0x00},
+			"0200  6005       v0 := 0x05\n" +
+				"0202  A234       i := 0x234\n" +
+				"0204  D015       sprite v0 v1 0x5\n" +
+				"0206  1200       jump 0x200\n",
+		},
+		{
+			"xo-chip long load and register ranges",
+			types.MODE_XOCHIP,
+			[]byte{0xF0, 0x00, 0x12, 0x34, 0x51, 0x32, 0x51, 0x33},
+			"0200  F000 1234  i := long 0x1234\n" +
+				"0204  5132       save v1 - v3\n" +
+				"0206  5133       load v1 - v3\n",
+		},
+		{
+			"xo-chip and superchip opcodes on chip-8",
+			types.MODE_CHIP8,
+			[]byte{0x51, 0x32, 0x00, 0xFF},
+			"0200  5132       0x51 0x32\n" +
+				"0202  00FF       0x00 0xFF\n",
+		},
+		{
+			"odd trailing byte",
+			types.MODE_CHIP8,
+			[]byte{0x00, 0xE0, 0xFF},
+			"0200  00E0       clear\n" +
+				"0202  FF         0xFF\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := New(tt.mode).Listing(&buf, tt.rom); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, buf.String(), tt.want)
+		})
+	}
+}
+
+func TestOcto(t *testing.T) {
+	tests := []struct {
+		name string
+		mode types.Mode
+		rom  []byte
+		want string
+	}{
+		{
+			"labels and data",
+			types.MODE_CHIP8,
+			[]byte{
+				0xA2, 0x08, // i := data
+				0x22, 0x06, // call sub
+				0x12, 0x00, // jump main
+				0x00, 0xEE, // sub: return
+				0xF0, 0x90, // data
+			},
+			": main\n" +
+				"\ti := data_208\n" +
+				"\t:call code_206\n" +
+				"\tjump main\n" +
+				": code_206\n" +
+				"\treturn\n" +
+				": data_208\n" +
+				"\t0xF0 0x90\n",
+		},
+		{
+			"skipping a long load",
+			types.MODE_XOCHIP,
+			[]byte{
+				0x30, 0x00, // if v0 != 0 then
+				0xF0, 0x00, 0x02, 0x0A, // i := long data
+				0x12, 0x00, // jump main
+				0xFF, 0xFF, // unreachable
+				0x3C, // data
+			},
+			": main\n" +
+				"\tif v0 != 0x00 then\n" +
+				"\ti := long data_20A\n" +
+				"\tjump main\n" +
+				"\t0xFF 0xFF\n" +
+				": data_20A\n" +
+				"\t0x3C\n",
+		},
+		{
+			"chip-8x starts at 0x300",
+			types.MODE_CHIP8X,
+			[]byte{0x02, 0xA0, 0x13, 0x00},
+			":org 0x300\n" +
+				": main\n" +
+				"\t0x02 0xA0 # bgstep\n" +
+				"\tjump main\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := New(tt.mode).Octo(&buf, tt.rom); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, buf.String(), tt.want)
+		})
+	}
+}
+
+// disassembling to octo source and assembling it again should give back the original rom
+func TestRoundTrip(t *testing.T) {
+	rom := []byte{
+		0x00, 0xE0, 0x6A, 0x10, 0x7A, 0xFF, 0x8A, 0xB4, 0x8A, 0xBE, 0xA2, 0x2A, 0xD0, 0x15, 0x3A, 0x00,
+		0xF0, 0x00, 0x02, 0x2A, 0x4A, 0x01, 0x22, 0x26, 0xEA, 0x9E, 0xFA, 0x0A, 0x51, 0x32, 0x51, 0x33,
+		0xF2, 0x01, 0x00, 0xC4, 0x12, 0x00, 0x00, 0xEE, 0xF0, 0x90, 0x90, 0x90, 0xF0,
+	}
+
+	var src bytes.Buffer
+	if err := New(types.MODE_XOCHIP).Octo(&src, rom); err != nil {
+		t.Fatal(err)
+	}
+	p, err := asm.Assemble("roundtrip.8o", src.Bytes(), 0x200)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, src.String())
+	}
+	assert.Equal(t, p.ROM, rom, strings.TrimSpace(src.String()))
+}
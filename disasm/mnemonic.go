@@ -0,0 +1,161 @@
+package disasm
+
+import (
+	"fmt"
+
+	"github.com/swensone/gorito/emulator"
+)
+
+// mnemonic returns the octo statement for an instruction. label names an address used as a jump, call or I target.
+// ok is false for instructions octo can't express, which are written out as bytes when producing octo source.
+func mnemonic(in emulator.Instruction, label func(addr uint32) string) (string, bool) {
+	X, Y, N, NN, NNN := in.X(), in.Y(), in.N(), in.NN(), in.NNN()
+
+	switch in.Op {
+	case emulator.OP_CLS:
+		return "clear", true
+	case emulator.OP_RET:
+		return "return", true
+	case emulator.OP_JP:
+		return "jump " + label(uint32(NNN)), true
+	case emulator.OP_CALL:
+		return ":call " + label(uint32(NNN)), true
+	case emulator.OP_SE_NN:
+		return fmt.Sprintf("if v%X != 0x%02X then", X, NN), true
+	case emulator.OP_SNE_NN:
+		return fmt.Sprintf("if v%X == 0x%02X then", X, NN), true
+	case emulator.OP_SE_VY:
+		return fmt.Sprintf("if v%X != v%X then", X, Y), true
+	case emulator.OP_LD_NN:
+		return fmt.Sprintf("v%X := 0x%02X", X, NN), true
+	case emulator.OP_ADD_NN:
+		return fmt.Sprintf("v%X += 0x%02X", X, NN), true
+	case emulator.OP_LD_VY:
+		return fmt.Sprintf("v%X := v%X", X, Y), true
+	case emulator.OP_OR:
+		return fmt.Sprintf("v%X |= v%X", X, Y), true
+	case emulator.OP_AND:
+		return fmt.Sprintf("v%X &= v%X", X, Y), true
+	case emulator.OP_XOR:
+		return fmt.Sprintf("v%X ^= v%X", X, Y), true
+	case emulator.OP_ADD_VY:
+		return fmt.Sprintf("v%X += v%X", X, Y), true
+	case emulator.OP_SUB:
+		return fmt.Sprintf("v%X -= v%X", X, Y), true
+	case emulator.OP_SHR:
+		return fmt.Sprintf("v%X >>= v%X", X, Y), true
+	case emulator.OP_SUBN:
+		return fmt.Sprintf("v%X =- v%X", X, Y), true
+	case emulator.OP_SHL:
+		return fmt.Sprintf("v%X <<= v%X", X, Y), true
+	case emulator.OP_SNE_VY:
+		return fmt.Sprintf("if v%X == v%X then", X, Y), true
+	case emulator.OP_LD_I:
+		return "i := " + label(uint32(NNN)), true
+	case emulator.OP_JP_V0:
+		return "jump0 " + label(uint32(NNN)), true
+	case emulator.OP_RND:
+		return fmt.Sprintf("v%X := random 0x%02X", X, NN), true
+	case emulator.OP_DRW:
+		return fmt.Sprintf("sprite v%X v%X 0x%X", X, Y, N), true
+	case emulator.OP_SKP:
+		return fmt.Sprintf("if v%X -key then", X), true
+	case emulator.OP_SKNP:
+		return fmt.Sprintf("if v%X key then", X), true
+	case emulator.OP_LD_VX_DT:
+		return fmt.Sprintf("v%X := delay", X), true
+	case emulator.OP_LD_KEY:
+		return fmt.Sprintf("v%X := key", X), true
+	case emulator.OP_LD_DT:
+		return fmt.Sprintf("delay := v%X", X), true
+	case emulator.OP_LD_ST:
+		return fmt.Sprintf("buzzer := v%X", X), true
+	case emulator.OP_ADD_I:
+		return fmt.Sprintf("i += v%X", X), true
+	case emulator.OP_LD_FONT:
+		return fmt.Sprintf("i := hex v%X", X), true
+	case emulator.OP_BCD:
+		return fmt.Sprintf("bcd v%X", X), true
+	case emulator.OP_STORE:
+		return fmt.Sprintf("save v%X", X), true
+	case emulator.OP_LOAD:
+		return fmt.Sprintf("load v%X", X), true
+
+	case emulator.OP_SCROLL_DOWN:
+		return fmt.Sprintf("scroll-down 0x%X", N), true
+	case emulator.OP_SCROLL_RIGHT:
+		return "scroll-right", true
+	case emulator.OP_SCROLL_LEFT:
+		return "scroll-left", true
+	case emulator.OP_EXIT:
+		return "exit", true
+	case emulator.OP_LORES:
+		return "lores", true
+	case emulator.OP_HIRES:
+		return "hires", true
+	case emulator.OP_LD_HIFONT:
+		return fmt.Sprintf("i := bighex v%X", X), true
+	case emulator.OP_SAVE_FLAGS:
+		return fmt.Sprintf("saveflags v%X", X), true
+	case emulator.OP_LOAD_FLAGS:
+		return fmt.Sprintf("loadflags v%X", X), true
+
+	case emulator.OP_SCROLL_UP:
+		// octo only has the xo-chip encoding, megachip's 00BN is written as bytes
+		return fmt.Sprintf("scroll-up 0x%X", N), in.Opcode&0xFFF0 == 0x00D0
+	case emulator.OP_SAVE_RANGE:
+		return fmt.Sprintf("save v%X - v%X", X, Y), true
+	case emulator.OP_LOAD_RANGE:
+		return fmt.Sprintf("load v%X - v%X", X, Y), true
+	case emulator.OP_LD_I_LONG:
+		return "i := long " + label(uint32(in.NNNN)), true
+	case emulator.OP_AUDIO:
+		return "audio", true
+	case emulator.OP_PLANE:
+		return fmt.Sprintf("plane %d", X), true
+	case emulator.OP_PITCH:
+		return fmt.Sprintf("pitch := v%X", X), true
+
+	// octo doesn't support megachip or chip-8x, these use the mnemonics from their original documentation
+	case emulator.OP_MEGA_OFF:
+		return "megaoff", false
+	case emulator.OP_MEGA_ON:
+		return "megaon", false
+	case emulator.OP_LD_I_24:
+		return "ldhi " + label(uint32(NN)<<16|uint32(in.NNNN)), false
+	case emulator.OP_PALETTE:
+		return fmt.Sprintf("ldpal 0x%02X", NN), false
+	case emulator.OP_SPRITE_W:
+		return fmt.Sprintf("sprw 0x%02X", NN), false
+	case emulator.OP_SPRITE_H:
+		return fmt.Sprintf("sprh 0x%02X", NN), false
+	case emulator.OP_ALPHA:
+		return fmt.Sprintf("alpha 0x%02X", NN), false
+	case emulator.OP_SAMPLE:
+		return fmt.Sprintf("digisnd 0x%X", N), false
+	case emulator.OP_STOP_SAMPLE:
+		return "stopsnd", false
+	case emulator.OP_BLEND:
+		return fmt.Sprintf("bmode 0x%X", N), false
+	case emulator.OP_COLLISION:
+		return fmt.Sprintf("ccol 0x%02X", NN), false
+	case emulator.OP_BG_STEP:
+		return "bgstep", false
+	case emulator.OP_ADD_NIBBLES:
+		return fmt.Sprintf("v%X +n v%X", X, Y), false
+	case emulator.OP_ZONE_COLOR:
+		return fmt.Sprintf("zonecol v%X v%X", X, Y), false
+	case emulator.OP_SPRITE_COLOR:
+		return fmt.Sprintf("sprcol v%X v%X 0x%X", X, Y, N), false
+	case emulator.OP_SKP2:
+		return fmt.Sprintf("if v%X -key2 then", X), false
+	case emulator.OP_SKNP2:
+		return fmt.Sprintf("if v%X key2 then", X), false
+	case emulator.OP_OUT:
+		return fmt.Sprintf("out v%X", X), false
+	case emulator.OP_IN:
+		return fmt.Sprintf("v%X := in", X), false
+	}
+
+	return fmt.Sprintf("0x%02X 0x%02X", uint8(in.Opcode>>8), uint8(in.Opcode)), false
+}
@@ -0,0 +1,160 @@
+package emulator
+
+import (
+	"math"
+
+	"github.com/swensone/gorito/types"
+)
+
+// the vp-590 color board colors the 64x32 display in zones 8 pixels wide and 1 pixel high, which BXY0 sets in blocks
+// of 4 rows. pixels are looked up in the ColorMap, CHIP8X_FG+color for set pixels and CHIP8X_BG+background for unset.
+const (
+	CHIP8X_ZONE_COLS = 8
+	CHIP8X_ZONE_ROWS = 32
+
+	CHIP8X_FG uint8 = 0x10
+	CHIP8X_BG uint8 = 0x20
+)
+
+// chip8xColors are the default ColorMap entries for the vp-590 foreground colors and the 4 background colors stepped
+// through by 02A0
+var chip8xColors = map[uint8]types.Color{
+	CHIP8X_FG + 0: {R: 0x00, G: 0x00, B: 0x00}, // black
+	CHIP8X_FG + 1: {R: 0xFF, G: 0x00, B: 0x00}, // red
+	CHIP8X_FG + 2: {R: 0x00, G: 0x00, B: 0xFF}, // blue
+	CHIP8X_FG + 3: {R: 0xFF, G: 0x00, B: 0xFF}, // violet
+	CHIP8X_FG + 4: {R: 0x00, G: 0xFF, B: 0x00}, // green
+	CHIP8X_FG + 5: {R: 0xFF, G: 0xFF, B: 0x00}, // yellow
+	CHIP8X_FG + 6: {R: 0x00, G: 0xFF, B: 0xFF}, // aqua
+	CHIP8X_FG + 7: {R: 0xFF, G: 0xFF, B: 0xFF}, // white
+	CHIP8X_BG + 0: {R: 0x00, G: 0x00, B: 0x80}, // dark blue
+	CHIP8X_BG + 1: {R: 0x00, G: 0x00, B: 0x00}, // black
+	CHIP8X_BG + 2: {R: 0x00, G: 0x80, B: 0x00}, // dark green
+	CHIP8X_BG + 3: {R: 0x80, G: 0x00, B: 0x00}, // dark red
+}
+
+// chip8x holds the state of the vp-590 color board and the vp-595 sound board. Fields are exported so they can be
+// saved in save states.
+type chip8x struct {
+	Zones      [CHIP8X_ZONE_COLS * CHIP8X_ZONE_ROWS]uint8
+	Background uint8
+	Output     uint8 // last value written to the output port
+	Input      uint8 // value latched on the input port
+	InputReady bool
+}
+
+func newChip8x() chip8x {
+	c := chip8x{}
+	// zones start out red
+	for i := range c.Zones {
+		c.Zones[i] = 1
+	}
+	return c
+}
+
+// stepBackground: 02A0: Step the background color through blue, black, green and red
+func (e *Emulator) stepBackground() {
+	e.c8x.Background = (e.c8x.Background + 1) % 4
+	e.drawFlag = true
+}
+
+// addVYtoVXNibbles: 5XY1: Adds VY to VX, adding each nibble separately and wrapping them at 8. VF is not affected.
+func (e *Emulator) addVYtoVXNibbles(X, Y uint8) {
+	VX := e.registers[X]
+	VY := e.registers[Y]
+	hi := (VX>>4 + VY>>4) % 8
+	lo := (VX&0x0F + VY&0x0F) % 8
+	e.registers[X] = hi<<4 | lo
+}
+
+// setZoneColors: BXY0: Sets the color of a block of zones to VY. The low nibble of VX is the first column and the
+// high nibble the number of columns after it, V(X+1) is the same for rows of 4 zones.
+func (e *Emulator) setZoneColors(X, Y uint8) {
+	col := int(e.registers[X] & 0x0F)
+	cols := int(e.registers[X]>>4) + 1
+	row := int(e.registers[(X+1)&0x0F]&0x0F) * 4
+	rows := (int(e.registers[(X+1)&0x0F]>>4) + 1) * 4
+
+	for y := row; y < row+rows && y < CHIP8X_ZONE_ROWS; y++ {
+		for x := col; x < col+cols && x < CHIP8X_ZONE_COLS; x++ {
+			e.c8x.Zones[y*CHIP8X_ZONE_COLS+x] = e.registers[Y] & 0x07
+		}
+	}
+	e.drawFlag = true
+}
+
+// setSpriteColors: BXYN: Sets the color of the zones under an 8xN sprite drawn at (VX, V(X+1)) to VY
+func (e *Emulator) setSpriteColors(X, Y, N uint8) {
+	col := int(e.registers[X]) / 8 % CHIP8X_ZONE_COLS
+	row := int(e.registers[(X+1)&0x0F])
+
+	for y := row; y < row+int(N) && y < CHIP8X_ZONE_ROWS; y++ {
+		e.c8x.Zones[y*CHIP8X_ZONE_COLS+col] = e.registers[Y] & 0x07
+	}
+	e.drawFlag = true
+}
+
+// skipIfPressed2: EXF2: Skips the next instruction if the key stored in VX is pressed on the second keypad
+func (e *Emulator) skipIfPressed2(X uint8) {
+	if e.keys2[e.registers[X]&0x0F] {
+		e.skipNext()
+	}
+}
+
+// skipIfNotPressed2: EXF5: Skips the next instruction if the key stored in VX is not pressed on the second keypad
+func (e *Emulator) skipIfNotPressed2(X uint8) {
+	if !e.keys2[e.registers[X]&0x0F] {
+		e.skipNext()
+	}
+}
+
+// outputVX: FXF8: Outputs VX to the io port. With the vp-595 sound board this sets the tone of the beeper, 0 resets it.
+func (e *Emulator) outputVX(X uint8) {
+	e.c8x.Output = e.registers[X]
+
+	val := e.c8x.Output
+	if val == 0 {
+		val = 0x80
+	}
+	// the vp-595 divides its clock by VX+1, convert that to an xo-chip style pitch value
+	freq := 27535 / (float64(val) + 1)
+	pitch := 64 + 48*math.Log2(freq/4000)
+	e.pitch = uint8(max(min(pitch, 255), 0))
+	e.audio.SetPitch(e.pitch)
+}
+
+// inputVX: FXFB: Waits for a value on the io port and stores it in VX
+func (e *Emulator) inputVX(X uint8) {
+	if !e.c8x.InputReady {
+		e.pc -= 2
+		return
+	}
+	e.registers[X] = e.c8x.Input
+	e.c8x.InputReady = false
+}
+
+// WritePort latches a value on the chip-8x input port for FXFB to read
+func (e *Emulator) WritePort(val uint8) {
+	e.c8x.Input = val
+	e.c8x.InputReady = true
+}
+
+// ReadPort returns the last value written to the chip-8x output port by FXF8
+func (e *Emulator) ReadPort() uint8 {
+	return e.c8x.Output
+}
+
+// chip8xGfx colors the display using the zone colors and the background
+func (e *Emulator) chip8xGfx() []types.Color {
+	res := make([]types.Color, XRES*YRES)
+	for i := range XRES * YRES {
+		// zones are measured in low resolution pixels, which are 2x2 in the display buffer
+		x, y := i%XRES/2, i/XRES/2
+		if e.gfx[0][i] != 0 {
+			res[i] = e.cfg.ColorMap[CHIP8X_FG+e.c8x.Zones[int(y)*CHIP8X_ZONE_COLS+int(x)/8]]
+		} else {
+			res[i] = e.cfg.ColorMap[CHIP8X_BG+e.c8x.Background]
+		}
+	}
+	return res
+}
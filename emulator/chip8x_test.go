@@ -0,0 +1,41 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/types"
+)
+
+func TestChip8x(t *testing.T) {
+	e := newTestEmulator(t, types.MODE_CHIP8X)
+	rom := []byte{
+		0x60, 0x10, // V0 := 0x10, columns 0-1
+		0x61, 0x01, // V1 := 0x01, rows 4-7
+		0x62, 0x04, // V2 := green
+		0xB0, 0x20, // color the zones
+		0x02, 0xA0, // step the background to black
+		0x63, 0x76, // V3 := 0x76
+		0x64, 0x13, // V4 := 0x13
+		0x53, 0x41, // add V4 to V3 by nibble
+	}
+	if err := e.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	for range 8 {
+		if err := e.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Equal(t, e.PC(), uint16(0x310))
+	assert.Equal(t, e.Registers()[3], uint8(0x01))
+	assert.Equal(t, e.c8x.Zones[4*CHIP8X_ZONE_COLS], uint8(4))
+	assert.Equal(t, e.c8x.Zones[7*CHIP8X_ZONE_COLS+1], uint8(4))
+	assert.Equal(t, e.c8x.Zones[7*CHIP8X_ZONE_COLS+2], uint8(1))
+	assert.Equal(t, e.c8x.Zones[8*CHIP8X_ZONE_COLS], uint8(1))
+
+	// the background is drawn from the color map
+	assert.Equal(t, e.Framebuffer()[0], chip8xColors[CHIP8X_BG+1])
+}
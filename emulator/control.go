@@ -0,0 +1,88 @@
+package emulator
+
+import (
+	"bytes"
+	"path"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/types"
+)
+
+// ErrNotRunning is returned from Do once Run has returned
+var ErrNotRunning = errors.New("emulator isn't running")
+
+// request is a function queued by Do
+type request struct {
+	fn   func() error
+	done chan error
+}
+
+// Do runs fn on the goroutine running Run, between instructions, so that other goroutines can inspect and change the
+// emulator while it runs. Requests are handled while paused or held by a debugger too. Do waits for fn and returns its
+// error, or ErrNotRunning once Run has returned.
+func (e *Emulator) Do(fn func() error) error {
+	r := request{fn: fn, done: make(chan error, 1)}
+	select {
+	case e.requests <- r:
+	case <-e.exited:
+		return ErrNotRunning
+	}
+	return <-r.done
+}
+
+// handleRequests runs any functions waiting in Do
+func (e *Emulator) handleRequests() {
+	for {
+		select {
+		case r := <-e.requests:
+			r.done <- r.fn()
+		default:
+			return
+		}
+	}
+}
+
+// Paused returns true while the emulator is paused with the pause hotkey or SetPaused
+func (e *Emulator) Paused() bool {
+	return e.paused
+}
+
+// SetPaused pauses or resumes execution
+func (e *Emulator) SetPaused(paused bool) {
+	e.paused = paused
+}
+
+// Mode returns the mode being emulated
+func (e *Emulator) Mode() types.Mode {
+	return e.cfg.Mode
+}
+
+// Restart resets the machine and loads the last program again
+func (e *Emulator) Restart() error {
+	program := e.program
+	e.Reset()
+	e.loadErr = nil
+	return e.LoadROM(program)
+}
+
+// Replace resets the machine and loads a new program in place of the current one. The name is used for save states
+// like a rom's file name, octo source with a .8o name is assembled first. If the program can't be loaded the machine
+// is left as it was.
+func (e *Emulator) Replace(name string, data []byte) error {
+	st := e.snapshot()
+	e.Reset()
+	e.loadErr = nil
+
+	var err error
+	if path.Ext(name) == ".8o" {
+		err = e.loadSource(name, bytes.NewReader(data))
+	} else {
+		err = e.LoadROM(data)
+	}
+	if err != nil {
+		return errors.CombineErrors(err, e.restore(st))
+	}
+	e.rom = RomName(name)
+	return nil
+}
@@ -0,0 +1,397 @@
+package emulator
+
+import (
+	"sync"
+
+	"github.com/swensone/gorito/types"
+)
+
+// Op identifies an instruction independently of its operands
+type Op uint8
+
+const (
+	OP_UNKNOWN Op = iota
+
+	// chip-8
+	OP_CLS      // 00E0
+	OP_RET      // 00EE
+	OP_JP       // 1NNN
+	OP_CALL     // 2NNN
+	OP_SE_NN    // 3XNN
+	OP_SNE_NN   // 4XNN
+	OP_SE_VY    // 5XY0
+	OP_LD_NN    // 6XNN
+	OP_ADD_NN   // 7XNN
+	OP_LD_VY    // 8XY0
+	OP_OR       // 8XY1
+	OP_AND      // 8XY2
+	OP_XOR      // 8XY3
+	OP_ADD_VY   // 8XY4
+	OP_SUB      // 8XY5
+	OP_SHR      // 8XY6
+	OP_SUBN     // 8XY7
+	OP_SHL      // 8XYE
+	OP_SNE_VY   // 9XY0
+	OP_LD_I     // ANNN
+	OP_JP_V0    // BNNN
+	OP_RND      // CXNN
+	OP_DRW      // DXYN
+	OP_SKP      // EX9E
+	OP_SKNP     // EXA1
+	OP_LD_VX_DT // FX07
+	OP_LD_KEY   // FX0A
+	OP_LD_DT    // FX15
+	OP_LD_ST    // FX18
+	OP_ADD_I    // FX1E
+	OP_LD_FONT  // FX29
+	OP_BCD      // FX33
+	OP_STORE    // FX55
+	OP_LOAD     // FX65
+
+	// superchip
+	OP_SCROLL_DOWN  // 00CN
+	OP_SCROLL_RIGHT // 00FB
+	OP_SCROLL_LEFT  // 00FC
+	OP_EXIT         // 00FD
+	OP_LORES        // 00FE
+	OP_HIRES        // 00FF
+	OP_LD_HIFONT    // FX30
+	OP_SAVE_FLAGS   // FX75
+	OP_LOAD_FLAGS   // FX85
+
+	// xo-chip
+	OP_SCROLL_UP  // 00DN, 00BN on megachip
+	OP_SAVE_RANGE // 5XY2
+	OP_LOAD_RANGE // 5XY3
+	OP_LD_I_LONG  // F000 NNNN
+	OP_AUDIO      // F002
+	OP_PLANE      // FN01
+	OP_PITCH      // FX3A
+
+	// megachip
+	OP_MEGA_OFF    // 0010
+	OP_MEGA_ON     // 0011
+	OP_LD_I_24     // 01NN NNNN
+	OP_PALETTE     // 02NN
+	OP_SPRITE_W    // 03NN
+	OP_SPRITE_H    // 04NN
+	OP_ALPHA       // 05NN
+	OP_SAMPLE      // 060N
+	OP_STOP_SAMPLE // 0700
+	OP_BLEND       // 080N
+	OP_COLLISION   // 09NN
+
+	// chip-8x
+	OP_BG_STEP      // 02A0
+	OP_ADD_NIBBLES  // 5XY1
+	OP_ZONE_COLOR   // BXY0
+	OP_SPRITE_COLOR // BXYN
+	OP_SKP2         // EXF2
+	OP_SKNP2        // EXF5
+	OP_OUT          // FXF8
+	OP_IN           // FXFB
+
+	OP_COUNT
+)
+
+// Instruction is a decoded opcode. The operands are extracted by methods rather than stored, which keeps the struct
+// small enough for the compiler to pass around in registers.
+type Instruction struct {
+	Op     Op
+	Opcode uint16
+	NNNN   uint16 // second word of 4 byte instructions, only filled in by DecodeAt
+	Length uint16
+}
+
+// X returns the second nibble of the opcode
+func (in Instruction) X() uint8 {
+	return uint8(in.Opcode>>8) & 0x0F
+}
+
+// Y returns the third nibble of the opcode
+func (in Instruction) Y() uint8 {
+	return uint8(in.Opcode>>4) & 0x0F
+}
+
+// N returns the last nibble of the opcode
+func (in Instruction) N() uint8 {
+	return uint8(in.Opcode) & 0x0F
+}
+
+// NN returns the low byte of the opcode
+func (in Instruction) NN() uint8 {
+	return uint8(in.Opcode)
+}
+
+// NNN returns the low 12 bits of the opcode
+func (in Instruction) NNN() uint16 {
+	return in.Opcode & 0x0FFF
+}
+
+// pattern matches opcodes where opcode&mask == value
+type pattern struct {
+	mask  uint16
+	value uint16
+	op    Op
+}
+
+// chip8Patterns is the original chip-8 instruction set shared by every platform
+var chip8Patterns = []pattern{
+	{0xFFFF, 0x00E0, OP_CLS},
+	{0xFFFF, 0x00EE, OP_RET},
+	{0xF000, 0x1000, OP_JP},
+	{0xF000, 0x2000, OP_CALL},
+	{0xF000, 0x3000, OP_SE_NN},
+	{0xF000, 0x4000, OP_SNE_NN},
+	{0xF00F, 0x5000, OP_SE_VY},
+	{0xF000, 0x6000, OP_LD_NN},
+	{0xF000, 0x7000, OP_ADD_NN},
+	{0xF00F, 0x8000, OP_LD_VY},
+	{0xF00F, 0x8001, OP_OR},
+	{0xF00F, 0x8002, OP_AND},
+	{0xF00F, 0x8003, OP_XOR},
+	{0xF00F, 0x8004, OP_ADD_VY},
+	{0xF00F, 0x8005, OP_SUB},
+	{0xF00F, 0x8006, OP_SHR},
+	{0xF00F, 0x8007, OP_SUBN},
+	{0xF00F, 0x800E, OP_SHL},
+	{0xF00F, 0x9000, OP_SNE_VY},
+	{0xF000, 0xA000, OP_LD_I},
+	{0xF000, 0xB000, OP_JP_V0},
+	{0xF000, 0xC000, OP_RND},
+	{0xF000, 0xD000, OP_DRW},
+	{0xF0FF, 0xE09E, OP_SKP},
+	{0xF0FF, 0xE0A1, OP_SKNP},
+	{0xF0FF, 0xF007, OP_LD_VX_DT},
+	{0xF0FF, 0xF00A, OP_LD_KEY},
+	{0xF0FF, 0xF015, OP_LD_DT},
+	{0xF0FF, 0xF018, OP_LD_ST},
+	{0xF0FF, 0xF01E, OP_ADD_I},
+	{0xF0FF, 0xF029, OP_LD_FONT},
+	{0xF0FF, 0xF033, OP_BCD},
+	{0xF0FF, 0xF055, OP_STORE},
+	{0xF0FF, 0xF065, OP_LOAD},
+}
+
+// superchipPatterns are added by superchip 1.0, high resolution, the big font and the rpl flags
+var superchipPatterns = []pattern{
+	{0xFFFF, 0x00FD, OP_EXIT},
+	{0xFFFF, 0x00FE, OP_LORES},
+	{0xFFFF, 0x00FF, OP_HIRES},
+	{0xF0FF, 0xF030, OP_LD_HIFONT},
+	{0xF0FF, 0xF075, OP_SAVE_FLAGS},
+	{0xF0FF, 0xF085, OP_LOAD_FLAGS},
+}
+
+// scrollPatterns are added by superchip 1.1
+var scrollPatterns = []pattern{
+	{0xFFF0, 0x00C0, OP_SCROLL_DOWN},
+	{0xFFFF, 0x00FB, OP_SCROLL_RIGHT},
+	{0xFFFF, 0x00FC, OP_SCROLL_LEFT},
+}
+
+// xochipPatterns are added by xo-chip
+var xochipPatterns = []pattern{
+	{0xFFF0, 0x00D0, OP_SCROLL_UP},
+	{0xF00F, 0x5002, OP_SAVE_RANGE},
+	{0xF00F, 0x5003, OP_LOAD_RANGE},
+	{0xFFFF, 0xF000, OP_LD_I_LONG},
+	{0xFFFF, 0xF002, OP_AUDIO},
+	{0xF0FF, 0xF001, OP_PLANE},
+	{0xF0FF, 0xF03A, OP_PITCH},
+}
+
+// megachipPatterns are checked before the other instruction sets on megachip
+var megachipPatterns = []pattern{
+	{0xFFFF, 0x0010, OP_MEGA_OFF},
+	{0xFFFF, 0x0011, OP_MEGA_ON},
+	{0xFFF0, 0x00B0, OP_SCROLL_UP},
+	{0xFF00, 0x0100, OP_LD_I_24},
+	{0xFF00, 0x0200, OP_PALETTE},
+	{0xFF00, 0x0300, OP_SPRITE_W},
+	{0xFF00, 0x0400, OP_SPRITE_H},
+	{0xFF00, 0x0500, OP_ALPHA},
+	{0xFFF0, 0x0600, OP_SAMPLE},
+	{0xFFFF, 0x0700, OP_STOP_SAMPLE},
+	{0xFFF0, 0x0800, OP_BLEND},
+	{0xFF00, 0x0900, OP_COLLISION},
+}
+
+// chip8xPatterns are checked before the other instruction sets on chip-8x, BXYN replaces BNNN
+var chip8xPatterns = []pattern{
+	{0xFFFF, 0x02A0, OP_BG_STEP},
+	{0xF00F, 0x5001, OP_ADD_NIBBLES},
+	{0xF00F, 0xB000, OP_ZONE_COLOR},
+	{0xF000, 0xB000, OP_SPRITE_COLOR},
+	{0xF0FF, 0xE0F2, OP_SKP2},
+	{0xF0FF, 0xE0F5, OP_SKNP2},
+	{0xF0FF, 0xF0F8, OP_OUT},
+	{0xF0FF, 0xF0FB, OP_IN},
+}
+
+// Decoder turns opcodes into instructions for a platform, using a table of every possible opcode built up front
+type Decoder struct {
+	ops [0x10000]Op
+}
+
+var (
+	decoders   = map[types.Platform]*Decoder{}
+	decodersMu sync.Mutex
+)
+
+// DecoderFor returns the decoder for a platform, building it the first time the platform is seen
+func DecoderFor(p types.Platform) *Decoder {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+
+	if d, ok := decoders[p]; ok {
+		return d
+	}
+	d := newDecoder(p)
+	decoders[p] = d
+	return d
+}
+
+func newDecoder(p types.Platform) *Decoder {
+	var patterns []pattern
+	if p.Megachip {
+		patterns = append(patterns, megachipPatterns...)
+	}
+	if p.Chip8X {
+		patterns = append(patterns, chip8xPatterns...)
+	}
+	if p.Hires {
+		patterns = append(patterns, superchipPatterns...)
+	}
+	if p.Scroll {
+		patterns = append(patterns, scrollPatterns...)
+	}
+	if p.XOChip {
+		patterns = append(patterns, xochipPatterns...)
+	}
+	patterns = append(patterns, chip8Patterns...)
+
+	d := &Decoder{}
+	for opcode := range len(d.ops) {
+		for _, pt := range patterns {
+			if uint16(opcode)&pt.mask == pt.value {
+				d.ops[opcode] = pt.op
+				break
+			}
+		}
+	}
+	return d
+}
+
+// Decode decodes an opcode. NNNN is left empty, the cpu reads the second word of 4 byte instructions as they execute.
+func (d *Decoder) Decode(opcode uint16) Instruction {
+	in := Instruction{
+		Op:     d.ops[opcode],
+		Opcode: opcode,
+		Length: 2,
+	}
+	if in.Op == OP_LD_I_LONG || in.Op == OP_LD_I_24 {
+		in.Length = 4
+	}
+	return in
+}
+
+// DecodeAt decodes the instruction at addr in mem, including the second word of 4 byte instructions. Reads past the
+// end of mem return zeros.
+func (d *Decoder) DecodeAt(mem []uint8, addr int) Instruction {
+	word := func(a int) uint16 {
+		var hi, lo uint8
+		if a < len(mem) {
+			hi = mem[a]
+		}
+		if a+1 < len(mem) {
+			lo = mem[a+1]
+		}
+		return uint16(hi)<<8 | uint16(lo)
+	}
+
+	in := d.Decode(word(addr))
+	if in.Length == 4 {
+		in.NNNN = word(addr + 2)
+	}
+	return in
+}
+
+// dispatch holds the handler for each op. platforms pick which ops they support through their decoder.
+var dispatch = [OP_COUNT]func(e *Emulator, in Instruction){
+	OP_UNKNOWN: func(e *Emulator, in Instruction) { e.raise(types.FAULT_UNKNOWN_OPCODE, "unable to interpret opcode") },
+
+	OP_CLS:      func(e *Emulator, in Instruction) { e.clearDisplay() },
+	OP_RET:      func(e *Emulator, in Instruction) { e.returnFromSubroutine() },
+	OP_JP:       func(e *Emulator, in Instruction) { e.jumpToNNN(in.NNN()) },
+	OP_CALL:     func(e *Emulator, in Instruction) { e.callNNN(in.NNN()) },
+	OP_SE_NN:    func(e *Emulator, in Instruction) { e.skipIfVXEqualsNN(in.X(), in.NN()) },
+	OP_SNE_NN:   func(e *Emulator, in Instruction) { e.skipIfVXNotEqualsNN(in.X(), in.NN()) },
+	OP_SE_VY:    func(e *Emulator, in Instruction) { e.skipIfVXEqualsVY(in.X(), in.Y()) },
+	OP_LD_NN:    func(e *Emulator, in Instruction) { e.setVXtoNN(in.X(), in.NN()) },
+	OP_ADD_NN:   func(e *Emulator, in Instruction) { e.addNNtoVX(in.X(), in.NN()) },
+	OP_LD_VY:    func(e *Emulator, in Instruction) { e.setVXtoVY(in.X(), in.Y()) },
+	OP_OR:       func(e *Emulator, in Instruction) { e.setVXtoVXorVY(in.X(), in.Y()) },
+	OP_AND:      func(e *Emulator, in Instruction) { e.setVXtoVXandVY(in.X(), in.Y()) },
+	OP_XOR:      func(e *Emulator, in Instruction) { e.setVXtoVXxorVY(in.X(), in.Y()) },
+	OP_ADD_VY:   func(e *Emulator, in Instruction) { e.addVYtoVX(in.X(), in.Y()) },
+	OP_SUB:      func(e *Emulator, in Instruction) { e.subVYFromVX(in.X(), in.Y()) },
+	OP_SHR:      func(e *Emulator, in Instruction) { e.shiftVXRight(in.X(), in.Y()) },
+	OP_SUBN:     func(e *Emulator, in Instruction) { e.subVXFromVY(in.X(), in.Y()) },
+	OP_SHL:      func(e *Emulator, in Instruction) { e.shiftVXLeft(in.X(), in.Y()) },
+	OP_SNE_VY:   func(e *Emulator, in Instruction) { e.skipIfVXnotEqualsVY(in.X(), in.Y()) },
+	OP_LD_I:     func(e *Emulator, in Instruction) { e.setItoNNN(in.NNN()) },
+	OP_JP_V0:    func(e *Emulator, in Instruction) { e.jumpToNNNplusV0(in.X(), in.NNN()) },
+	OP_RND:      func(e *Emulator, in Instruction) { e.setVXtoNNNandRand(in.X(), in.NN()) },
+	OP_DRW:      func(e *Emulator, in Instruction) { e.drawSprite(in.X(), in.Y(), in.N()) },
+	OP_SKP:      func(e *Emulator, in Instruction) { e.skipIfPressed(in.X()) },
+	OP_SKNP:     func(e *Emulator, in Instruction) { e.skipIfNotPressed(in.X()) },
+	OP_LD_VX_DT: func(e *Emulator, in Instruction) { e.setVXToDelay(in.X()) },
+	OP_LD_KEY:   func(e *Emulator, in Instruction) { e.waitKeyPress(in.X()) },
+	OP_LD_DT:    func(e *Emulator, in Instruction) { e.setDelayTimerToVX(in.X()) },
+	OP_LD_ST:    func(e *Emulator, in Instruction) { e.setSoundTimerToVX(in.X()) },
+	OP_ADD_I:    func(e *Emulator, in Instruction) { e.addVXtoI(in.X()) },
+	OP_LD_FONT:  func(e *Emulator, in Instruction) { e.setItoChar(in.X()) },
+	OP_BCD:      func(e *Emulator, in Instruction) { e.storeVXatIinBCD(in.X()) },
+	OP_STORE:    func(e *Emulator, in Instruction) { e.storeRegistersInMemory(in.X()) },
+	OP_LOAD:     func(e *Emulator, in Instruction) { e.storeMemInRegisters(in.X()) },
+
+	OP_SCROLL_DOWN:  func(e *Emulator, in Instruction) { e.scrollDown(in.N()) },
+	OP_SCROLL_RIGHT: func(e *Emulator, in Instruction) { e.scrollRight() },
+	OP_SCROLL_LEFT:  func(e *Emulator, in Instruction) { e.scrollLeft() },
+	OP_EXIT:         func(e *Emulator, in Instruction) { e.exitInterpreter() },
+	OP_LORES:        func(e *Emulator, in Instruction) { e.disableHiRes() },
+	OP_HIRES:        func(e *Emulator, in Instruction) { e.enableHiRes() },
+	OP_LD_HIFONT:    func(e *Emulator, in Instruction) { e.setItoHiresChar(in.X()) },
+	OP_SAVE_FLAGS:   func(e *Emulator, in Instruction) { e.storeRegistersToStorage(in.X()) },
+	OP_LOAD_FLAGS:   func(e *Emulator, in Instruction) { e.loadRegistersFromStorage(in.X()) },
+
+	OP_SCROLL_UP:  func(e *Emulator, in Instruction) { e.scrollUp(in.N()) },
+	OP_SAVE_RANGE: func(e *Emulator, in Instruction) { e.saveVXthroughVY(in.X(), in.Y()) },
+	OP_LOAD_RANGE: func(e *Emulator, in Instruction) { e.loadVXthroughVY(in.X(), in.Y()) },
+	OP_LD_I_LONG:  func(e *Emulator, in Instruction) { e.loadHiMem(e.opcodeAt(e.pc + 2)) },
+	OP_AUDIO:      func(e *Emulator, in Instruction) { e.loadAudioPattern() },
+	OP_PLANE:      func(e *Emulator, in Instruction) { e.selectPlane(in.X()) },
+	OP_PITCH:      func(e *Emulator, in Instruction) { e.setAudioPitch(in.X()) },
+
+	OP_MEGA_OFF:    func(e *Emulator, in Instruction) { e.disableMegachip() },
+	OP_MEGA_ON:     func(e *Emulator, in Instruction) { e.enableMegachip() },
+	OP_LD_I_24:     func(e *Emulator, in Instruction) { e.loadHiMem24(in.NN(), e.opcodeAt(e.pc+2)) },
+	OP_PALETTE:     func(e *Emulator, in Instruction) { e.loadPalette(in.NN()) },
+	OP_SPRITE_W:    func(e *Emulator, in Instruction) { e.setSpriteWidth(in.NN()) },
+	OP_SPRITE_H:    func(e *Emulator, in Instruction) { e.setSpriteHeight(in.NN()) },
+	OP_ALPHA:       func(e *Emulator, in Instruction) { e.setScreenAlpha(in.NN()) },
+	OP_SAMPLE:      func(e *Emulator, in Instruction) { e.playSample(in.N()) },
+	OP_STOP_SAMPLE: func(e *Emulator, in Instruction) { e.stopSample() },
+	OP_BLEND:       func(e *Emulator, in Instruction) { e.setBlendMode(in.N()) },
+	OP_COLLISION:   func(e *Emulator, in Instruction) { e.setCollisionColor(in.NN()) },
+
+	OP_BG_STEP:      func(e *Emulator, in Instruction) { e.stepBackground() },
+	OP_ADD_NIBBLES:  func(e *Emulator, in Instruction) { e.addVYtoVXNibbles(in.X(), in.Y()) },
+	OP_ZONE_COLOR:   func(e *Emulator, in Instruction) { e.setZoneColors(in.X(), in.Y()) },
+	OP_SPRITE_COLOR: func(e *Emulator, in Instruction) { e.setSpriteColors(in.X(), in.Y(), in.N()) },
+	OP_SKP2:         func(e *Emulator, in Instruction) { e.skipIfPressed2(in.X()) },
+	OP_SKNP2:        func(e *Emulator, in Instruction) { e.skipIfNotPressed2(in.X()) },
+	OP_OUT:          func(e *Emulator, in Instruction) { e.outputVX(in.X()) },
+	OP_IN:           func(e *Emulator, in Instruction) { e.inputVX(in.X()) },
+}
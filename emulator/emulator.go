@@ -6,34 +6,91 @@ import (
 	"log/slog"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/cockroachdb/errors"
 
+	"github.com/swensone/gorito/asm"
+	"github.com/swensone/gorito/record"
+	"github.com/swensone/gorito/synth"
 	"github.com/swensone/gorito/types"
 )
 
 // memory map
 // 0x000-0x1FF   - Chip 8 interpreter (contains font set in emu)
 // 0x050-0x0A0   - Used for the built in 4x5 pixel font set (0-F)
-// 0x200-0xFFF   - Program ROM and work RAM
+// 0x200-0xFFF   - Program ROM and work RAM (0x300 onwards on chip-8x)
 // 0x1000-0xFFFF - xo-chip high mem range
+//
+// the amount of memory and the depth of the stack depend on the platform being emulated
 
 type EmulatorConfig struct {
-	Savefile   string
-	Mode       types.Mode
-	Speed      uint32
-	ColorMap   map[uint8]types.Color
-	LogOpcodes bool
+	Savefile        string
+	Mode            types.Mode
+	Quirks          types.Quirks
+	Speed           uint32
+	ColorMap        map[uint8]types.Color
+	LogOpcodes      bool
+	RewindFrames    int
+	Faults          types.FaultPolicies
+	MovieRecord     string  // file to record the keypad to, see movie.go
+	MoviePlay       string  // file to play the keypad back from
+	RNG             RNG     // random numbers for CXNN, a pcg generator when nil
+	Seed            int64   // seed for the RNG, applied on every reset
+	VSync           bool    // pace frames with the display's vertical blank instead of the clock, see scheduler.go
+	Turbo           float64 // speed multiplier while the turbo hotkey is held
+	SlowMotion      float64 // speed multiplier while the slow motion hotkey is held
+	Unthrottled     bool    // run frames as fast as possible, for running without a display
+	Frames          int     // frames Run stops after, 0 to run until the program exits
+	ScreenshotDir   string  // directory for screenshots, next to the save file when empty
+	ScreenshotScale int     // factor screenshots and recordings are scaled up by
+	Record          string  // file to record the screen and sound to from the start, see StartRecording
+	RecordFormat    string  // format of recordings started by the hotkey: gif, apng or y4m
+	WAV             string  // file to capture the sound to from the start, see StartAudioCapture
 }
 
+// resolution of the bit planes, low resolution pixels are drawn as 2x2 blocks. megachip has its own, larger, display.
 const (
-	XRES   int32 = 128
-	YRES   int32 = 64
-	PLANES       = 2
+	XRES int32 = 128
+	YRES int32 = 64
 )
 
-func New(cfg EmulatorConfig, display Display, audio Audio, log *slog.Logger) (*Emulator, error) {
+// New creates an emulator. display, audio and input may be nil when embedding the emulator without host devices, in
+// which case the host drives it through Step/RunFrame and SetKey.
+func New(cfg EmulatorConfig, display Display, audio Audio, input Input, log *slog.Logger) (*Emulator, error) {
+	if display == nil {
+		display = nullDisplay{}
+	}
+	if audio == nil {
+		audio = nullAudio{}
+	}
+	if input == nil {
+		input = nullInput{}
+	}
+	if cfg.RNG == nil {
+		cfg.RNG = NewPCG()
+	}
+	if cfg.Turbo <= 0 {
+		cfg.Turbo = DEFAULT_TURBO
+	}
+	if cfg.SlowMotion <= 0 {
+		cfg.SlowMotion = DEFAULT_SLOW_MOTION
+	}
+	if cfg.RecordFormat == "" {
+		cfg.RecordFormat = "gif"
+	}
+
+	// fill in any chip-8x colors the caller hasn't set, without changing the caller's map
+	colorMap := make(map[uint8]types.Color, len(cfg.ColorMap)+len(chip8xColors))
+	for k, c := range chip8xColors {
+		colorMap[k] = c
+	}
+	for k, c := range cfg.ColorMap {
+		colorMap[k] = c
+	}
+	cfg.ColorMap = colorMap
+
 	storage, err := newStorage(cfg.Savefile, log)
 	if err != nil {
 		return nil, err
@@ -44,17 +101,15 @@ func New(cfg EmulatorConfig, display Display, audio Audio, log *slog.Logger) (*E
 		registers: make([]uint8, 16),
 		audio:     audio,
 		display:   display,
+		input:     input,
 		xres:      XRES,
 		yres:      YRES,
-		gfx:       make(map[int][]uint8),
 		plane:     1,
 		storage:   storage,
 		log:       log,
-	}
-
-	for i := range PLANES {
-		e.gfx[i] = make([]uint8, XRES*YRES)
-		e.gfx[i] = make([]uint8, XRES*YRES)
+		rng:       cfg.RNG,
+		requests:  make(chan request),
+		exited:    make(chan struct{}),
 	}
 	e.Reset()
 
@@ -63,20 +118,24 @@ func New(cfg EmulatorConfig, display Display, audio Audio, log *slog.Logger) (*E
 
 type Emulator struct {
 	// basic config
-	cfg EmulatorConfig
-	rom string
+	cfg     EmulatorConfig
+	rom     string
+	program []byte // the last program loaded, for restarting
 
 	// core emulator functionality
 	registers  []uint8
+	platform   types.Platform
+	decoder    *Decoder
 	stack      [16]uint16
 	sp         uint8
-	memory     [64 * 1024]uint8
-	idx        uint16
+	memory     []uint8
+	idx        uint32
 	pc         uint16
 	timer      uint32
 	delayTimer uint8
 	soundTimer uint8
 	counter    uint64
+	frame      int // frames run since the last reset
 
 	// graphics
 	gfx      map[int][]uint8
@@ -85,20 +144,60 @@ type Emulator struct {
 	yres     int32
 	hires    bool
 	drawFlag bool
+	mega     megachip
+	c8x      chip8x
 
 	// audio
 	audio_pattern [16]uint8
 	pitch         uint8
 
 	// key tracking
-	prevKeys [16]bool
-	keys     [16]bool
-	paused   bool
-	finished bool
+	prevKeys   [16]bool
+	keys       [16]bool
+	keys2      [16]bool // chip-8x second keypad
+	polled     InputState
+	paused     bool
+	finished   bool
+	rewinding  bool
+	turbo      bool
+	slowMotion bool
+
+	rewindRefused bool // the rewind key is held during a movie
+
+	// fault raised by the current instruction
+	fault *Fault
+
+	// random numbers for CXNN
+	rng RNG
+
+	// attached debugger, and whether it's holding execution
+	debugger Debugger
+	held     bool
+
+	// assembly error for octo source, shown on screen in place of the program
+	loadErr error
+
+	// per frame snapshots for rewinding
+	rewind *rewindBuffer
+
+	// movie being recorded or played
+	movie *movie
+
+	// video being recorded
+	recorder *record.Recorder
 
-	// interfaces for graphics and sound functionality
+	// sound being captured
+	capture *record.Sound
+
+	// requests from other goroutines to run between instructions, see Do
+	requests chan request
+	exited   chan struct{}
+	exitOnce sync.Once
+
+	// interfaces for graphics, sound and input functionality
 	display Display
 	audio   Audio
+	input   Input
 
 	// persistent storage for superchip and xo-chip
 	storage *storage
@@ -107,6 +206,8 @@ type Emulator struct {
 	log *slog.Logger
 }
 
+// LoadProgram loads a rom from a file. Octo source, with a .8o extension, is assembled first, with any errors marked
+// as ErrAssemble.
 func (e *Emulator) LoadProgram(filepath string) error {
 	if filepath == "" {
 		return errors.New("rom path must be specified")
@@ -116,17 +217,58 @@ func (e *Emulator) LoadProgram(filepath string) error {
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
-	data, err := io.ReadAll(f)
+	e.log.Debug("loading program", "file", fp)
+	if path.Ext(fp) == ".8o" {
+		err = e.loadSource(fp, f)
+	} else {
+		err = e.LoadReader(f)
+	}
 	if err != nil {
 		return err
 	}
+	e.rom = RomName(filepath)
+
+	return nil
+}
+
+// loadSource assembles octo source for the platform's start address and loads the result
+func (e *Emulator) loadSource(name string, r io.Reader) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	program, err := asm.Assemble(name, src, e.platform.Start)
+	if err != nil {
+		return errors.Mark(err, ErrAssemble)
+	}
+	return e.LoadROM(program.ROM)
+}
+
+// LoadReader reads a program from r and loads it into memory at the platform's start address
+func (e *Emulator) LoadReader(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return e.LoadROM(data)
+}
+
+// LoadROM loads a program into memory at the platform's start address, 0x200 on most platforms
+func (e *Emulator) LoadROM(data []byte) error {
+	start := e.platform.Start
+	if start+len(data) > len(e.memory) {
+		return e.newFault(types.FAULT_ROM_SIZE, uint16(start), 0,
+			fmt.Sprintf("rom is %d bytes, only %d bytes are available in %s mode", len(data), len(e.memory)-start, e.cfg.Mode.String()))
+	}
+
+	e.program = append([]byte(nil), data...)
 
-	e.log.Debug("loading program", "file", fp)
 	var upper uint8
 	var lower uint8
 	for i, b := range data {
-		e.memory[0x200+i] = b
+		e.memory[start+i] = b
 
 		if i%2 == 0 {
 			upper = b
@@ -137,85 +279,397 @@ func (e *Emulator) LoadProgram(filepath string) error {
 
 	}
 	e.log.Debug("done loading program")
-	e.rom = RomName(filepath)
 
 	return nil
 }
 
+// Run loads a rom and runs it until the program exits or the user quits. Octo source is watched for changes, and
+// reassembled and restarted whenever it's saved. Unthrottled runs have no one to fix the source or unpause, so they
+// return assembly errors and faults instead.
 func (e *Emulator) Run(rom string) error {
-	if err := e.LoadProgram(rom); err != nil {
+	defer e.exitOnce.Do(func() { close(e.exited) })
+
+	var watcher *sourceWatcher
+	if path.Ext(rom) == ".8o" && !e.cfg.Unthrottled {
+		var err error
+		if watcher, err = newSourceWatcher(rom, e.log); err != nil {
+			return err
+		}
+		defer watcher.Close()
+	}
+	if err := e.reload(rom); err != nil {
 		return errors.Wrapf(err, "unable to open file %s", rom)
 	}
+	if e.loadErr != nil && e.cfg.Unthrottled {
+		return e.loadErr
+	}
+	if err := e.startMovie(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := e.stopMovie(); err != nil {
+			e.log.Error("failed to finish movie", "error", err)
+		}
+	}()
+	// the capture is started first so that it's under the recording's tap on the audio, and stopped after it
+	if e.cfg.WAV != "" {
+		if err := e.StartAudioCapture(e.cfg.WAV); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		if err := e.StopAudioCapture(); err != nil {
+			e.log.Error("failed to finish audio capture", "error", err)
+		}
+	}()
+	if e.cfg.Record != "" {
+		if err := e.StartRecording(e.cfg.Record); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		if err := e.StopRecording(); err != nil {
+			e.log.Error("failed to finish recording", "error", err)
+		}
+	}()
 
-	cyclesTimer := time.Now()
-	lastDraw := time.Now()
-	cycles := 0
+	sched := e.newScheduler()
+	statsTimer := time.Now()
+	frames := 0
 	draws := 0
 	for {
-		start := time.Now()
-
-		// check for keyboard events
+		// check for keyboard events, and anything another goroutine wants done
 		e.setKeys()
+		e.handleRequests()
+
+		// reassemble and restart when the source changes. the file may be missing part way through a save, in which
+		// case the error is shown until the next change.
+		if watcher != nil && watcher.Changed() {
+			if err := e.reload(rom); err != nil {
+				if err := e.showLoadError(err); err != nil {
+					return err
+				}
+			}
+		}
 
-		// if we're paused, skip any cpu or graphics updates
-		if e.paused && !e.finished {
+		// if we're paused or showing an assembly error, skip any cpu or graphics updates
+		if (e.paused || e.loadErr != nil) && !e.finished {
 			time.Sleep(time.Second / 100)
+			sched.reset()
 			continue
 		}
 
-		// fetch/decode/execute opcodes
-		if err := e.execOpcode(); err != nil {
-			return errors.Wrap(err, "failed during exec opcode")
-		}
-
-		// update the display at approx 60hz
-		if time.Since(lastDraw) > time.Second/60 {
-			if e.drawFlag {
-				if err := e.display.Draw(e.getGfx()); err != nil {
-					return errors.Wrap(err, "failed during draw")
-				}
-				e.drawFlag = false
+		// while rewinding, step back a frame on each pass instead of executing
+		if e.rewinding && !e.finished {
+			if err := e.rewindFrame(); err != nil {
+				return errors.Wrap(err, "failed during rewind")
+			}
+			if err := e.present(sched); err != nil {
+				return err
 			}
-			lastDraw = time.Now()
 			draws++
+			sched.wait()
+			continue
+		}
 
-			// Update timers
-			if e.delayTimer > 0 {
-				e.delayTimer--
+		// run the frames owed for this pass. only the first uses freshly polled keys, the rest are latched again
+		// so that movies get a keypad state per frame while fast forwarding.
+		limited := false
+		for i := range sched.frames(e.speedMultiplier()) {
+			if i > 0 {
+				e.latchKeys()
 			}
-
-			if e.soundTimer > 0 {
-				e.audio.Play()
-			} else {
-				e.audio.Stop()
+			if err := e.runFrame(); err != nil {
+				return err
+			}
+			if e.held || e.finished {
+				break
+			}
+			if err := e.rewindFrame(); err != nil {
+				return errors.Wrap(err, "failed to record rewind frame")
 			}
-			if e.soundTimer > 0 {
-				e.soundTimer--
+			frames++
+			if e.cfg.Frames > 0 && e.frame >= e.cfg.Frames {
+				limited = true
+				break
 			}
 		}
 
-		// slow the emulator down to an approximately right speed
-		time.Sleep(time.Until(start.Add(time.Second / time.Duration(e.cfg.Speed))))
+		// while the debugger holds execution, keep the screen up to date but stop the timers
+		if e.held && !e.finished {
+			if err := e.draw(); err != nil {
+				return err
+			}
+			time.Sleep(time.Second / 100)
+			sched.reset()
+			continue
+		}
+
+		if err := e.present(sched); err != nil {
+			return err
+		}
+		draws++
+		if e.finished || limited {
+			return nil
+		}
+		sched.wait()
 
-		cycles++
-		if time.Since(cyclesTimer) > time.Second {
-			e.log.Debug("cycles per second", "cycles", cycles, "draws", draws)
-			cyclesTimer = time.Now()
-			cycles = 0
+		if time.Since(statsTimer) > time.Second {
+			e.log.Debug("frames per second", "frames", frames, "draws", draws)
+			statsTimer = time.Now()
+			frames = 0
 			draws = 0
 		}
+	}
+}
+
+// newScheduler paces Run with the display when vsync is configured and the display can report its refresh rate,
+// falling back to the clock otherwise
+func (e *Emulator) newScheduler() *scheduler {
+	if e.cfg.Unthrottled {
+		s := newScheduler(0)
+		s.unthrottled = true
+		return s
+	}
+	if !e.cfg.VSync {
+		return newScheduler(0)
+	}
+	d, ok := e.display.(VSyncDisplay)
+	if !ok || d.RefreshRate() <= 0 {
+		e.log.Warn("the display can't sync to its refresh rate, using the clock instead")
+		return newScheduler(0)
+	}
+	e.log.Debug("syncing to the display", "refresh", d.RefreshRate())
+	return newScheduler(d.RefreshRate())
+}
+
+// present updates the display at the end of a pass. With vsync the screen is drawn every pass, even when nothing
+// changed, since drawing is what waits for the vertical blank.
+func (e *Emulator) present(sched *scheduler) error {
+	if sched.vsync {
+		e.drawFlag = true
+	}
+	return e.draw()
+}
+
+// Step fetches, decodes and executes a single instruction. If a debugger is attached and holding execution nothing
+// runs.
+func (e *Emulator) Step() error {
+	if err := e.execOpcode(); err != nil {
+		return err
+	}
+	if !e.held {
+		copy(e.prevKeys[:], e.keys[:])
+	}
+	return nil
+}
+
+// RunFrame executes one 60hz frame worth of instructions based on the configured speed, then updates the display and
+// the timers. It returns early if the program exits, or without updating the timers if the debugger holds execution.
+func (e *Emulator) RunFrame() error {
+	if err := e.runFrame(); err != nil {
+		return err
+	}
+	return e.draw()
+}
 
+// runFrame executes a batch of instructions and ticks the timers, without drawing
+func (e *Emulator) runFrame() error {
+	for range e.instructionsPerFrame() {
 		if e.finished {
+			break
+		}
+		if err := e.Step(); err != nil {
+			return errors.Wrap(err, "failed during exec opcode")
+		}
+		if e.held {
 			return nil
 		}
 	}
+	return e.endFrame()
+}
+
+func (e *Emulator) instructionsPerFrame() int {
+	return max(int(e.cfg.Speed)/FRAME_RATE, 1)
+}
+
+// endFrame ticks the delay and sound timers, once per frame
+func (e *Emulator) endFrame() error {
+	e.frame++
+
+	// Update timers
+	if e.delayTimer > 0 {
+		e.delayTimer--
+	}
+
+	if e.soundTimer > 0 {
+		e.audio.Play()
+	} else {
+		e.audio.Stop()
+	}
+	if e.soundTimer > 0 {
+		e.soundTimer--
+	}
+
+	if e.capture != nil {
+		e.captureFrame()
+	}
+	if e.recorder != nil {
+		e.recordFrame()
+	}
+	if e.movie != nil {
+		return e.movieFrame()
+	}
+	return nil
+}
+
+// draw updates the display if anything changed since the last draw
+func (e *Emulator) draw() error {
+	if e.drawFlag {
+		xres, yres := e.Resolution()
+		if err := e.display.Draw(e.getGfx(), xres, yres); err != nil {
+			return errors.Wrap(err, "failed during draw")
+		}
+		e.drawFlag = false
+	}
+	return nil
+}
+
+// SetKey sets the state of a key on the hex keypad, for hosts that drive the emulator without an Input
+func (e *Emulator) SetKey(k uint8, down bool) {
+	e.keys[k&0x0F] = down
+}
+
+// Framebuffer returns the current screen contents as colors, row by row. See Resolution for the dimensions.
+func (e *Emulator) Framebuffer() []types.Color {
+	return e.getGfx()
+}
+
+// ColorMap returns the colors the framebuffer is drawn with, by pixel value
+func (e *Emulator) ColorMap() map[uint8]types.Color {
+	return e.cfg.ColorMap
+}
+
+// Resolution returns the width and height of the framebuffer, which changes when megachip mode is toggled
+func (e *Emulator) Resolution() (int32, int32) {
+	if e.mega.Enabled {
+		return MEGACHIP_XRES, MEGACHIP_YRES
+	}
+	return XRES, YRES
+}
+
+// Registers returns a copy of V0-VF
+func (e *Emulator) Registers() [16]uint8 {
+	var regs [16]uint8
+	copy(regs[:], e.registers)
+	return regs
+}
+
+// PC returns the program counter
+func (e *Emulator) PC() uint16 {
+	return e.pc
+}
+
+// Index returns the I register, which is 24 bits wide on megachip
+func (e *Emulator) Index() uint32 {
+	return e.idx
+}
+
+// SP returns the stack pointer, the number of return addresses on the stack
+func (e *Emulator) SP() uint8 {
+	return e.sp
+}
+
+// Stack returns the return addresses on the stack, oldest first
+func (e *Emulator) Stack() []uint16 {
+	return append([]uint16{}, e.stack[:e.sp]...)
+}
+
+// Timers returns the delay and sound timers
+func (e *Emulator) Timers() (uint8, uint8) {
+	return e.delayTimer, e.soundTimer
+}
+
+// Platform returns the platform being emulated
+func (e *Emulator) Platform() types.Platform {
+	return e.platform
+}
+
+// ReadMemory returns a copy of n bytes of memory from addr, wrapping around the end of memory
+func (e *Emulator) ReadMemory(addr uint32, n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = e.memory[(int(addr)+i)%len(e.memory)]
+	}
+	return data
+}
+
+// SetRegister sets one of V0-VF. Like the other setters it must only be called while the emulator isn't running, see
+// the debugger package's Modify.
+func (e *Emulator) SetRegister(x uint8, val uint8) {
+	e.registers[x&0x0F] = val
+}
+
+// SetPC sets the program counter
+func (e *Emulator) SetPC(pc uint16) {
+	e.pc = pc
+}
+
+// SetIndex sets the I register
+func (e *Emulator) SetIndex(idx uint32) {
+	e.idx = idx
+}
+
+// SetSP sets the stack pointer, which can't be deeper than the platform's stack
+func (e *Emulator) SetSP(sp uint8) error {
+	if int(sp) > e.platform.StackDepth {
+		return errors.Newf("stack pointer %d is deeper than the %d level stack", sp, e.platform.StackDepth)
+	}
+	e.sp = sp
+	return nil
+}
+
+// SetTimers sets the delay and sound timers
+func (e *Emulator) SetTimers(delay, sound uint8) {
+	e.delayTimer, e.soundTimer = delay, sound
+}
+
+// WriteMemory copies data into memory from addr, wrapping around the end of memory
+func (e *Emulator) WriteMemory(addr uint32, data []byte) {
+	for i, b := range data {
+		e.memory[(int(addr)+i)%len(e.memory)] = b
+	}
+}
+
+// SetDebugger attaches a debugger, or detaches it when d is nil. It should be called before the emulator starts
+// running.
+func (e *Emulator) SetDebugger(d Debugger) {
+	e.debugger = d
+	e.held = false
+}
+
+// Frame returns the number of frames run since the program was loaded
+func (e *Emulator) Frame() int {
+	return e.frame
+}
+
+// Finished returns true once the program has exited or the user has quit
+func (e *Emulator) Finished() bool {
+	return e.finished
 }
 
 func (e *Emulator) getGfx() []types.Color {
+	if e.mega.Enabled {
+		return e.megachipGfx()
+	}
+	if e.platform.Chip8X {
+		return e.chip8xGfx()
+	}
+
 	res := make([]types.Color, XRES*YRES)
 	for i := range XRES * YRES {
 		var val uint8
-		for j := range PLANES {
+		for j := range e.platform.Planes {
 			val |= e.gfx[j][i] << j
 		}
 		res[i] = e.cfg.ColorMap[val]
@@ -224,21 +678,37 @@ func (e *Emulator) getGfx() []types.Color {
 }
 
 func (e *Emulator) Reset() {
-	e.pc = 0x200       // Program counter starts at 0x200
+	e.platform = types.PlatformForMode(e.cfg.Mode)
+	e.decoder = DecoderFor(e.platform)
+
+	// Program counter starts at 0x200, or 0x300 on chip-8x
+	e.pc = uint16(e.platform.Start)
+
 	e.idx = 0          // Reset index register
 	e.sp = 0           // Reset stack pointer
 	e.delayTimer = 0   // Reset delay timer
 	e.soundTimer = 0   // Reset sound timer
 	e.timer = 0        // Reset timer counter
 	e.counter = 0      // Reset counter
+	e.frame = 0        // Reset frame counter
 	e.paused = false   // Unpause if paused
 	e.finished = false // Reset the finished flag
+	e.fault = nil      // Drop any pending fault
+	e.held = false     // Check in with the debugger again
+	e.rng.Seed(e.cfg.Seed)
 
 	// Clear graphics memory and reset graphics variables
-	e.plane = 3
-	e.clearDisplay()
+	e.gfx = make(map[int][]uint8)
+	for i := range e.platform.Planes {
+		e.gfx[i] = make([]uint8, XRES*YRES)
+	}
+	e.xres = XRES
+	e.yres = YRES
 	e.plane = 1
 	e.hires = false
+	e.drawFlag = true
+	e.mega = newMegachip(e.platform)
+	e.c8x = newChip8x()
 
 	// Clear stack
 	for i := range e.stack {
@@ -250,24 +720,33 @@ func (e *Emulator) Reset() {
 		e.registers[i] = 0
 	}
 
-	// Clear memory
-	for i := range e.memory {
-		e.memory[i] = 0
-	}
+	// Clear memory, sized for the platform
+	e.memory = make([]uint8, e.platform.MemorySize)
 
 	// Clear audio
 	// set values to approximately A4 as a default
-	e.pitch = 247
-	for i := range e.audio_pattern {
-		if i < len(e.audio_pattern)/2 {
-			e.audio_pattern[i] = 0x00
+	e.pitch = synth.DEFAULT_PITCH
+	e.audio_pattern = synth.DEFAULT_PATTERN
+	e.loadAudio()
+
+	// Clear rewind history. Snapshotting megachip's 16MB of memory every frame is too slow, so rewinding is limited to
+	// the smaller platforms.
+	e.rewind = nil
+	if e.cfg.RewindFrames > 0 {
+		if e.platform.MemorySize <= REWIND_MAX_MEMORY {
+			e.rewind = newRewindBuffer(e.cfg.RewindFrames)
 		} else {
-			e.audio_pattern[i] = 0xff
+			e.log.Warn("rewind isn't available for this mode, it has too much memory to snapshot every frame",
+				"mode", e.cfg.Mode.String(), "memory", e.platform.MemorySize)
 		}
 	}
 
 	// Load the fonts
-	for i, val := range fontSet {
+	font := fontSet
+	if e.platform.Font == types.FONT_VIP {
+		font = vipFontSet
+	}
+	for i, val := range font {
 		e.memory[FONT_OFFSET+i] = val
 	}
 
@@ -0,0 +1,432 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/record"
+	"github.com/swensone/gorito/types"
+)
+
+func newTestEmulator(t *testing.T, mode types.Mode) *Emulator {
+	e, err := New(EmulatorConfig{
+		Savefile: filepath.Join(t.TempDir(), "saves.json"),
+		Mode:     mode,
+		Quirks:   types.QuirksForMode(mode),
+		Speed:    600,
+		ColorMap: map[uint8]types.Color{},
+	}, nil, nil, nil, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// runHeadless writes a program to a file called name in a temporary directory and runs it headless for up to frames
+// frames. configure, when given, adjusts the config before the emulator is created, for example to put output files in
+// the directory, which is returned with the emulator and the result of Run.
+func runHeadless(t *testing.T, mode types.Mode, name string, program []byte, frames int,
+	configure func(cfg *EmulatorConfig, dir string)) (*Emulator, string, error) {
+	e, dir, rom := newHeadless(t, mode, name, program, frames, configure)
+	return e, dir, e.Run(rom)
+}
+
+// newHeadless does the setup for runHeadless and returns the emulator, the directory and the path of the program
+// without running it, so tests can call Run from another goroutine.
+func newHeadless(t *testing.T, mode types.Mode, name string, program []byte, frames int,
+	configure func(cfg *EmulatorConfig, dir string)) (*Emulator, string, string) {
+	dir := t.TempDir()
+	rom := filepath.Join(dir, name)
+	if err := os.WriteFile(rom, program, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := EmulatorConfig{
+		Savefile:    filepath.Join(dir, "saves.json"),
+		Mode:        mode,
+		Quirks:      types.QuirksForMode(mode),
+		Speed:       600,
+		Unthrottled: true,
+		Frames:      frames,
+	}
+	if configure != nil {
+		configure(&cfg, dir)
+	}
+	e, err := New(cfg, nil, nil, nil, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, dir, rom
+}
+
+func TestStep(t *testing.T) {
+	tests := []struct {
+		name  string
+		rom   []byte
+		steps int
+		pc    uint16
+		v0    uint8
+	}{
+		{
+			"set and add",
+			[]byte{0x60, 0x05, 0x70, 0x01},
+			2,
+			0x204,
+			0x06,
+		},
+		{
+			"jump to self",
+			[]byte{0x60, 0x01, 0x12, 0x02},
+			5,
+			0x202,
+			0x01,
+		},
+		{
+			"call and return",
+			[]byte{0x22, 0x04, 0x12, 0x02, 0x60, 0x09, 0x00, 0xEE},
+			3,
+			0x202,
+			0x09,
+		},
+		{
+			"skip if pressed with vx above 15",
+			[]byte{0x60, 0xFF, 0xE0, 0x9E, 0x60, 0x01},
+			3,
+			0x206,
+			0x01,
+		},
+		{
+			"skip if not pressed with vx above 15",
+			[]byte{0x60, 0x13, 0xE0, 0xA1, 0x60, 0x01, 0x60, 0x02},
+			3,
+			0x208,
+			0x02,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEmulator(t, types.MODE_CHIP8)
+			if err := e.LoadROM(tt.rom); err != nil {
+				t.Fatal(err)
+			}
+			for range tt.steps {
+				if err := e.Step(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			assert.Equal(t, e.PC(), tt.pc)
+			assert.Equal(t, e.Registers()[0], tt.v0)
+		})
+	}
+}
+
+func TestSetKeyWaitsForRelease(t *testing.T) {
+	e := newTestEmulator(t, types.MODE_CHIP8)
+	// F10A: wait for a key and store it in V1
+	if err := e.LoadROM([]byte{0xF1, 0x0A}); err != nil {
+		t.Fatal(err)
+	}
+
+	e.Step()
+	assert.Equal(t, e.PC(), uint16(0x200))
+
+	e.SetKey(0x3, true)
+	e.Step()
+	assert.Equal(t, e.PC(), uint16(0x200))
+
+	e.SetKey(0x3, false)
+	e.Step()
+	assert.Equal(t, e.PC(), uint16(0x202))
+	assert.Equal(t, e.Registers()[1], uint8(0x3))
+}
+
+func TestRunFrame(t *testing.T) {
+	e := newTestEmulator(t, types.MODE_CHIP8)
+	// set the delay timer to 10, then spin
+	if err := e.LoadROM([]byte{0x60, 0x0A, 0xF0, 0x15, 0x12, 0x04}); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		if err := e.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, e.delayTimer, uint8(7))
+	assert.Equal(t, len(e.Framebuffer()), int(XRES*YRES))
+}
+
+func TestRunFrameLimit(t *testing.T) {
+	// set the delay timer to 200, then spin. 120 frames take two seconds at 60hz.
+	start := time.Now()
+	e, _, err := runHeadless(t, types.MODE_CHIP8, "spin.ch8", []byte{0x60, 0xC8, 0xF0, 0x15, 0x12, 0x04}, 120, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, time.Since(start) < time.Second, true)
+	assert.Equal(t, e.Frame(), 120)
+	assert.Equal(t, e.delayTimer, uint8(80))
+}
+
+func TestHeadlessStops(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		program string
+		err     string
+	}{
+		{"assembly error", "broken.8o", ": main jump nowhere", "undefined name nowhere"},
+		{"fault that would break", "underflow.ch8", "\x00\xEE", "stack_underflow at 200"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _, rom := newHeadless(t, types.MODE_CHIP8, tt.file, []byte(tt.program), 10,
+				func(cfg *EmulatorConfig, dir string) {
+					cfg.Faults = types.FaultPolicies{types.FAULT_STACK_UNDERFLOW: types.FAULT_BREAK}
+				})
+			done := make(chan error, 1)
+			go func() {
+				done <- e.Run(rom)
+			}()
+
+			select {
+			case err := <-done:
+				assert.Matches(t, fmt.Sprint(err), tt.err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("headless run didn't stop")
+			}
+		})
+	}
+}
+
+func TestSaveLoadState(t *testing.T) {
+	e := newTestEmulator(t, types.MODE_XOCHIP)
+	// V0 = 1, I = 0x50, draw the font 0 sprite, then increment V0 forever
+	if err := e.LoadROM([]byte{0x60, 0x01, 0xA0, 0x50, 0xD0, 0x05, 0x70, 0x01, 0x12, 0x06}); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		e.Step()
+	}
+
+	var buf bytes.Buffer
+	if err := e.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := e.snapshot()
+
+	for range 10 {
+		e.Step()
+	}
+	e.Reset()
+
+	if err := e.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, e.snapshot(), saved)
+
+	if err := e.LoadState(bytes.NewBufferString("not a state")); err == nil {
+		t.Fatal("expected an error loading an invalid state")
+	}
+}
+
+func TestLoadInvalidState(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(st *machineState)
+		err    string
+	}{
+		{"stack pointer too deep", func(st *machineState) { st.SP = 200 }, "stack pointer 200 is deeper"},
+		{"different mode", func(st *machineState) { st.Mode = types.MODE_SUPERCHIP }, "save state is for superchip, not chip-48"},
+		{"wrong memory size", func(st *machineState) { st.Memory = st.Memory[:16] }, "does not match"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEmulator(t, types.MODE_CHIP48)
+			st := e.snapshot()
+			tt.modify(&st)
+
+			var buf bytes.Buffer
+			if _, err := buf.WriteString(STATE_MAGIC); err != nil {
+				t.Fatal(err)
+			}
+			if err := binary.Write(&buf, binary.BigEndian, STATE_VERSION); err != nil {
+				t.Fatal(err)
+			}
+			if err := gob.NewEncoder(&buf).Encode(st); err != nil {
+				t.Fatal(err)
+			}
+			saved := e.snapshot()
+
+			err := e.LoadState(&buf)
+			assert.Matches(t, fmt.Sprint(err), tt.err)
+			assert.Equal(t, e.snapshot(), saved, "state unchanged")
+			assert.Equal(t, e.Mode(), types.Mode(types.MODE_CHIP48))
+		})
+	}
+}
+
+func TestRewind(t *testing.T) {
+	e := newTestEmulator(t, types.MODE_XOCHIP)
+	e.cfg.RewindFrames = 3
+	e.Reset()
+	// increment V0 and store it at 0x300 forever
+	if err := e.LoadROM([]byte{0x70, 0x01, 0xA3, 0x00, 0xF0, 0x55, 0x12, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+
+	var values []uint8
+	for range 5 {
+		for range 4 {
+			e.Step()
+		}
+		values = append(values, e.Registers()[0])
+		e.frame++
+		if err := e.rewindFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// three frames of history are kept behind the current state
+	e.rewinding = true
+	for i := 3; i >= 1; i-- {
+		if err := e.rewindFrame(); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, e.Registers()[0], values[i])
+		assert.Equal(t, e.memory[0x300], values[i])
+		assert.Equal(t, e.Frame(), i+1)
+	}
+	if err := e.rewindFrame(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, e.Registers()[0], values[1])
+}
+
+func TestScreenshot(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   types.Mode
+		hires  bool
+		scale  int
+		width  int
+		height int
+	}{
+		{"low resolution", types.MODE_CHIP8, false, 1, 64, 32},
+		{"high resolution", types.MODE_SUPERCHIP, true, 1, 128, 64},
+		{"scaled", types.MODE_CHIP8, false, 3, 192, 96},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEmulator(t, tt.mode)
+			e.cfg.ScreenshotDir = t.TempDir()
+			e.cfg.ScreenshotScale = tt.scale
+			e.rom = "game"
+			if tt.hires {
+				e.enableHiRes()
+			}
+
+			fpath, err := e.Screenshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Matches(t, filepath.Base(fpath), `^game-\d{8}-\d{6}\.\d{3}\.png$`)
+
+			f, err := os.Open(fpath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			img, err := png.Decode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, img.Bounds().Dx(), tt.width)
+			assert.Equal(t, img.Bounds().Dy(), tt.height)
+		})
+	}
+}
+
+func TestRecording(t *testing.T) {
+	// beep for 10 frames, then spin
+	e, dir, err := runHeadless(t, types.MODE_CHIP8, "beep.ch8", []byte{0x60, 0x0A, 0xF0, 0x18, 0x12, 0x04}, 30,
+		func(cfg *EmulatorConfig, dir string) { cfg.Record = filepath.Join(dir, "beep.y4m") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, e.recorder == nil, true, "recording stopped")
+
+	video, err := os.Stat(filepath.Join(dir, "beep.y4m"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, video.Size() > int64(30*XRES*YRES*3), true)
+	wav, err := os.ReadFile(filepath.Join(dir, "beep.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(wav), record.WAV_HEADER_SIZE+30*record.SAMPLES_PER_FRAME*2)
+}
+
+func TestAudioCapture(t *testing.T) {
+	// play a pattern with 4 bits on for 5 frames at 8000 bits/s, 6 samples a bit, then spin. The bit edges are checked
+	// a sample away as the position through the pattern is a float.
+	program := []byte{
+		0xA2, 0x0E, // i = pattern
+		0xF0, 0x02, // load the pattern
+		0x60, 0x70, // v0 = 112
+		0xF0, 0x3A, // pitch v0
+		0x60, 0x05, // v0 = 5
+		0xF0, 0x18, // sound timer v0
+		0x12, 0x0C, // spin
+		0xF0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	}
+	e, dir, err := runHeadless(t, types.MODE_XOCHIP, "pattern.ch8", program, 10,
+		func(cfg *EmulatorConfig, dir string) { cfg.WAV = filepath.Join(dir, "pattern.wav") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, e.capture == nil, true, "capture stopped")
+
+	wav, err := os.ReadFile(filepath.Join(dir, "pattern.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(wav), record.WAV_HEADER_SIZE+10*record.SAMPLES_PER_FRAME*2)
+	sample := func(i int) int16 {
+		return int16(binary.LittleEndian.Uint16(wav[record.WAV_HEADER_SIZE+i*2:]))
+	}
+
+	tests := []struct {
+		name  string
+		index int
+		value int16
+	}{
+		{"first bit on", 0, 8192},
+		{"last bit on", 22, 8192},
+		{"bits off", 25, -8192},
+		{"end of the pattern", 766, -8192},
+		{"pattern repeats", 769, 8192},
+		{"stopped", 5 * record.SAMPLES_PER_FRAME, 0},
+		{"last sample", 10*record.SAMPLES_PER_FRAME - 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, sample(tt.index), tt.value)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package emulator
+
+import (
+	"fmt"
+
+	"github.com/swensone/gorito/types"
+)
+
+// Fault is returned from Step, RunFrame and Run when the cpu does something the platform can't, and the fault's policy
+// is to halt. It holds a dump of the machine at the time of the fault.
+type Fault struct {
+	Kind      types.FaultKind
+	PC        uint16
+	Opcode    uint16
+	Registers [16]uint8
+	Idx       uint32
+	SP        uint8
+	Stack     [16]uint16
+	Detail    string
+}
+
+func (f *Fault) Error() string {
+	msg := fmt.Sprintf("%s at %03X (opcode %04X)", f.Kind.String(), f.PC, f.Opcode)
+	if f.Detail != "" {
+		msg += ": " + f.Detail
+	}
+	return msg
+}
+
+// newFault creates a fault for the instruction at pc with the current machine state
+func (e *Emulator) newFault(kind types.FaultKind, pc, opcode uint16, detail string) *Fault {
+	return &Fault{
+		Kind:      kind,
+		PC:        pc,
+		Opcode:    opcode,
+		Registers: e.Registers(),
+		Idx:       e.idx,
+		SP:        e.sp,
+		Stack:     e.stack,
+		Detail:    detail,
+	}
+}
+
+// raise records a fault in the current instruction, which is handled once the instruction finishes. Only the first
+// fault in an instruction is kept.
+func (e *Emulator) raise(kind types.FaultKind, detail string) {
+	if e.fault != nil {
+		return
+	}
+	e.fault = e.newFault(kind, 0, 0, detail)
+}
+
+// handleFault applies the configured policy to a fault, returning it if the cpu should halt
+func (e *Emulator) handleFault(f *Fault) error {
+	switch e.cfg.Faults.Policy(f.Kind) {
+	case types.FAULT_CONTINUE:
+		e.log.Error("cpu fault", "fault", f.Error())
+		return nil
+	case types.FAULT_BREAK:
+		// break into the debugger if there is one, otherwise pause
+		if e.debugger != nil {
+			e.log.Error("cpu fault, breaking", "fault", f.Error())
+			e.debugger.Fault(f)
+			return nil
+		}
+		// without a debugger, an unthrottled run has no one to unpause it so it halts
+		if e.cfg.Unthrottled {
+			return f
+		}
+		e.log.Error("cpu fault, pausing", "fault", f.Error())
+		e.paused = true
+		return nil
+	}
+	return f
+}
@@ -0,0 +1,120 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/types"
+)
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		rom      []byte
+		policies types.FaultPolicies
+		steps    int
+		kind     types.FaultKind
+		halted   bool
+		pc       uint16
+	}{
+		{
+			"stack underflow halts",
+			[]byte{0x00, 0xEE},
+			nil,
+			1,
+			types.FAULT_STACK_UNDERFLOW,
+			true,
+			0x200,
+		},
+		{
+			"stack overflow halts",
+			[]byte{0x22, 0x00},
+			nil,
+			13,
+			types.FAULT_STACK_OVERFLOW,
+			true,
+			0x200,
+		},
+		{
+			"unknown opcode continues",
+			[]byte{0x00, 0x01, 0x60, 0x01},
+			nil,
+			2,
+			types.FAULT_UNKNOWN_OPCODE,
+			false,
+			0x204,
+		},
+		{
+			"unknown opcode halts by policy",
+			[]byte{0x00, 0x01},
+			types.FaultPolicies{types.FAULT_UNKNOWN_OPCODE: types.FAULT_HALT},
+			1,
+			types.FAULT_UNKNOWN_OPCODE,
+			true,
+			0x200,
+		},
+		{
+			"xo-chip save range halts chip-8",
+			[]byte{0x51, 0x22},
+			types.FaultPolicies{types.FAULT_UNKNOWN_OPCODE: types.FAULT_HALT},
+			1,
+			types.FAULT_UNKNOWN_OPCODE,
+			true,
+			0x200,
+		},
+		{
+			"superchip hires halts chip-8",
+			[]byte{0x00, 0xFF},
+			types.FaultPolicies{types.FAULT_UNKNOWN_OPCODE: types.FAULT_HALT},
+			1,
+			types.FAULT_UNKNOWN_OPCODE,
+			true,
+			0x200,
+		},
+		{
+			"memory bounds halts by policy",
+			[]byte{0xAF, 0xFF, 0xF1, 0x65},
+			types.FaultPolicies{types.FAULT_MEMORY_BOUNDS: types.FAULT_HALT},
+			2,
+			types.FAULT_MEMORY_BOUNDS,
+			true,
+			0x202,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEmulator(t, types.MODE_CHIP8)
+			e.cfg.Faults = tt.policies
+			if err := e.LoadROM(tt.rom); err != nil {
+				t.Fatal(err)
+			}
+
+			var err error
+			for range tt.steps {
+				if err = e.Step(); err != nil {
+					break
+				}
+			}
+
+			var fault *Fault
+			assert.Equal(t, errors.As(err, &fault), tt.halted)
+			if tt.halted {
+				assert.Equal(t, fault.Kind, tt.kind)
+				assert.Equal(t, fault.PC, tt.pc)
+			}
+			assert.Equal(t, e.PC(), tt.pc)
+		})
+	}
+}
+
+func TestLoadROMTooLarge(t *testing.T) {
+	e := newTestEmulator(t, types.MODE_CHIP8)
+	err := e.LoadROM(make([]byte, 4096))
+
+	var fault *Fault
+	assert.Equal(t, errors.As(err, &fault), true)
+	assert.Equal(t, fault.Kind, types.FAULT_ROM_SIZE)
+}
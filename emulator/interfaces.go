@@ -7,8 +7,36 @@ type Audio interface {
 	Stop()
 	LoadPattern(pattern [16]uint8)
 	SetPitch(pitch uint8)
+	// PlaySample plays 8 bit unsigned pcm audio, used by megachip
+	PlaySample(rate int, samples []uint8, loop bool)
+	StopSample()
 }
 
 type Display interface {
-	Draw(gfx []types.Color) error
+	// Draw renders xres*yres colors, row by row
+	Draw(gfx []types.Color, xres, yres int32) error
+}
+
+// VSyncDisplay is a display whose Draw waits for the vertical blank, which Run can pace frames with instead of the
+// clock
+type VSyncDisplay interface {
+	Display
+	// RefreshRate returns the display's refresh rate in hz, or 0 if it's unknown
+	RefreshRate() int
+}
+
+type Input interface {
+	Poll() InputState
+}
+
+// Debugger is notified by the emulator as it executes, see the debugger package for an implementation. It's called
+// from the goroutine running the emulator.
+type Debugger interface {
+	// Break is called before each instruction executes, returning true holds execution before it. It's called again
+	// for the same instruction until it returns false.
+	Break(in Instruction) bool
+	// MemoryAccess is called for each byte of memory an instruction reads or writes
+	MemoryAccess(addr uint32, write bool)
+	// Fault is called for faults with the break policy
+	Fault(f *Fault)
 }
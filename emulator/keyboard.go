@@ -1,59 +1,96 @@
 package emulator
 
-import (
-	"github.com/veandco/go-sdl2/sdl"
-)
+// EventType identifies a host hotkey, as opposed to a key on the chip-8 keypad
+type EventType int
 
-/*
-CHIP-8 has an odd hex-based keypad layout:
-1	2	3	C
-4	5	6	D
-7	8	9	E
-A	0	B	F
+const (
+	EVENT_PAUSE EventType = iota
+	EVENT_QUIT
+	EVENT_SAVE_STATE  // Arg is the slot, 0-9
+	EVENT_LOAD_STATE  // Arg is the slot, 0-9
+	EVENT_REWIND      // sent on every poll while the rewind key is held
+	EVENT_TURBO       // sent on every poll while the turbo key is held
+	EVENT_SLOW_MOTION // sent on every poll while the slow motion key is held
+	EVENT_SCREENSHOT
+	EVENT_RECORD // starts or stops recording video
+)
 
-We remap the first four columns of the standard ASCII keyboard in order to support this as best as possible.
-*/
+// Event is a hotkey triggered since the last poll. Arg carries any value associated with the hotkey.
+type Event struct {
+	Type EventType
+	Arg  int
+}
 
-var keymap = map[int]int{
-	sdl.SCANCODE_1: 0x1, // map key 1 to 1
-	sdl.SCANCODE_2: 0x2, // map key 2 to 2
-	sdl.SCANCODE_3: 0x3, // map key 3 to 3
-	sdl.SCANCODE_4: 0xC, // map key 4 to C
-	sdl.SCANCODE_Q: 0x4, // map key Q to 4
-	sdl.SCANCODE_W: 0x5, // map key W to 5
-	sdl.SCANCODE_E: 0x6, // map key E to 6
-	sdl.SCANCODE_R: 0xD, // map key R to D
-	sdl.SCANCODE_A: 0x7, // map key A to 7
-	sdl.SCANCODE_S: 0x8, // map key S to 8
-	sdl.SCANCODE_D: 0x9, // map key D to 9
-	sdl.SCANCODE_F: 0xE, // map key F to E
-	sdl.SCANCODE_Z: 0xA, // map key Z to A
-	sdl.SCANCODE_X: 0x0, // map key X to 0
-	sdl.SCANCODE_C: 0xB, // map key C to B
-	sdl.SCANCODE_V: 0xF, // map key V to F
+// InputState is the state of the 16 key hex keypad plus any hotkey events since the last poll
+type InputState struct {
+	Keys   [16]bool
+	Keys2  [16]bool // the second keypad, only used by chip-8x
+	Events []Event
 }
 
+// setKeys polls the input for the keypad state and hotkeys. prevKeys is updated after each instruction in Step, so that
+// FX0A can detect key releases.
 func (e *Emulator) setKeys() {
-	copy(e.prevKeys[:], e.keys[:])
+	state := e.input.Poll()
+	e.polled = state
+	e.latchKeys()
+	e.rewinding = false
+	e.turbo = false
+	e.slowMotion = false
+	rewindRefused := false
 
-	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-		switch ke := event.(type) {
-		case *sdl.KeyboardEvent:
-			if ke.Type == sdl.KEYUP && ke.Keysym.Scancode == sdl.SCANCODE_P {
-				e.paused = !e.paused
-			}
-		case *sdl.QuitEvent:
+	for _, ev := range state.Events {
+		switch ev.Type {
+		case EVENT_PAUSE:
+			e.paused = !e.paused
+		case EVENT_QUIT:
 			e.finished = true
+		case EVENT_REWIND:
+			if e.movie != nil {
+				// the movie can't go back in time with the machine, sent every poll so only logged when first pressed
+				if !e.rewindRefused {
+					e.log.Warn("rewind isn't available while a movie is recording or playing")
+				}
+				rewindRefused = true
+				continue
+			}
+			e.rewinding = true
+		case EVENT_TURBO:
+			e.turbo = true
+		case EVENT_SLOW_MOTION:
+			e.slowMotion = true
+		case EVENT_SAVE_STATE:
+			if err := e.SaveSlot(ev.Arg); err != nil {
+				e.log.Error("unable to save state", "slot", ev.Arg, "error", err)
+			}
+		case EVENT_LOAD_STATE:
+			if e.movie != nil {
+				e.log.Warn("loading a state isn't available while a movie is recording or playing", "slot", ev.Arg)
+				continue
+			}
+			if err := e.LoadSlot(ev.Arg); err != nil {
+				e.log.Error("unable to load state", "slot", ev.Arg, "error", err)
+			}
+		case EVENT_SCREENSHOT:
+			if _, err := e.Screenshot(); err != nil {
+				e.log.Error("unable to save screenshot", "error", err)
+			}
+		case EVENT_RECORD:
+			if err := e.toggleRecording(); err != nil {
+				e.log.Error("unable to record", "error", err)
+			}
 		}
 	}
+	e.rewindRefused = rewindRefused
+}
 
-	keyState := sdl.GetKeyboardState()
-
-	for key, mapped := range keymap {
-		e.keys[mapped] = keyState[key] == 1
-	}
-
-	if keyState[sdl.SCANCODE_ESCAPE] == 1 {
-		e.finished = true
+// latchKeys sets the keypad from the last poll, or from the movie for the current frame. It's called again for each
+// extra frame run without polling while fast forwarding.
+func (e *Emulator) latchKeys() {
+	state := InputState{Keys: e.polled.Keys, Keys2: e.polled.Keys2}
+	if e.movie != nil {
+		e.movieKeys(&state)
 	}
+	e.keys = state.Keys
+	e.keys2 = state.Keys2
 }
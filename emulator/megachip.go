@@ -0,0 +1,279 @@
+package emulator
+
+import "github.com/swensone/gorito/types"
+
+const (
+	MEGACHIP_XRES int32 = 256
+	MEGACHIP_YRES int32 = 192
+)
+
+// megachip sprite blend modes, selected with 080N
+const (
+	BLEND_NORMAL = iota
+	BLEND_25
+	BLEND_50
+	BLEND_75
+	BLEND_ADD
+	BLEND_MULTIPLY
+)
+
+// megachip holds the state of the megachip display. Sprites are 8 bit palette indexes, blended into the back buffer as
+// they're drawn, and 00E0 presents the back buffer and clears it for the next frame. Fields are exported so they can
+// be saved in save states.
+type megachip struct {
+	Enabled        bool
+	Palette        [256]types.Color
+	Alpha          [256]uint8
+	SpriteWidth    uint8 // 0 means 256
+	SpriteHeight   uint8 // 0 means 256
+	ScreenAlpha    uint8
+	BlendMode      uint8
+	CollisionColor uint8
+	Back           []types.Color
+	Front          []types.Color
+	Indexes        []uint8 // the palette index drawn at each pixel of the back buffer, for collision detection
+}
+
+func newMegachip(platform types.Platform) megachip {
+	m := megachip{
+		SpriteWidth:  8,
+		SpriteHeight: 8,
+		ScreenAlpha:  0xFF,
+	}
+	for i := range m.Palette {
+		m.Palette[i] = types.Color{R: 0xFF, G: 0xFF, B: 0xFF}
+		m.Alpha[i] = 0xFF
+	}
+	m.Palette[0] = types.Color{}
+
+	// only allocate the display buffers when they can be used, they'd be dead weight in every rewind frame otherwise
+	if platform.Megachip {
+		m.Back = make([]types.Color, MEGACHIP_XRES*MEGACHIP_YRES)
+		m.Front = make([]types.Color, MEGACHIP_XRES*MEGACHIP_YRES)
+		m.Indexes = make([]uint8, MEGACHIP_XRES*MEGACHIP_YRES)
+	}
+	return m
+}
+
+// clone returns a deep copy of the megachip state
+func (m megachip) clone() megachip {
+	m.Back = append([]types.Color(nil), m.Back...)
+	m.Front = append([]types.Color(nil), m.Front...)
+	m.Indexes = append([]uint8(nil), m.Indexes...)
+	return m
+}
+
+// disableMegachip: 0010: Disable megachip mode
+func (e *Emulator) disableMegachip() {
+	e.mega.Enabled = false
+	e.drawFlag = true
+}
+
+// enableMegachip: 0011: Enable megachip mode
+func (e *Emulator) enableMegachip() {
+	e.mega.Enabled = true
+	e.drawFlag = true
+}
+
+// loadHiMem24: 01NN NNNN: Load I with the 24 bit address NNNNNN
+func (e *Emulator) loadHiMem24(NN uint8, NNNN uint16) {
+	e.pc += 2
+	e.idx = uint32(NN)<<16 | uint32(NNNN)
+}
+
+// loadPalette: 02NN: Load NN colors from I into the palette, starting at index 1. Each color is 4 bytes of ARGB.
+func (e *Emulator) loadPalette(NN uint8) {
+	for i := range int(NN) {
+		addr := int(e.idx) + i*4
+		e.mega.Alpha[i+1] = e.readMem(addr)
+		e.mega.Palette[i+1] = types.Color{
+			R: e.readMem(addr + 1),
+			G: e.readMem(addr + 2),
+			B: e.readMem(addr + 3),
+		}
+	}
+}
+
+// setSpriteWidth: 03NN: Set the sprite width to NN, 0 means 256
+func (e *Emulator) setSpriteWidth(NN uint8) {
+	e.mega.SpriteWidth = NN
+}
+
+// setSpriteHeight: 04NN: Set the sprite height to NN, 0 means 256
+func (e *Emulator) setSpriteHeight(NN uint8) {
+	e.mega.SpriteHeight = NN
+}
+
+// setScreenAlpha: 05NN: Set the alpha of the whole screen to NN
+func (e *Emulator) setScreenAlpha(NN uint8) {
+	e.mega.ScreenAlpha = NN
+	e.drawFlag = true
+}
+
+// playSample: 060N: Play the digitised sound at I, looping if N is 0. The sound starts with a 6 byte header, a 16 bit
+// sample rate, a 24 bit length and a zero byte, followed by 8 bit unsigned samples.
+func (e *Emulator) playSample(N uint8) {
+	addr := int(e.idx)
+	rate := int(e.readMem(addr))<<8 | int(e.readMem(addr+1))
+	length := int(e.readMem(addr+2))<<16 | int(e.readMem(addr+3))<<8 | int(e.readMem(addr+4))
+
+	samples := make([]uint8, length)
+	for i := range samples {
+		samples[i] = e.readMem(addr + 6 + i)
+	}
+	e.audio.PlaySample(rate, samples, N == 0)
+}
+
+// stopSample: 0700: Stop the digitised sound
+func (e *Emulator) stopSample() {
+	e.audio.StopSample()
+}
+
+// setBlendMode: 080N: Set the sprite blend mode, 0 normal, 1 25%, 2 50%, 3 75%, 4 additive and 5 multiply
+func (e *Emulator) setBlendMode(N uint8) {
+	if N > BLEND_MULTIPLY {
+		e.log.Error("setBlendMode passed invalid value for N (must be 0-5)", "N", N)
+		return
+	}
+	e.mega.BlendMode = N
+}
+
+// setCollisionColor: 09NN: Set the palette index that counts as a collision when drawn over
+func (e *Emulator) setCollisionColor(NN uint8) {
+	e.mega.CollisionColor = NN
+}
+
+// presentMegachip: 00E0 in megachip mode: shows everything drawn since the last 00E0, then clears the back buffer
+func (e *Emulator) presentMegachip() {
+	copy(e.mega.Front, e.mega.Back)
+	clear(e.mega.Back)
+	clear(e.mega.Indexes)
+	e.drawFlag = true
+}
+
+// drawMegachipSprite: DXYN in megachip mode: Draws a sprite of palette indexes at (VX, VY), with the size set by 03NN
+// and 04NN. Index 0 is transparent, and VF is set to 1 if any pixel drawn over has the collision color.
+func (e *Emulator) drawMegachipSprite(X, Y, N uint8) {
+	// fonts and sprites below the program area are still 1 bit, draw them with the last palette entry
+	if e.idx < 0x200 {
+		e.drawMegachipFont(X, Y, N)
+		return
+	}
+
+	width := int(e.mega.SpriteWidth)
+	if width == 0 {
+		width = 256
+	}
+	height := int(e.mega.SpriteHeight)
+	if height == 0 {
+		height = 256
+	}
+
+	e.registers[0xF] = 0x00
+	VX := int(e.registers[X])
+	VY := int(e.registers[Y])
+	for row := range height {
+		for col := range width {
+			color := e.readMem(int(e.idx) + row*width + col)
+			if color != 0 {
+				e.drawMegachipPixel(VX+col, VY+row, color)
+			}
+		}
+	}
+}
+
+// drawMegachipFont draws a 1 bit sprite of N rows, or 16x16 if N is 0, onto the megachip display
+func (e *Emulator) drawMegachipFont(X, Y, N uint8) {
+	width, height := 8, int(N)
+	if N == 0 {
+		width, height = 16, 16
+	}
+
+	e.registers[0xF] = 0x00
+	VX := int(e.registers[X])
+	VY := int(e.registers[Y])
+	offset := int(e.idx)
+	for row := range height {
+		data := uint16(e.readMem(offset))
+		offset++
+		if width == 16 {
+			data = data<<8 | uint16(e.readMem(offset))
+			offset++
+		}
+		for bit := range width {
+			if data>>(width-1-bit)&0x01 == 0x01 {
+				e.drawMegachipPixel(VX+bit, VY+row, 0xFF)
+			}
+		}
+	}
+}
+
+func (e *Emulator) drawMegachipPixel(x, y int, color uint8) {
+	if x >= int(MEGACHIP_XRES) || y >= int(MEGACHIP_YRES) {
+		return
+	}
+
+	p := y*int(MEGACHIP_XRES) + x
+	if e.mega.Indexes[p] != 0 && e.mega.Indexes[p] == e.mega.CollisionColor {
+		e.registers[0xF] = 0x01
+	}
+	e.mega.Indexes[p] = color
+	e.mega.Back[p] = blend(e.mega.Back[p], e.mega.Palette[color], e.mega.Alpha[color], e.mega.BlendMode)
+}
+
+// scrollMegachip moves the megachip back buffer by dx, dy pixels
+func (e *Emulator) scrollMegachip(dx, dy int) {
+	xres, yres := int(MEGACHIP_XRES), int(MEGACHIP_YRES)
+	back := make([]types.Color, len(e.mega.Back))
+	indexes := make([]uint8, len(e.mega.Indexes))
+	for y := range yres {
+		for x := range xres {
+			sx, sy := x-dx, y-dy
+			if sx < 0 || sx >= xres || sy < 0 || sy >= yres {
+				continue
+			}
+			back[y*xres+x] = e.mega.Back[sy*xres+sx]
+			indexes[y*xres+x] = e.mega.Indexes[sy*xres+sx]
+		}
+	}
+	e.mega.Back = back
+	e.mega.Indexes = indexes
+}
+
+// megachipGfx returns the front buffer with the screen alpha applied
+func (e *Emulator) megachipGfx() []types.Color {
+	res := make([]types.Color, len(e.mega.Front))
+	for i, c := range e.mega.Front {
+		res[i] = blend(types.Color{}, c, e.mega.ScreenAlpha, BLEND_NORMAL)
+	}
+	return res
+}
+
+// blend combines a sprite color with the color already on screen
+func blend(dst, src types.Color, alpha uint8, mode uint8) types.Color {
+	channel := func(d, s uint8) uint8 {
+		switch mode {
+		case BLEND_ADD:
+			return uint8(min(int(d)+int(s)*int(alpha)/0xFF, 0xFF))
+		case BLEND_MULTIPLY:
+			return uint8(int(d) * int(s) / 0xFF)
+		}
+
+		a := int(alpha)
+		switch mode {
+		case BLEND_25:
+			a = a / 4
+		case BLEND_50:
+			a = a / 2
+		case BLEND_75:
+			a = a * 3 / 4
+		}
+		return uint8((int(s)*a + int(d)*(0xFF-a)) / 0xFF)
+	}
+
+	return types.Color{
+		R: channel(dst.R, src.R),
+		G: channel(dst.G, src.G),
+		B: channel(dst.B, src.B),
+	}
+}
@@ -0,0 +1,48 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/types"
+)
+
+func TestMegachip(t *testing.T) {
+	e := newTestEmulator(t, types.MODE_MEGACHIP)
+	rom := []byte{
+		0x00, 0x11, // enable megachip mode
+		0x01, 0x00, 0x03, 0x00, // I := 0x000300
+		0x03, 0x02, // sprite width 2
+		0x04, 0x01, // sprite height 1
+		0x60, 0x00, // V0 := 0
+		0x09, 0x01, // collision color 1
+		0xD0, 0x01, // draw
+		0xD0, 0x01, // draw again, colliding
+		0x00, 0xE0, // present
+		0x30, 0x00, // skip the next instruction if V0 == 0
+		0x01, 0x00, 0x00, 0x00, // I := 0x000000, 4 bytes long
+	}
+	rom = append(rom, make([]byte, 0x100-len(rom))...)
+	rom = append(rom, 0x01, 0x02)
+	if err := e.LoadROM(rom); err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		if err := e.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	xres, yres := e.Resolution()
+	assert.Equal(t, xres, MEGACHIP_XRES)
+	assert.Equal(t, yres, MEGACHIP_YRES)
+	assert.Equal(t, e.Registers()[0xF], uint8(0x01))
+	assert.Equal(t, e.Index(), uint32(0x300))
+	assert.Equal(t, e.PC(), uint16(0x21A))
+
+	gfx := e.Framebuffer()
+	assert.Equal(t, len(gfx), int(MEGACHIP_XRES*MEGACHIP_YRES))
+	assert.Equal(t, gfx[0], types.Color{R: 0xFF, G: 0xFF, B: 0xFF})
+	assert.Equal(t, gfx[2], types.Color{})
+}
@@ -0,0 +1,37 @@
+package emulator
+
+import (
+	"fmt"
+
+	"github.com/swensone/gorito/types"
+)
+
+// readMem returns the byte at addr, wrapping around the end of the platform's memory
+func (e *Emulator) readMem(addr int) uint8 {
+	if e.debugger != nil {
+		e.debugger.MemoryAccess(uint32(addr%len(e.memory)), false)
+	}
+	return e.fetchMem(addr)
+}
+
+// fetchMem reads like readMem without notifying the debugger
+func (e *Emulator) fetchMem(addr int) uint8 {
+	e.checkBounds(addr)
+	return e.memory[addr%len(e.memory)]
+}
+
+// writeMem sets the byte at addr, wrapping around the end of the platform's memory
+func (e *Emulator) writeMem(addr int, val uint8) {
+	e.checkBounds(addr)
+	if e.debugger != nil {
+		e.debugger.MemoryAccess(uint32(addr%len(e.memory)), true)
+	}
+	e.memory[addr%len(e.memory)] = val
+}
+
+// checkBounds raises a fault for addresses past the end of memory
+func (e *Emulator) checkBounds(addr int) {
+	if addr >= len(e.memory) {
+		e.raise(types.FAULT_MEMORY_BOUNDS, fmt.Sprintf("address %X is past the end of %d bytes of memory", addr, len(e.memory)))
+	}
+}
@@ -0,0 +1,145 @@
+package emulator
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/swensone/gorito/types"
+)
+
+// messages are drawn in a 3x5 pixel font on a 4x6 grid, giving 32 columns and 10 rows on the 128x64 display
+const (
+	MESSAGE_COLS = int(XRES) / 4
+	MESSAGE_ROWS = int(YRES) / 6
+)
+
+// messageFont has a glyph for the printable ascii characters, lower case letters are drawn as upper case. Each row is
+// 3 bits wide with the leftmost pixel in the high bit.
+var messageFont = map[rune][5]uint8{
+	'0':  {0b111, 0b101, 0b101, 0b101, 0b111},
+	'1':  {0b010, 0b110, 0b010, 0b010, 0b111},
+	'2':  {0b111, 0b001, 0b111, 0b100, 0b111},
+	'3':  {0b111, 0b001, 0b011, 0b001, 0b111},
+	'4':  {0b101, 0b101, 0b111, 0b001, 0b001},
+	'5':  {0b111, 0b100, 0b111, 0b001, 0b111},
+	'6':  {0b111, 0b100, 0b111, 0b101, 0b111},
+	'7':  {0b111, 0b001, 0b010, 0b010, 0b010},
+	'8':  {0b111, 0b101, 0b111, 0b101, 0b111},
+	'9':  {0b111, 0b101, 0b111, 0b001, 0b111},
+	'A':  {0b010, 0b101, 0b111, 0b101, 0b101},
+	'B':  {0b110, 0b101, 0b110, 0b101, 0b110},
+	'C':  {0b011, 0b100, 0b100, 0b100, 0b011},
+	'D':  {0b110, 0b101, 0b101, 0b101, 0b110},
+	'E':  {0b111, 0b100, 0b110, 0b100, 0b111},
+	'F':  {0b111, 0b100, 0b110, 0b100, 0b100},
+	'G':  {0b011, 0b100, 0b101, 0b101, 0b011},
+	'H':  {0b101, 0b101, 0b111, 0b101, 0b101},
+	'I':  {0b111, 0b010, 0b010, 0b010, 0b111},
+	'J':  {0b001, 0b001, 0b001, 0b101, 0b010},
+	'K':  {0b101, 0b101, 0b110, 0b101, 0b101},
+	'L':  {0b100, 0b100, 0b100, 0b100, 0b111},
+	'M':  {0b101, 0b111, 0b111, 0b101, 0b101},
+	'N':  {0b110, 0b101, 0b101, 0b101, 0b101},
+	'O':  {0b010, 0b101, 0b101, 0b101, 0b010},
+	'P':  {0b110, 0b101, 0b110, 0b100, 0b100},
+	'Q':  {0b010, 0b101, 0b101, 0b110, 0b011},
+	'R':  {0b110, 0b101, 0b110, 0b101, 0b101},
+	'S':  {0b011, 0b100, 0b010, 0b001, 0b110},
+	'T':  {0b111, 0b010, 0b010, 0b010, 0b010},
+	'U':  {0b101, 0b101, 0b101, 0b101, 0b111},
+	'V':  {0b101, 0b101, 0b101, 0b101, 0b010},
+	'W':  {0b101, 0b101, 0b111, 0b111, 0b101},
+	'X':  {0b101, 0b101, 0b010, 0b101, 0b101},
+	'Y':  {0b101, 0b101, 0b010, 0b010, 0b010},
+	'Z':  {0b111, 0b001, 0b010, 0b100, 0b111},
+	' ':  {0b000, 0b000, 0b000, 0b000, 0b000},
+	'.':  {0b000, 0b000, 0b000, 0b000, 0b010},
+	',':  {0b000, 0b000, 0b000, 0b010, 0b100},
+	':':  {0b000, 0b010, 0b000, 0b010, 0b000},
+	';':  {0b000, 0b010, 0b000, 0b010, 0b100},
+	'!':  {0b010, 0b010, 0b010, 0b000, 0b010},
+	'?':  {0b111, 0b001, 0b010, 0b000, 0b010},
+	'\'': {0b010, 0b010, 0b000, 0b000, 0b000},
+	'"':  {0b101, 0b101, 0b000, 0b000, 0b000},
+	'`':  {0b100, 0b010, 0b000, 0b000, 0b000},
+	'-':  {0b000, 0b000, 0b111, 0b000, 0b000},
+	'_':  {0b000, 0b000, 0b000, 0b000, 0b111},
+	'+':  {0b000, 0b010, 0b111, 0b010, 0b000},
+	'*':  {0b000, 0b101, 0b010, 0b101, 0b000},
+	'=':  {0b000, 0b111, 0b000, 0b111, 0b000},
+	'/':  {0b001, 0b001, 0b010, 0b100, 0b100},
+	'\\': {0b100, 0b100, 0b010, 0b001, 0b001},
+	'|':  {0b010, 0b010, 0b010, 0b010, 0b010},
+	'(':  {0b001, 0b010, 0b010, 0b010, 0b001},
+	')':  {0b100, 0b010, 0b010, 0b010, 0b100},
+	'[':  {0b011, 0b010, 0b010, 0b010, 0b011},
+	']':  {0b110, 0b010, 0b010, 0b010, 0b110},
+	'{':  {0b011, 0b010, 0b110, 0b010, 0b011},
+	'}':  {0b110, 0b010, 0b011, 0b010, 0b110},
+	'<':  {0b001, 0b010, 0b100, 0b010, 0b001},
+	'>':  {0b100, 0b010, 0b001, 0b010, 0b100},
+	'#':  {0b101, 0b111, 0b101, 0b111, 0b101},
+	'%':  {0b101, 0b001, 0b010, 0b100, 0b101},
+	'&':  {0b010, 0b101, 0b010, 0b101, 0b011},
+	'@':  {0b010, 0b101, 0b111, 0b100, 0b011},
+	'$':  {0b011, 0b110, 0b010, 0b011, 0b110},
+	'^':  {0b010, 0b101, 0b000, 0b000, 0b000},
+	'~':  {0b000, 0b011, 0b110, 0b000, 0b000},
+}
+
+// wrapMessage splits text into lines of at most cols characters, breaking at spaces where it can
+func wrapMessage(text string, cols int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len(word) > cols {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, word[:cols])
+				word = word[cols:]
+			}
+			if line == "" {
+				line = word
+			} else if len(line)+1+len(word) <= cols {
+				line += " " + word
+			} else {
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// showMessage replaces the display contents with text, using the first foreground color on the background color.
+// Text that doesn't fit on screen is cut off.
+func (e *Emulator) showMessage(text string) error {
+	bg, fg := e.cfg.ColorMap[0], e.cfg.ColorMap[1]
+	gfx := make([]types.Color, XRES*YRES)
+	for i := range gfx {
+		gfx[i] = bg
+	}
+
+	lines := wrapMessage(text, MESSAGE_COLS)
+	for row, line := range lines[:min(len(lines), MESSAGE_ROWS)] {
+		for col, c := range line {
+			glyph, ok := messageFont[unicode.ToUpper(c)]
+			if !ok {
+				glyph = messageFont['?']
+			}
+			for y, bits := range glyph {
+				for x := range 3 {
+					if bits&(0b100>>x) != 0 {
+						gfx[(row*6+1+y)*int(XRES)+col*4+1+x] = fg
+					}
+				}
+			}
+		}
+	}
+
+	return e.display.Draw(gfx, XRES, YRES)
+}
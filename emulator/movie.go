@@ -0,0 +1,284 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"hash/fnv"
+	"io"
+	"os"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/types"
+)
+
+// movie files start with a magic number and a version, followed by a gob encoded MovieHeader and a movieFrame for
+// every frame
+const (
+	MOVIE_MAGIC   = "GRTM"
+	MOVIE_VERSION = uint16(2)
+)
+
+// MovieHeader holds everything that has to match for a movie to play back the same way it was recorded
+type MovieHeader struct {
+	ROMHash [sha256.Size]byte
+	Mode    types.Mode
+	Quirks  types.Quirks
+	Speed   uint32 // sets the instructions run per frame
+	Seed    int64  // seeds the random numbers for CXNN
+	RNG     string // generator for the random numbers, pcg or vip
+}
+
+// movieFrame is the keypad state for a frame, and a hash of the screen at the end of it
+type movieFrame struct {
+	Keys  uint16
+	Keys2 uint16
+	Hash  uint64
+}
+
+// movie records or plays back the keypad a frame at a time. Keys are latched at the start of each frame, so a movie
+// needs frames to run a fixed number of instructions to play back the same.
+type movie struct {
+	file   *os.File
+	w      *bufio.Writer
+	enc    *gob.Encoder
+	frames []movieFrame // when playing
+
+	frame   int
+	current movieFrame
+	latch   bool // take the keys for the next frame on the next poll
+	desync  int  // first frame that didn't match, or -1
+}
+
+// ReadMovieHeader returns the header of a movie file, so the emulator can be set up to play it
+func ReadMovieHeader(fpath string) (MovieHeader, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return MovieHeader{}, err
+	}
+	defer f.Close()
+
+	header, _, err := readMovieHeader(bufio.NewReader(f))
+	return header, err
+}
+
+func readMovieHeader(r io.Reader) (MovieHeader, *gob.Decoder, error) {
+	magic := make([]byte, len(MOVIE_MAGIC))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return MovieHeader{}, nil, errors.Wrap(err, "unable to read movie header")
+	}
+	if !bytes.Equal(magic, []byte(MOVIE_MAGIC)) {
+		return MovieHeader{}, nil, errors.New("not a gorito movie")
+	}
+
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return MovieHeader{}, nil, errors.Wrap(err, "unable to read movie version")
+	}
+	if version != MOVIE_VERSION {
+		return MovieHeader{}, nil, errors.Errorf("unsupported movie version %d, expected %d", version, MOVIE_VERSION)
+	}
+
+	dec := gob.NewDecoder(r)
+	header := MovieHeader{}
+	if err := dec.Decode(&header); err != nil {
+		return MovieHeader{}, nil, errors.Wrap(err, "unable to decode movie header")
+	}
+	return header, dec, nil
+}
+
+// movieHeader describes the loaded program and the machine it runs on
+func (e *Emulator) movieHeader() MovieHeader {
+	return MovieHeader{
+		ROMHash: sha256.Sum256(e.program),
+		Mode:    e.cfg.Mode,
+		Quirks:  e.cfg.Quirks,
+		Speed:   e.cfg.Speed,
+		Seed:    e.cfg.Seed,
+		RNG:     e.rng.Name(),
+	}
+}
+
+// startMovie starts recording or playing the movie files in the config, once the program is loaded
+func (e *Emulator) startMovie() error {
+	switch {
+	case e.cfg.MovieRecord != "":
+		return e.recordMovie(e.cfg.MovieRecord)
+	case e.cfg.MoviePlay != "":
+		return e.playMovie(e.cfg.MoviePlay)
+	}
+	return nil
+}
+
+func (e *Emulator) recordMovie(fpath string) error {
+	f, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	m := &movie{file: f, w: bufio.NewWriter(f), latch: true, desync: -1}
+	m.enc = gob.NewEncoder(m.w)
+
+	if _, err := io.WriteString(m.w, MOVIE_MAGIC); err != nil {
+		f.Close()
+		return err
+	}
+	if err := binary.Write(m.w, binary.BigEndian, MOVIE_VERSION); err != nil {
+		f.Close()
+		return err
+	}
+	if err := m.enc.Encode(e.movieHeader()); err != nil {
+		f.Close()
+		return errors.Wrap(err, "unable to write movie header")
+	}
+
+	e.movie = m
+	e.log.Info("recording movie", "file", fpath)
+	return nil
+}
+
+func (e *Emulator) playMovie(fpath string) error {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header, dec, err := readMovieHeader(bufio.NewReader(f))
+	if err != nil {
+		return errors.Wrapf(err, "failed to read movie %s", fpath)
+	}
+	if want := e.movieHeader(); header != want {
+		if header.ROMHash != want.ROMHash {
+			return errors.Newf("movie %s was recorded with a different rom", fpath)
+		}
+		return errors.Newf("movie %s was recorded with mode %s, speed %d, seed %d, the %s generator and quirks %+v",
+			fpath, header.Mode.String(), header.Speed, header.Seed, header.RNG, header.Quirks)
+	}
+
+	m := &movie{latch: true, desync: -1}
+	for {
+		var frame movieFrame
+		if err := dec.Decode(&frame); err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to read movie %s", fpath)
+		}
+		m.frames = append(m.frames, frame)
+	}
+
+	e.movie = m
+	e.log.Info("playing movie", "file", fpath, "frames", len(m.frames))
+	return nil
+}
+
+// stopMovie finishes recording
+func (e *Emulator) stopMovie() error {
+	m := e.movie
+	if m == nil || m.file == nil {
+		return nil
+	}
+
+	err := m.w.Flush()
+	if cerr := m.file.Close(); err == nil {
+		err = cerr
+	}
+	e.movie = nil
+	e.log.Info("recorded movie", "frames", m.frame)
+	return errors.Wrap(err, "failed to write movie")
+}
+
+// movieKeys replaces the polled keys with the keys for the current frame. When recording they're latched from the
+// input at the start of the frame, when playing they come from the movie until it runs out.
+func (e *Emulator) movieKeys(state *InputState) {
+	m := e.movie
+	if m.file == nil && m.frame >= len(m.frames) {
+		return
+	}
+
+	if m.latch {
+		m.latch = false
+		if m.file != nil {
+			m.current = movieFrame{Keys: packKeys(state.Keys), Keys2: packKeys(state.Keys2)}
+		} else {
+			m.current = m.frames[m.frame]
+		}
+	}
+	state.Keys = unpackKeys(m.current.Keys)
+	state.Keys2 = unpackKeys(m.current.Keys2)
+}
+
+// movieFrame records the frame just finished, or checks it against the movie, reporting the first frame that
+// doesn't match
+func (e *Emulator) movieFrame() error {
+	m := e.movie
+	m.latch = true
+	hash := e.frameHash()
+
+	if m.file != nil {
+		m.current.Hash = hash
+		m.frame++
+		return errors.Wrap(m.enc.Encode(m.current), "failed to write movie frame")
+	}
+
+	if m.frame >= len(m.frames) {
+		return nil
+	}
+	if m.desync < 0 && hash != m.frames[m.frame].Hash {
+		m.desync = m.frame
+		e.log.Error("movie desynced, the screen doesn't match the recording", "frame", m.frame)
+	}
+	m.frame++
+	if m.frame == len(m.frames) {
+		e.log.Info("movie finished", "frames", m.frame, "desynced", m.desync >= 0)
+	}
+	return nil
+}
+
+// MovieDesync returns the first frame of the movie being played that didn't match the recording, or -1
+func (e *Emulator) MovieDesync() int {
+	if e.movie == nil {
+		return -1
+	}
+	return e.movie.desync
+}
+
+// frameHash hashes what's on screen without the configured colors, so movies play back with any colors
+func (e *Emulator) frameHash() uint64 {
+	h := fnv.New64a()
+	if e.mega.Enabled {
+		for _, c := range e.mega.Front {
+			h.Write([]byte{c.R, c.G, c.B})
+		}
+		return h.Sum64()
+	}
+
+	for i := range e.platform.Planes {
+		h.Write(e.gfx[i])
+	}
+	if e.platform.Chip8X {
+		h.Write(e.c8x.Zones[:])
+		h.Write([]byte{e.c8x.Background})
+	}
+	return h.Sum64()
+}
+
+func packKeys(keys [16]bool) uint16 {
+	var packed uint16
+	for k, down := range keys {
+		if down {
+			packed |= 1 << k
+		}
+	}
+	return packed
+}
+
+func unpackKeys(packed uint16) [16]bool {
+	var keys [16]bool
+	for k := range keys {
+		keys[k] = packed&(1<<k) != 0
+	}
+	return keys
+}
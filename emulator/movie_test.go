@@ -0,0 +1,254 @@
+package emulator
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/types"
+)
+
+// movieROM draws a pixel further along the top row for every instruction key 5 is held, and exits after 20 frames
+var movieROM = []byte{
+	0xA2, 0x20, // 200: i := 0x220
+	0x6B, 0x05, // 202: vb := 5
+	0x6C, 0x14, // 204: vc := 20
+	0xFC, 0x15, // 206: delay := vc
+	0xEB, 0x9E, // 208: if vb -key then
+	0x12, 0x10, // 20A: jump 210
+	0xD0, 0x11, // 20C: sprite v0 v1 1
+	0x70, 0x01, // 20E: v0 += 1
+	0xFD, 0x07, // 210: vd := delay
+	0x3D, 0x00, // 212: if vd != 0 then
+	0x12, 0x08, // 214: jump 208
+	0x00, 0xFD, // 216: exit
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x80, // 220: sprite
+}
+
+// pollInput holds key 5 down for a range of polls, which Run makes once per frame
+type pollInput struct {
+	polls      int
+	start, end int
+}
+
+func (p *pollInput) Poll() InputState {
+	var state InputState
+	state.Keys[5] = p.polls >= p.start && p.polls < p.end
+	p.polls++
+	return state
+}
+
+// hotkeyInput holds key 5 down like pollInput, then sends an event on every poll in a later range
+type hotkeyInput struct {
+	pollInput
+	event                Event
+	eventStart, eventEnd int
+}
+
+func (h *hotkeyInput) Poll() InputState {
+	poll := h.polls
+	state := h.pollInput.Poll()
+	if poll >= h.eventStart && poll < h.eventEnd {
+		state.Events = append(state.Events, h.event)
+	}
+	return state
+}
+
+func TestMovie(t *testing.T) {
+	tests := []struct {
+		name   string
+		rom    []byte
+		speed  uint32
+		rng    string
+		input  Input
+		err    string
+		desync int
+	}{
+		{
+			"plays back",
+			movieROM,
+			600,
+			"pcg",
+			nil,
+			"",
+			-1,
+		},
+		{
+			"plays back over other input",
+			movieROM,
+			600,
+			"pcg",
+			&pollInput{start: 0, end: 1000},
+			"",
+			-1,
+		},
+		{
+			"different speed",
+			movieROM,
+			1200,
+			"pcg",
+			nil,
+			"recorded with mode superchip, speed 600",
+			-1,
+		},
+		{
+			"different random number generator",
+			movieROM,
+			600,
+			"vip",
+			nil,
+			"the pcg generator",
+			-1,
+		},
+		{
+			"different rom",
+			append([]byte{0x00, 0xE0}, movieROM...),
+			600,
+			"pcg",
+			nil,
+			"recorded with a different rom",
+			-1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			rom := filepath.Join(dir, "movie.ch8")
+			if err := os.WriteFile(rom, movieROM, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			movie := filepath.Join(dir, "movie.gmv")
+
+			// record holding key 5 for a while part way through
+			recorder, err := New(EmulatorConfig{
+				Savefile:    filepath.Join(dir, "saves.json"),
+				Mode:        types.MODE_SUPERCHIP,
+				Quirks:      types.QuirksForMode(types.MODE_SUPERCHIP),
+				Speed:       600,
+				MovieRecord: movie,
+			}, nil, nil, &pollInput{start: 5, end: 10}, slog.Default())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := recorder.Run(rom); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, recorder.Registers()[0] > 0, true, "key 5 was pressed while recording")
+
+			if err := os.WriteFile(rom, tt.rom, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			header, err := ReadMovieHeader(movie)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rng := NewPCG()
+			if tt.rng == "vip" {
+				if rng, err = NewVIP(make([]byte, 2*VIP_PAGE)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			player, err := New(EmulatorConfig{
+				Savefile:  filepath.Join(dir, "saves.json"),
+				Mode:      header.Mode,
+				Quirks:    header.Quirks,
+				Speed:     tt.speed,
+				RNG:       rng,
+				MoviePlay: movie,
+			}, nil, nil, tt.input, slog.Default())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = player.Run(rom)
+			if tt.err != "" {
+				assert.Matches(t, err.Error(), tt.err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, player.MovieDesync(), tt.desync)
+			assert.Equal(t, player.Registers(), recorder.Registers())
+			assert.Equal(t, player.frameHash(), recorder.frameHash())
+		})
+	}
+}
+
+func TestMovieDesync(t *testing.T) {
+	e := newTestEmulator(t, types.MODE_CHIP8)
+	e.movie = &movie{frames: []movieFrame{{}, {}, {}}, latch: true, desync: -1}
+	e.movie.frames[0].Hash = e.frameHash()
+	e.movie.frames[1].Hash = e.frameHash()
+	e.movie.frames[2].Hash = e.frameHash() + 1
+
+	for range 3 {
+		if err := e.endFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, e.MovieDesync(), 2)
+}
+
+func TestMovieRefusesTimeTravel(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+	}{
+		{"rewind", Event{Type: EVENT_REWIND}},
+		{"load state", Event{Type: EVENT_LOAD_STATE, Arg: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			rom := filepath.Join(dir, "movie.ch8")
+			if err := os.WriteFile(rom, movieROM, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			movie := filepath.Join(dir, "movie.gmv")
+			cfg := EmulatorConfig{
+				Savefile:     filepath.Join(dir, "saves.json"),
+				Mode:         types.MODE_SUPERCHIP,
+				Quirks:       types.QuirksForMode(types.MODE_SUPERCHIP),
+				Speed:        600,
+				RewindFrames: 60,
+			}
+
+			// going back after key 5 is released would undo the presses, a state saved at the start takes the machine
+			// back to before them
+			input := &hotkeyInput{pollInput{start: 5, end: 10}, tt.event, 11, 16}
+			recorder, err := New(cfg, nil, nil, input, slog.Default())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := recorder.LoadProgram(rom); err != nil {
+				t.Fatal(err)
+			}
+			if err := recorder.SaveSlot(1); err != nil {
+				t.Fatal(err)
+			}
+			recorder.cfg.MovieRecord = movie
+			if err := recorder.Run(rom); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg.MoviePlay = movie
+			player, err := New(cfg, nil, nil, nil, slog.Default())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := player.Run(rom); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, player.MovieDesync(), -1)
+			assert.Equal(t, player.Frame(), recorder.Frame())
+			assert.Equal(t, player.Registers(), recorder.Registers())
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package emulator
+
+import "github.com/swensone/gorito/types"
+
+// null devices used when the emulator is created without a display, audio or input
+
+type nullDisplay struct{}
+
+func (nullDisplay) Draw(gfx []types.Color, xres, yres int32) error { return nil }
+
+type nullAudio struct{}
+
+func (nullAudio) Play()                                           {}
+func (nullAudio) Stop()                                           {}
+func (nullAudio) LoadPattern(pattern [16]uint8)                   {}
+func (nullAudio) SetPitch(pitch uint8)                            {}
+func (nullAudio) PlaySample(rate int, samples []uint8, loop bool) {}
+func (nullAudio) StopSample()                                     {}
+
+type nullInput struct{}
+
+func (nullInput) Poll() InputState { return InputState{} }
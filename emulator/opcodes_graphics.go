@@ -1,10 +1,14 @@
 package emulator
 
-import "github.com/swensone/gorito/types"
-
-// clearDisplay: 00E0: clears display. on xo-chip, clears the selected display plane.
+// clearDisplay: 00E0: clears display. on xo-chip, clears the selected display plane. in megachip mode it presents the
+// frame drawn since the last clear instead.
 func (e *Emulator) clearDisplay() {
-	for i := range PLANES {
+	if e.mega.Enabled {
+		e.presentMegachip()
+		return
+	}
+
+	for i := range e.platform.Planes {
 		if e.plane>>i&0x01 == 0x01 {
 			for j := range XRES * YRES {
 				e.gfx[i][j] = 0
@@ -30,10 +34,15 @@ func (e *Emulator) enableHiRes() {
 // execution of this instruction. As described above, VF is set to 1 if any screen pixels are flipped from set to
 // unset when the sprite is drawn, and to 0 if that does not happen.
 func (e *Emulator) drawSprite(X, Y, N uint8) {
+	if e.mega.Enabled {
+		e.drawMegachipSprite(X, Y, N)
+		return
+	}
+
 	xres := int(e.xres)
 	yres := int(e.yres)
-	// handle display wait quirk
-	if e.cfg.Mode == types.MODE_CHIP8 {
+	// handle display wait quirk, only low resolution drawing waits for the vertical blank
+	if e.cfg.Quirks.DisplayWait && !e.hires {
 		if e.counter%4 != 0 {
 			e.pc -= 2
 			return
@@ -42,9 +51,13 @@ func (e *Emulator) drawSprite(X, Y, N uint8) {
 
 	spriteWidth := 8
 	spriteHeight := int(N)
-	if e.hires && N == 0 {
-		spriteWidth = 16
-		spriteHeight = 16
+	if N == 0 {
+		if e.hires || e.cfg.Quirks.Lores16x16 {
+			spriteWidth = 16
+			spriteHeight = 16
+		} else if e.cfg.Quirks.Lores8x16 {
+			spriteHeight = 16
+		}
 	}
 
 	scaleFactor := 2
@@ -54,7 +67,7 @@ func (e *Emulator) drawSprite(X, Y, N uint8) {
 
 	VX := int(e.registers[X]) * scaleFactor
 	VY := int(e.registers[Y]) * scaleFactor
-	if e.cfg.Mode != types.MODE_XOCHIP {
+	if !e.cfg.Quirks.SpriteWrap {
 		if VX >= xres {
 			VX = VX % xres
 		}
@@ -63,37 +76,52 @@ func (e *Emulator) drawSprite(X, Y, N uint8) {
 		}
 	}
 
-	offset := e.idx
-	e.registers[0xF] = 0x00
+	offset := int(e.idx)
+	collisions := 0
+	clipped := 0
 	for i := range spriteHeight {
-		spriteData := uint16(e.memory[offset])
+		spriteData := uint16(e.readMem(offset))
 		offset++
 		if spriteWidth == 16 {
-			spriteData = spriteData<<8 | uint16(e.memory[offset])
+			spriteData = spriteData<<8 | uint16(e.readMem(offset))
 			offset++
 		}
 
+		posY := VY + i*scaleFactor
+		if e.cfg.Quirks.SpriteWrap {
+			posY = posY % yres
+		} else if posY >= yres {
+			clipped++
+			continue
+		}
+
+		collided := false
 		for bit := range spriteWidth {
 			posX := VX + bit*scaleFactor
-			posY := VY + i*scaleFactor
-
-			if e.cfg.Mode == types.MODE_XOCHIP {
-				posX = posX % int(xres)
-				posY = posY % int(yres)
-			} else {
-				if posX < 0 || posX >= int(xres) {
-					continue
-				}
-				if posY < 0 || posY >= int(yres) {
-					continue
-				}
+			if e.cfg.Quirks.SpriteWrap {
+				posX = posX % xres
+			} else if posX >= xres {
+				continue
 			}
 
 			set := uint8(spriteData >> (spriteWidth - 1 - bit) & 0x01)
 			if e.drawAt(posX, posY, scaleFactor, set) {
-				e.registers[0xF] = 0x01
+				collided = true
 			}
 		}
+		if collided {
+			collisions++
+		}
+	}
+
+	// handle collision quirk, superchip 1.1 counts the rows that collided or were clipped off the bottom of the screen
+	// in high resolution mode
+	if e.cfg.Quirks.CollisionRows && e.hires {
+		e.registers[0xF] = uint8(collisions + clipped)
+	} else if collisions > 0 {
+		e.registers[0xF] = 0x01
+	} else {
+		e.registers[0xF] = 0x00
 	}
 	e.drawFlag = true
 }
@@ -104,7 +132,7 @@ func (e *Emulator) drawAt(x, y, scaleFactor int, set uint8) bool {
 		for yoffset := range scaleFactor {
 			gfxIdx := (y+yoffset)*int(e.xres) + x + xoffset
 
-			for i := range PLANES {
+			for i := range e.platform.Planes {
 				if e.plane>>i&0x01 == 0x01 {
 					prevSet := e.gfx[i][gfxIdx]
 					e.gfx[i][gfxIdx] = prevSet ^ set
@@ -130,13 +158,13 @@ func (e *Emulator) selectPlane(X uint8) {
 // setItoChar: FX29: Sets I to the location of the sprite for the character in VX (only consider the lowest nibble).
 // Characters 0-F (in hexadecimal) are represented by a 4x5 font.
 func (e *Emulator) setItoChar(X uint8) {
-	e.idx = FONT_OFFSET + uint16(e.registers[X])*5
+	e.idx = FONT_OFFSET + uint32(e.registers[X])*5
 }
 
 // setItoHiresChar: FX30: Sets I to the location of the sprite for the character in VX (only consider the lowest nibble).
 // Characters 0-9 are represented by a 8x10 font.
 func (e *Emulator) setItoHiresChar(X uint8) {
-	e.idx = SUPERCHIP_FONT_OFFSET + uint16(e.registers[X])*10
+	e.idx = SUPERCHIP_FONT_OFFSET + uint32(e.registers[X])*10
 }
 
 // storeVXatIinBCD: FX33: Stores the binary-coded decimal representation of VX, with the hundreds digit in memory at location
@@ -144,7 +172,7 @@ func (e *Emulator) setItoHiresChar(X uint8) {
 func (e *Emulator) storeVXatIinBCD(X uint8) {
 	vx := e.registers[X]
 	for i := range 3 {
-		e.memory[e.idx+2-uint16(i)] = vx % 10
+		e.writeMem(int(e.idx)+2-i, vx%10)
 		vx = vx / 10
 	}
 }
@@ -93,6 +93,18 @@ func TestDrawSprite(t *testing.T) {
 			10,
 			0x01,
 		},
+		{
+			"large sprite overlap counting rows",
+			true,
+			types.MODE_SCHIP11,
+			0x100,
+			0x100,
+			1,
+			0,
+			0,
+			10,
+			0x0A,
+		},
 		{
 			"large sprite second plane no overlap",
 			true,
@@ -116,7 +128,9 @@ func TestDrawSprite(t *testing.T) {
 				gfx:       make(map[int][]uint8),
 				plane:     1,
 				display:   newTermDisplay(XRES, YRES),
+				audio:     nullAudio{},
 				registers: make([]uint8, 16),
+				rng:       NewPCG(),
 			}
 			e.gfx[0] = make([]uint8, XRES*YRES)
 			e.gfx[1] = make([]uint8, XRES*YRES)
@@ -126,27 +140,28 @@ func TestDrawSprite(t *testing.T) {
 			e.cfg.ColorMap[2] = types.Color{R: 2}
 			e.cfg.ColorMap[3] = types.Color{R: 3}
 			e.cfg.Mode = tt.mode
+			e.cfg.Quirks = types.QuirksForMode(tt.mode)
 			e.Reset()
 			if tt.hires {
 				e.enableHiRes()
 			}
 
 			// draw a 0 at 0,0
-			e.idx = tt.sprite1
+			e.idx = uint32(tt.sprite1)
 			e.registers[0] = 0
 			e.registers[1] = 0
 			fmt.Printf("draw sprite 0x%03x at 0,0\n", tt.sprite1)
 			e.drawSprite(0, 1, tt.height)
-			e.display.Draw(e.getGfx())
+			e.display.Draw(e.getGfx(), XRES, YRES)
 
 			// draw a 3 at specific coordinates and check for overlap
-			e.idx = tt.sprite2
+			e.idx = uint32(tt.sprite2)
 			e.registers[0] = tt.x
 			e.registers[1] = tt.y
 			fmt.Printf("draw sprite 0x%03x at %d,%d\n", tt.sprite2, tt.x, tt.y)
 			e.selectPlane(tt.plane)
 			e.drawSprite(0, 1, tt.height)
-			e.display.Draw(e.getGfx())
+			e.display.Draw(e.getGfx(), XRES, YRES)
 
 			assert.Equal(t, e.registers[0x0f], tt.vf)
 			assert.Equal(t, true, e.drawFlag)
@@ -163,7 +178,9 @@ func newTermDisplay(xres, yres int32) *termDisplay {
 	return &termDisplay{xres: xres, yres: yres}
 }
 
-func (t *termDisplay) Draw(gfx []types.Color) error {
+func (t *termDisplay) Draw(gfx []types.Color, xres, yres int32) error {
+	t.xres, t.yres = xres, yres
+
 	for range t.xres + 2 {
 		fmt.Print("*")
 	}
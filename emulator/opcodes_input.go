@@ -4,7 +4,12 @@ package emulator
 // key event, delay and sound timers should continue processing)
 func (e *Emulator) waitKeyPress(X uint8) {
 	for i := range e.keys {
-		if e.prevKeys[i] && !e.keys[i] {
+		// handle key wait quirk, the original interpreter waits for the key to be released
+		if e.cfg.Quirks.KeyWaitRelease && e.prevKeys[i] && !e.keys[i] {
+			e.registers[X] = uint8(i)
+			return
+		}
+		if !e.cfg.Quirks.KeyWaitRelease && !e.prevKeys[i] && e.keys[i] {
 			e.registers[X] = uint8(i)
 			return
 		}
@@ -1,12 +1,5 @@
 package emulator
 
-import (
-	"crypto/rand"
-	"math/big"
-
-	"github.com/swensone/gorito/types"
-)
-
 // setVXtoNN: 6XNN: Sets VX to NN
 func (e *Emulator) setVXtoNN(X, NN uint8) {
 	e.registers[X] = NN
@@ -27,7 +20,7 @@ func (e *Emulator) setVXtoVXorVY(X, Y uint8) {
 	e.registers[X] |= e.registers[Y]
 
 	// handle VF reset quirk
-	if e.cfg.Mode == types.MODE_CHIP8 {
+	if e.cfg.Quirks.VFReset {
 		e.registers[0xF] = 0
 	}
 }
@@ -37,7 +30,7 @@ func (e *Emulator) setVXtoVXandVY(X, Y uint8) {
 	e.registers[X] &= e.registers[Y]
 
 	// handle VF reset quirk
-	if e.cfg.Mode == types.MODE_CHIP8 {
+	if e.cfg.Quirks.VFReset {
 		e.registers[0xF] = 0
 	}
 }
@@ -47,7 +40,7 @@ func (e *Emulator) setVXtoVXxorVY(X, Y uint8) {
 	e.registers[X] ^= e.registers[Y]
 
 	// handle VF reset quirk
-	if e.cfg.Mode == types.MODE_CHIP8 {
+	if e.cfg.Quirks.VFReset {
 		e.registers[0xF] = 0
 	}
 }
@@ -80,7 +73,8 @@ func (e *Emulator) subVYFromVX(X, Y uint8) {
 // shiftVXRight: 8XY6: Shifts VX to the right by 1, then stores the least significant bit of VX prior to the shift into VF
 func (e *Emulator) shiftVXRight(X, Y uint8) {
 	VX := e.registers[X]
-	if e.cfg.Mode != types.MODE_SUPERCHIP {
+	// handle shift quirk
+	if e.cfg.Quirks.ShiftUsesVY {
 		e.registers[X] = e.registers[Y]
 	}
 	e.registers[X] = e.registers[X] >> 1
@@ -107,23 +101,20 @@ func (e *Emulator) subVXFromVY(X, Y uint8) {
 // was set, or to 0 if it was unset.
 func (e *Emulator) shiftVXLeft(X, Y uint8) {
 	VX := e.registers[X]
-	if e.cfg.Mode != types.MODE_SUPERCHIP {
+	// handle shift quirk
+	if e.cfg.Quirks.ShiftUsesVY {
 		e.registers[X] = e.registers[Y]
 	}
 	e.registers[X] = e.registers[X] << 1
 	e.registers[0xF] = (0x80 & VX) >> 7
 }
 
-// setVXtoNNandRand CXNN: Sets VX to the result of a bitwise and operation on a random number (Typically: 0 to 255) and NN
+// setVXtoNNandRand CXNN: Sets VX to the result of a bitwise and operation on a random number (0 to 255) and NN
 func (e *Emulator) setVXtoNNNandRand(X, NN uint8) {
-	r, err := rand.Int(rand.Reader, big.NewInt(255))
-	if err != nil {
-		panic(err)
-	}
-	e.registers[X] = uint8(r.Int64()) & NN
+	e.registers[X] = e.rng.Byte() & NN
 }
 
 // addVXtoI: FX1E: Adds VX to I. VF is not affected.
 func (e *Emulator) addVXtoI(X uint8) {
-	e.idx += uint16(e.registers[X])
+	e.idx += uint32(e.registers[X])
 }
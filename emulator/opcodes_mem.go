@@ -2,7 +2,6 @@ package emulator
 
 import (
 	"github.com/swensone/gorito/gmath"
-	"github.com/swensone/gorito/types"
 )
 
 // saveVXthroughVY: 5XY2: Save an inclusive range of registers VX to VY in memory starting at I
@@ -10,11 +9,11 @@ func (e *Emulator) saveVXthroughVY(X, Y uint8) {
 	registers := gmath.Abs(int(X) - int(Y))
 	if X > Y {
 		for i := 0; i <= registers; i++ {
-			e.memory[e.idx+uint16(i)] = e.registers[X-uint8(i)]
+			e.writeMem(int(e.idx)+i, e.registers[X-uint8(i)])
 		}
 	} else {
 		for i := 0; i <= registers; i++ {
-			e.memory[e.idx+uint16(i)] = e.registers[X+uint8(i)]
+			e.writeMem(int(e.idx)+i, e.registers[X+uint8(i)])
 		}
 	}
 }
@@ -24,60 +23,64 @@ func (e *Emulator) loadVXthroughVY(X, Y uint8) {
 	registers := gmath.Abs(int(X) - int(Y))
 	if X > Y {
 		for i := 0; i <= registers; i++ {
-			e.registers[X-uint8(i)] = e.memory[e.idx+uint16(i)]
+			e.registers[X-uint8(i)] = e.readMem(int(e.idx) + i)
 		}
 	} else {
 		for i := 0; i <= registers; i++ {
-			e.registers[X+uint8(i)] = e.memory[e.idx+uint16(i)]
+			e.registers[X+uint8(i)] = e.readMem(int(e.idx) + i)
 		}
 	}
 }
 
 // setItoNNN: ANNN: Sets I to the address NNN
 func (e *Emulator) setItoNNN(NNN uint16) {
-	e.idx = NNN
+	e.idx = uint32(NNN)
 }
 
 // loadHiMem: F000 NNNN: Load I with 16-bit address NNNN
 func (e *Emulator) loadHiMem(NNNN uint16) {
 	e.pc += 2
-	e.idx = NNNN
+	e.idx = uint32(NNNN)
 }
 
 // storeRegistersInMemory: FX55: Stores from V0 to VX (including VX) in memory, starting at address I. The offset from I is increased by 1
 // for each value written, but I itself is left unmodified.[d][24]
 func (e *Emulator) storeRegistersInMemory(X uint8) {
 	for i := range X + 1 {
-		e.memory[e.idx+uint16(i)] = e.registers[i]
-	}
-
-	// handle memory quirk
-	if e.cfg.Mode != types.MODE_SUPERCHIP {
-		e.idx += (uint16(X) + 1)
+		e.writeMem(int(e.idx)+int(i), e.registers[i])
 	}
+	e.memIncrement(X)
 }
 
 // storeMemInRegisters: FX65: Fills from V0 to VX (including VX) with values from memory, starting at address I. The offset from I
 // is increased by 1 for each value read, but I itself is left unmodified.
 func (e *Emulator) storeMemInRegisters(X uint8) {
 	for i := range X + 1 {
-		e.registers[i] = e.memory[e.idx+uint16(i)]
+		e.registers[i] = e.readMem(int(e.idx) + int(i))
 	}
+	e.memIncrement(X)
+}
 
-	// handle memory quirk
-	if e.cfg.Mode != types.MODE_SUPERCHIP {
-		e.idx += (uint16(X) + 1)
+// memIncrement handles the memory quirk, where FX55 and FX65 leave I pointing past the registers that were stored or
+// loaded. chip-48 and superchip 1.0 increment it by one less.
+func (e *Emulator) memIncrement(X uint8) {
+	if !e.cfg.Quirks.MemIncrementI {
+		return
+	}
+	if e.cfg.Quirks.MemIncrementByX {
+		e.idx += uint32(X)
+	} else {
+		e.idx += uint32(X) + 1
 	}
 }
 
 // storeRegistersToStorage: FX75: Store V0..VX in RPL user flags (X <= 7 if superchip, X <= 16 if xo-chip)
 func (e *Emulator) storeRegistersToStorage(X uint8) {
 	e.log.Debug("storeRegistersToStorag", "X", X)
-	if e.cfg.Mode == types.MODE_CHIP8 {
+	if e.platform.Flags == 0 {
 		return
-	} else if e.cfg.Mode == types.MODE_SUPERCHIP && X > 7 {
-		X = 7
 	}
+	X = min(X, uint8(e.platform.Flags-1))
 
 	e.storage.Persist(e.rom, e.registers[:int(X)+1])
 }
@@ -85,11 +88,10 @@ func (e *Emulator) storeRegistersToStorage(X uint8) {
 // loadRegistersFromStorage: FX85: Read V0..VX from RPL user flags (X <= 7 if superchip, X <= 16 if xo-chip)
 func (e *Emulator) loadRegistersFromStorage(X uint8) {
 	e.log.Debug("loadRegistersFromStorage", "X", X)
-	if e.cfg.Mode == types.MODE_CHIP8 {
+	if e.platform.Flags == 0 {
 		return
-	} else if e.cfg.Mode == types.MODE_SUPERCHIP && X > 7 {
-		X = 7
 	}
+	X = min(X, uint8(e.platform.Flags-1))
 
 	e.storage.Load(e.rom, int(X), e.registers)
 }
@@ -1,11 +1,24 @@
 package emulator
 
+// scrollScale returns the number of display rows or columns in a scrolled pixel. In low resolution mode a pixel is
+// two display pixels across, unless the platform scrolls by high resolution pixels.
+func (e *Emulator) scrollScale() int {
+	if e.hires || e.cfg.Quirks.HalfPixelScroll {
+		return 1
+	}
+	return 2
+}
+
 // scrollDown: 00CN: Scroll the display down by 0 to 15 pixels
 func (e *Emulator) scrollDown(N uint8) {
-	scrollLen := int(e.xres) * int(N)
-	scroll := make([]uint8, scrollLen)
+	if e.mega.Enabled {
+		e.scrollMegachip(0, int(N))
+		return
+	}
 
-	for i := range PLANES {
+	scrollLen := int(e.xres) * int(N) * e.scrollScale()
+	scroll := make([]uint8, scrollLen)
+	for i := range e.platform.Planes {
 		if e.plane>>i&0x01 == 0x01 {
 			e.gfx[i] = append(scroll, e.gfx[i][0:len(e.gfx[i])-scrollLen]...)
 		}
@@ -14,25 +27,36 @@ func (e *Emulator) scrollDown(N uint8) {
 
 // scrollUp: 00DN: Scroll the display up by 0 to 15 pixels
 func (e *Emulator) scrollUp(N uint8) {
-	scrollLen := int(e.xres) * int(N)
-	scroll := make([]uint8, scrollLen)
+	if e.mega.Enabled {
+		e.scrollMegachip(0, -int(N))
+		return
+	}
 
-	for i := range PLANES {
+	scrollLen := int(e.xres) * int(N) * e.scrollScale()
+	scroll := make([]uint8, scrollLen)
+	for i := range e.platform.Planes {
 		if e.plane>>i&0x01 == 0x01 {
-			e.gfx[i] = append(e.gfx[i][len(e.gfx[i])-scrollLen:], scroll...)
+			e.gfx[i] = append(e.gfx[i][scrollLen:], scroll...)
 		}
 	}
 }
 
 // scrollRight: 00FB: Scroll the display right by 4 pixels
 func (e *Emulator) scrollRight() {
-	for i := range PLANES {
+	if e.mega.Enabled {
+		e.scrollMegachip(4, 0)
+		return
+	}
+
+	xres := int(e.xres)
+	n := 4 * e.scrollScale()
+	for i := range e.platform.Planes {
 		if e.plane>>i&0x01 == 0x01 {
 			newGfx := []uint8{}
-			scroll := make([]uint8, 4)
-			for j := range e.yres {
+			scroll := make([]uint8, n)
+			for j := range int(e.yres) {
 				newGfx = append(newGfx, scroll...)
-				newGfx = append(newGfx, e.gfx[i][j*e.xres:(j+1)*e.xres-4]...)
+				newGfx = append(newGfx, e.gfx[i][j*xres:(j+1)*xres-n]...)
 			}
 			e.gfx[i] = newGfx
 		}
@@ -41,12 +65,19 @@ func (e *Emulator) scrollRight() {
 
 // scrollLeft: 00FC: Scroll the display left by 4 pixels.
 func (e *Emulator) scrollLeft() {
-	for i := range PLANES {
+	if e.mega.Enabled {
+		e.scrollMegachip(-4, 0)
+		return
+	}
+
+	xres := int(e.xres)
+	n := 4 * e.scrollScale()
+	for i := range e.platform.Planes {
 		if e.plane>>i&0x01 == 0x01 {
 			newGfx := []uint8{}
-			scroll := make([]uint8, 4)
-			for j := range e.yres {
-				newGfx = append(newGfx, e.gfx[i][j*e.xres+4:(j+1)*e.xres]...)
+			scroll := make([]uint8, n)
+			for j := range int(e.yres) {
+				newGfx = append(newGfx, e.gfx[i][j*xres+n:(j+1)*xres]...)
 				newGfx = append(newGfx, scroll...)
 			}
 			e.gfx[i] = newGfx
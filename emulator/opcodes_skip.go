@@ -3,30 +3,21 @@ package emulator
 // skipIfVXEqual: 3XNN: Skips the next instruction if VX equals NN
 func (e *Emulator) skipIfVXEqualsNN(X, NN uint8) {
 	if e.registers[X] == NN {
-		e.pc += 2
-		if e.opcodeAt(e.pc) == 0xF000 {
-			e.pc += 2
-		}
+		e.skipNext()
 	}
 }
 
 // skipIfVXNotEqual: 4XNN: Skips the next instruction if VX does not equal NN
 func (e *Emulator) skipIfVXNotEqualsNN(X, NN uint8) {
 	if e.registers[X] != NN {
-		e.pc += 2
-		if e.opcodeAt(e.pc) == 0xF000 {
-			e.pc += 2
-		}
+		e.skipNext()
 	}
 }
 
 // skipIfVXEqualsVY: 5XY0: Skips the next instruction if VX equals VY
 func (e *Emulator) skipIfVXEqualsVY(X, Y uint8) {
 	if e.registers[X] == e.registers[Y] {
-		e.pc += 2
-		if e.opcodeAt(e.pc) == 0xF000 {
-			e.pc += 2
-		}
+		e.skipNext()
 	}
 }
 
@@ -34,31 +25,31 @@ func (e *Emulator) skipIfVXEqualsVY(X, Y uint8) {
 // code block).
 func (e *Emulator) skipIfVXnotEqualsVY(X, Y uint8) {
 	if e.registers[X] != e.registers[Y] {
-		e.pc += 2
-		if e.opcodeAt(e.pc) == 0xF000 {
-			e.pc += 2
-		}
+		e.skipNext()
 	}
 }
 
 // skipIfPressed: EX9E: Skips the next instruction if the key stored in VX (only consider the lowest nibble) is pressed
 // (usually the next instruction is a jump to skip a code block)
 func (e *Emulator) skipIfPressed(X uint8) {
-	if e.keys[e.registers[X]] {
-		e.pc += 2
-		if e.opcodeAt(e.pc) == 0xF000 {
-			e.pc += 2
-		}
+	if e.keys[e.registers[X]&0x0F] {
+		e.skipNext()
 	}
 }
 
 // skipIfNotPressed EXA1: Skips the next instruction if the key stored in VX (only consider the lowest nibble) is not pressed
 // (usually the next instruction is a jump to skip a code block)
 func (e *Emulator) skipIfNotPressed(X uint8) {
-	if !e.keys[e.registers[X]] {
+	if !e.keys[e.registers[X]&0x0F] {
+		e.skipNext()
+	}
+}
+
+// skipNext skips over the next instruction, which is 4 bytes long for F000 NNNN and megachip's 01NN NNNN
+func (e *Emulator) skipNext() {
+	e.pc += 2
+	next := e.opcodeAt(e.pc)
+	if next == 0xF000 || (e.platform.Megachip && next&0xFF00 == 0x0100) {
 		e.pc += 2
-		if e.opcodeAt(e.pc) == 0xF000 {
-			e.pc += 2
-		}
 	}
 }
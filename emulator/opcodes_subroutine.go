@@ -1,12 +1,21 @@
 package emulator
 
-import "github.com/swensone/gorito/types"
+import (
+	"fmt"
+
+	"github.com/swensone/gorito/types"
+)
 
 // returnFromSubroutine: 00EE: Return from subroutine
-// Return from subroutine. Set the PC to the address at the top of the stack and subtract 1 from the SP.
+// Return from subroutine. Subtract 1 from the SP and set the PC to the address at the top of the stack.
 func (e *Emulator) returnFromSubroutine() {
-	e.pc = e.stack[e.sp]
+	if e.sp == 0 {
+		e.raise(types.FAULT_STACK_UNDERFLOW, "return with an empty stack")
+		return
+	}
+
 	e.sp--
+	e.pc = e.stack[e.sp]
 }
 
 // exitInterpreter: 00FD: Exits the interpreter (superchip extension)
@@ -22,14 +31,16 @@ func (e *Emulator) jumpToNNN(NNN uint16) {
 }
 
 // callNNN: 2NNN: Call subroutine at NNN
-// Increment the SP and put the current PC value on the top of the stack. Then set the PC to NNN. Generally there is a limit of 16 successive calls.
+// Put the current PC value on the top of the stack and increment the SP. Then set the PC to NNN. The number of successive
+// calls is limited by the platform's stack depth, 12 on the cosmac vip and 16 elsewhere.
 func (e *Emulator) callNNN(NNN uint16) {
-	e.sp++
-	if len(e.stack) <= int(e.sp) {
-		panic("stack overflow")
+	if int(e.sp) >= e.platform.StackDepth {
+		e.raise(types.FAULT_STACK_OVERFLOW, fmt.Sprintf("call nested deeper than %d levels", e.platform.StackDepth))
+		return
 	}
 
 	e.stack[e.sp] = e.pc
+	e.sp++
 	e.pc = NNN
 	e.pc -= 2
 }
@@ -37,7 +48,8 @@ func (e *Emulator) callNNN(NNN uint16) {
 // jumpToNNNplusV0: BNNN: Jumps to the address NNN plus V0
 // superChip works as BXNN: It will jump to the address XNN, plus the value in the register VX
 func (e *Emulator) jumpToNNNplusV0(X uint8, NNN uint16) {
-	if e.cfg.Mode != types.MODE_SUPERCHIP {
+	// handle jump quirk
+	if !e.cfg.Quirks.JumpWithVX {
 		X = 0
 	}
 	e.pc = uint16(e.registers[X]) + NNN
@@ -0,0 +1,117 @@
+package emulator
+
+import (
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/record"
+)
+
+// StartRecording records the screen and sound to fpath, a gif, apng or y4m file picked by its extension, with the
+// sound in a wav file next to it
+func (e *Emulator) StartRecording(fpath string) error {
+	if e.recorder != nil {
+		return errors.New("already recording")
+	}
+	r, err := record.New(fpath, e.cfg.ScreenshotScale, e.audio)
+	if err != nil {
+		return err
+	}
+
+	e.recorder = r
+	e.audio = r.Sound()
+	e.loadAudio()
+	e.log.Info("recording", "file", fpath)
+	return nil
+}
+
+// StopRecording finishes the recording, if there is one
+func (e *Emulator) StopRecording() error {
+	if e.recorder == nil {
+		return nil
+	}
+	r := e.recorder
+	e.recorder = nil
+	e.audio = r.Sound().Inner()
+	if err := r.Close(); err != nil {
+		return errors.Wrap(err, "failed to finish recording")
+	}
+	e.log.Info("finished recording")
+	return nil
+}
+
+// toggleRecording starts recording to a new file in the screenshot directory, or stops recording
+func (e *Emulator) toggleRecording() error {
+	if e.recorder != nil {
+		return e.StopRecording()
+	}
+
+	ext, err := record.Extension(e.cfg.RecordFormat)
+	if err != nil {
+		return err
+	}
+	fpath, err := e.capturePath(ext)
+	if err != nil {
+		return err
+	}
+	return e.StartRecording(fpath)
+}
+
+// recordFrame adds the frame to the recording. A recording that fails is stopped, the emulator carries on.
+func (e *Emulator) recordFrame() {
+	xres, yres := e.Resolution()
+	if err := e.recorder.Frame(e.getGfx(), xres, yres); err != nil {
+		e.log.Error("recording failed", "error", err)
+		if err := e.StopRecording(); err != nil {
+			e.log.Error("unable to stop recording", "error", err)
+		}
+	}
+}
+
+// StartAudioCapture captures everything the emulator plays to a 48khz wav file at fpath, as the speaker would play it.
+// It doesn't need an audio device so it works headless. A megachip sample already playing isn't captured.
+func (e *Emulator) StartAudioCapture(fpath string) error {
+	if e.capture != nil {
+		return errors.New("already capturing audio")
+	}
+	if e.recorder != nil {
+		return errors.New("unable to capture audio while recording")
+	}
+	s, err := record.CreateSound(fpath, e.audio)
+	if err != nil {
+		return err
+	}
+
+	e.capture = s
+	e.audio = s
+	e.loadAudio()
+	e.log.Info("capturing audio", "file", fpath)
+	return nil
+}
+
+// StopAudioCapture finishes the audio capture, if there is one. A recording started after it must be stopped first.
+func (e *Emulator) StopAudioCapture() error {
+	if e.capture == nil {
+		return nil
+	}
+	if e.recorder != nil {
+		return errors.New("unable to stop audio capture while recording")
+	}
+	s := e.capture
+	e.capture = nil
+	e.audio = s.Inner()
+	if err := s.Close(); err != nil {
+		return errors.Wrap(err, "failed to finish audio capture")
+	}
+	e.log.Info("finished audio capture")
+	return nil
+}
+
+// captureFrame adds the frame's sound to the audio capture, which is stopped if it fails
+func (e *Emulator) captureFrame() {
+	if err := e.capture.Frame(); err != nil {
+		e.log.Error("audio capture failed", "error", err)
+		if err := e.StopAudioCapture(); err != nil {
+			e.log.Error("unable to stop audio capture", "error", err)
+		}
+	}
+}
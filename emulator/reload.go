@@ -0,0 +1,117 @@
+package emulator
+
+import (
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/fsnotify/fsnotify"
+)
+
+// RELOAD_SETTLE is how long to wait after a change before reloading, editors often write a file in several steps
+const RELOAD_SETTLE = 50 * time.Millisecond
+
+// ErrAssemble marks errors from assembling octo source, which are shown on screen rather than stopping the emulator
+var ErrAssemble = errors.New("failed to assemble")
+
+// sourceWatcher signals when an octo source file changes. It watches the directory rather than the file itself, since
+// editors that save by replacing the file would otherwise end the watch.
+type sourceWatcher struct {
+	watcher *fsnotify.Watcher
+	file    string
+	changed chan struct{}
+	log     *slog.Logger
+}
+
+func newSourceWatcher(file string, log *slog.Logger) (*sourceWatcher, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create file watcher")
+	}
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return nil, errors.Wrapf(err, "unable to watch %s", file)
+	}
+
+	w := &sourceWatcher{
+		watcher: watcher,
+		file:    file,
+		changed: make(chan struct{}, 1),
+		log:     log,
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *sourceWatcher) run() {
+	for {
+		select {
+		case ev, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(ev.Name) != w.file || !ev.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			// a change is already pending if the channel is full
+			select {
+			case w.changed <- struct{}{}:
+			default:
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.log.Error("error watching source file", "file", w.file, "error", err)
+		}
+	}
+}
+
+// Changed returns true if the file has changed since the last call, waiting for writes to settle before returning
+func (w *sourceWatcher) Changed() bool {
+	select {
+	case <-w.changed:
+	default:
+		return false
+	}
+
+	time.Sleep(RELOAD_SETTLE)
+	select {
+	case <-w.changed:
+	default:
+	}
+	return true
+}
+
+func (w *sourceWatcher) Close() error {
+	return w.watcher.Close()
+}
+
+// reload resets the emulator and loads the program again. Assembly errors are logged and shown on screen until the
+// next reload, anything else is returned.
+func (e *Emulator) reload(rom string) error {
+	e.Reset()
+	e.loadErr = nil
+
+	if err := e.LoadProgram(rom); err != nil {
+		if !errors.Is(err, ErrAssemble) {
+			return err
+		}
+		return e.showLoadError(err)
+	}
+
+	e.log.Info("loaded program", "file", rom)
+	return nil
+}
+
+// showLoadError logs an error loading the program and shows it on screen in place of the program
+func (e *Emulator) showLoadError(err error) error {
+	e.log.Error("failed to load program", "error", err)
+	e.loadErr = err
+	return e.showMessage(err.Error())
+}
@@ -0,0 +1,64 @@
+package emulator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/types"
+)
+
+func TestLoadSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      types.Mode
+		src       string
+		start     int
+		want      []byte
+		assembles bool
+	}{
+		{"chip-8", types.MODE_CHIP8, ": main v0 := 1 jump main", 0x200, []byte{0x60, 0x01, 0x12, 0x00}, true},
+		{"chip-8x start address", types.MODE_CHIP8X, ": main jump main", 0x300, []byte{0x13, 0x00}, true},
+		{"assembly error", types.MODE_CHIP8, "jump nowhere", 0x200, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "game.8o")
+			if err := os.WriteFile(file, []byte(tt.src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			e := newTestEmulator(t, tt.mode)
+			err := e.LoadProgram(file)
+			assert.Equal(t, err == nil, tt.assembles)
+			if !tt.assembles {
+				assert.Equal(t, errors.Is(err, ErrAssemble), true)
+				return
+			}
+			assert.Equal(t, e.memory[tt.start:tt.start+len(tt.want)], tt.want)
+		})
+	}
+}
+
+func TestWrapMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"short", "hello", []string{"hello"}},
+		{"wrapped at spaces", "game.8o:3: undefined name nowhere", []string{"game.8o:3: undefined", "name nowhere"}},
+		{"long words split", "abcdefghijklmnopqrstuvwxyz", []string{"abcdefghijklmnopqrst", "uvwxyz"}},
+		{"newlines", "one\ntwo", []string{"one", "two"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, wrapMessage(tt.text, 20), tt.want)
+		})
+	}
+}
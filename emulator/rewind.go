@@ -0,0 +1,177 @@
+package emulator
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// REWIND_MAX_BYTES bounds the memory used by the rewind buffer regardless of the configured number of frames
+	REWIND_MAX_BYTES = 64 * 1024 * 1024
+	// REWIND_MAX_MEMORY is the largest platform memory that is snapshotted for rewinding
+	REWIND_MAX_MEMORY = 64 * 1024
+)
+
+// rewindBuffer keeps the most recent frame as a full state and every earlier frame as a compressed xor delta against
+// the frame after it. Since most of memory doesn't change from frame to frame the deltas are mostly zeros and
+// compress very well, and stepping backwards is just xor-ing the newest delta into the current state.
+type rewindBuffer struct {
+	frames  int
+	entries []rewindEntry
+	start   int
+	count   int
+	size    int
+	head    []byte
+}
+
+type rewindEntry struct {
+	delta   []byte
+	prevLen int
+}
+
+func newRewindBuffer(frames int) *rewindBuffer {
+	return &rewindBuffer{
+		frames:  frames,
+		entries: make([]rewindEntry, frames),
+	}
+}
+
+// push records the state for a new frame
+func (r *rewindBuffer) push(state []byte) error {
+	if r.head != nil {
+		delta, err := compress(xorBytes(r.head, state))
+		if err != nil {
+			return err
+		}
+
+		// drop the oldest frames if we're full or over the memory budget
+		for r.count > 0 && (r.count == r.frames || r.size+len(delta) > REWIND_MAX_BYTES) {
+			r.size -= len(r.entries[r.start].delta)
+			r.entries[r.start] = rewindEntry{}
+			r.start = (r.start + 1) % r.frames
+			r.count--
+		}
+
+		r.entries[(r.start+r.count)%r.frames] = rewindEntry{delta: delta, prevLen: len(r.head)}
+		r.count++
+		r.size += len(delta)
+	}
+	r.head = state
+	return nil
+}
+
+// pop steps back one frame and returns that frame's state, or false if there's nothing left to rewind
+func (r *rewindBuffer) pop() ([]byte, bool, error) {
+	if r.count == 0 {
+		return nil, false, nil
+	}
+
+	last := (r.start + r.count - 1) % r.frames
+	entry := r.entries[last]
+	r.entries[last] = rewindEntry{}
+	r.count--
+	r.size -= len(entry.delta)
+
+	delta, err := decompress(entry.delta)
+	if err != nil {
+		return nil, false, err
+	}
+	r.head = xorBytes(r.head, delta)[:entry.prevLen]
+	return r.head, true, nil
+}
+
+// xorBytes xors two byte slices, treating the shorter one as zero padded
+func xorBytes(a, b []byte) []byte {
+	res := make([]byte, max(len(a), len(b)))
+	copy(res, a)
+	for i, v := range b {
+		res[i] ^= v
+	}
+	return res
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	return io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+}
+
+// encodeState serializes the machine state with a fixed layout so that consecutive frames line up byte for byte
+func (e *Emulator) encodeState() ([]byte, error) {
+	st := e.snapshot()
+	var buf bytes.Buffer
+	for _, v := range st.fields() {
+		if err := binary.Write(&buf, binary.BigEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeState restores a state serialized by encodeState
+func (e *Emulator) decodeState(data []byte) error {
+	// use the current state as a template so all of the slices are the right size
+	st := e.snapshot()
+	r := bytes.NewReader(data)
+	for _, v := range st.fields() {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return errors.Wrap(err, "unable to decode rewind state")
+		}
+	}
+	return e.restore(st)
+}
+
+// fields returns pointers to every field of the state, in serialization order. The mode is left out as it can't change
+// between frames.
+func (st *machineState) fields() []any {
+	fields := []any{
+		&st.Quirks, st.Memory, st.Registers, &st.Stack, &st.SP, &st.PC, &st.Idx, &st.Timer, &st.DelayTimer,
+		&st.SoundTimer, &st.Counter, &st.Frame, st.RNG, &st.Plane, &st.Hires, &st.AudioPattern, &st.Pitch,
+		&st.Megachip.Enabled, &st.Megachip.Palette, &st.Megachip.Alpha, &st.Megachip.SpriteWidth,
+		&st.Megachip.SpriteHeight, &st.Megachip.ScreenAlpha, &st.Megachip.BlendMode, &st.Megachip.CollisionColor,
+		st.Megachip.Back, st.Megachip.Front, st.Megachip.Indexes, &st.Chip8X,
+	}
+	for _, plane := range st.Gfx {
+		fields = append(fields, plane)
+	}
+	return fields
+}
+
+// rewindFrame records the current frame, or while rewinding restores the previous one
+func (e *Emulator) rewindFrame() error {
+	if e.rewind == nil {
+		return nil
+	}
+
+	if !e.rewinding {
+		state, err := e.encodeState()
+		if err != nil {
+			return err
+		}
+		return e.rewind.push(state)
+	}
+
+	e.audio.Stop()
+	state, ok, err := e.rewind.pop()
+	if err != nil || !ok {
+		return err
+	}
+	return e.decodeState(state)
+}
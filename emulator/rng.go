@@ -0,0 +1,103 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+
+	"github.com/cockroachdb/errors"
+)
+
+// RNG supplies the random numbers for CXNN. It's reseeded whenever the emulator resets, so that a program sees the
+// same numbers every time it runs with the same seed.
+type RNG interface {
+	// Seed restarts the sequence of numbers
+	Seed(seed int64)
+	// Byte returns a number from 0 to 255
+	Byte() uint8
+	// Name identifies the generator, pcg or vip
+	Name() string
+	// State returns the position in the sequence, for save states and rewinding
+	State() []byte
+	// SetState restores a position returned by State
+	SetState(state []byte) error
+}
+
+// pcgRNG is a permuted congruential generator, the default
+type pcgRNG struct {
+	pcg *rand.PCG
+}
+
+// NewPCG returns a seeded pcg generator
+func NewPCG() RNG {
+	return &pcgRNG{pcg: rand.NewPCG(0, 0)}
+}
+
+func (r *pcgRNG) Seed(seed int64) {
+	r.pcg.Seed(uint64(seed), 0)
+}
+
+func (r *pcgRNG) Byte() uint8 {
+	return uint8(r.pcg.Uint64() >> 56)
+}
+
+func (r *pcgRNG) Name() string {
+	return "pcg"
+}
+
+func (r *pcgRNG) State() []byte {
+	// marshalling a pcg never fails
+	state, _ := r.pcg.MarshalBinary()
+	return state
+}
+
+func (r *pcgRNG) SetState(state []byte) error {
+	return errors.Wrap(r.pcg.UnmarshalBinary(state), "invalid pcg state")
+}
+
+// VIP_PAGE is the page of the cosmac vip interpreter read by its random number routine
+const VIP_PAGE = 0x100
+
+// vipRNG follows the cosmac vip interpreter's CXNN routine. R9 is stepped for every number, its low byte picks a byte
+// of interpreter code from page 0x100, and that byte is added to its high byte, which is the result. The numbers
+// depend on the interpreter's code, so it's needed to get the same numbers as a real vip.
+type vipRNG struct {
+	page [256]uint8
+	r9   uint16
+}
+
+// NewVIP returns a vip generator, reading from an image of the 512 byte cosmac vip chip-8 interpreter
+func NewVIP(interpreter []byte) (RNG, error) {
+	if len(interpreter) != 2*VIP_PAGE {
+		return nil, errors.Newf("the vip interpreter is %d bytes, not %d", len(interpreter), 2*VIP_PAGE)
+	}
+	r := &vipRNG{}
+	copy(r.page[:], interpreter[VIP_PAGE:])
+	return r, nil
+}
+
+func (r *vipRNG) Seed(seed int64) {
+	r.r9 = uint16(seed)
+}
+
+func (r *vipRNG) Byte() uint8 {
+	r.r9++
+	hi := uint8(r.r9>>8) + r.page[uint8(r.r9)]
+	r.r9 = uint16(hi)<<8 | r.r9&0xFF
+	return hi
+}
+
+func (r *vipRNG) Name() string {
+	return "vip"
+}
+
+func (r *vipRNG) State() []byte {
+	return binary.BigEndian.AppendUint16(nil, r.r9)
+}
+
+func (r *vipRNG) SetState(state []byte) error {
+	if len(state) != 2 {
+		return errors.New("invalid vip state")
+	}
+	r.r9 = binary.BigEndian.Uint16(state)
+	return nil
+}
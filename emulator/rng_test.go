@@ -0,0 +1,159 @@
+package emulator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/types"
+)
+
+func TestRNG(t *testing.T) {
+	vip, err := NewVIP(make([]byte, 2*VIP_PAGE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		rng  RNG
+	}{
+		{"pcg", NewPCG()},
+		{"vip", vip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the same seed gives the same numbers
+			tt.rng.Seed(42)
+			first := make([]uint8, 16)
+			for i := range first {
+				first[i] = tt.rng.Byte()
+			}
+			tt.rng.Seed(42)
+			for i := range first {
+				assert.Equal(t, tt.rng.Byte(), first[i])
+			}
+
+			// restoring the state picks up the sequence from the same place
+			tt.rng.Seed(42)
+			tt.rng.Byte()
+			state := tt.rng.State()
+			tt.rng.Byte()
+			if err := tt.rng.SetState(state); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.rng.Byte(), first[1])
+			if err := tt.rng.SetState([]byte{1, 2, 3}); err == nil {
+				t.Fatal("expected an error restoring an invalid state")
+			}
+		})
+	}
+}
+
+func TestPCGRange(t *testing.T) {
+	rng := NewPCG()
+	rng.Seed(1)
+	seen := map[uint8]bool{}
+	for range 10000 {
+		seen[rng.Byte()] = true
+	}
+	assert.Equal(t, len(seen), 256)
+}
+
+func TestVIP(t *testing.T) {
+	interpreter := make([]byte, 2*VIP_PAGE)
+	interpreter[VIP_PAGE+1] = 0x10
+	interpreter[VIP_PAGE+2] = 0x05
+	vip, err := NewVIP(interpreter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vip.Seed(0x3000)
+
+	// R9 steps to 3001 and 3002, adding the bytes at 0x101 and 0x102 to the high byte
+	assert.Equal(t, vip.Byte(), uint8(0x40))
+	assert.Equal(t, vip.Byte(), uint8(0x45))
+	assert.Equal(t, vip.Byte(), uint8(0x45))
+
+	_, err = NewVIP(make([]byte, 100))
+	assert.Equal(t, err.Error(), "the vip interpreter is 100 bytes, not 512")
+}
+
+func TestRandomOpcodeIsSeeded(t *testing.T) {
+	// v0 := random 0xFF, twice, resetting in between
+	rom := []byte{0xC0, 0xFF}
+	values := []uint8{}
+	for range 2 {
+		e := newTestEmulator(t, types.MODE_CHIP8)
+		e.cfg.Seed = 7
+		e.Reset()
+		if err := e.LoadROM(rom); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Step(); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, e.Registers()[0])
+	}
+	assert.Equal(t, values[0], values[1])
+}
+
+func TestRandomOpcodeAfterRestore(t *testing.T) {
+	tests := []struct {
+		name    string
+		save    func(e *Emulator) []byte
+		restore func(e *Emulator, state []byte) error
+	}{
+		{
+			"save state",
+			func(e *Emulator) []byte {
+				var buf bytes.Buffer
+				if err := e.SaveState(&buf); err != nil {
+					t.Fatal(err)
+				}
+				return buf.Bytes()
+			},
+			func(e *Emulator, state []byte) error { return e.LoadState(bytes.NewReader(state)) },
+		},
+		{
+			"rewind",
+			func(e *Emulator) []byte {
+				state, err := e.encodeState()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return state
+			},
+			func(e *Emulator, state []byte) error { return e.decodeState(state) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEmulator(t, types.MODE_CHIP8)
+			// v0 := random 0xFF forever
+			if err := e.LoadROM([]byte{0xC0, 0xFF, 0x12, 0x00}); err != nil {
+				t.Fatal(err)
+			}
+			random := func() []uint8 {
+				var values []uint8
+				for range 4 {
+					e.Step()
+					e.Step()
+					values = append(values, e.Registers()[0])
+				}
+				return values
+			}
+
+			random()
+			state := tt.save(e)
+			want := random()
+			if err := tt.restore(e, state); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, random(), want)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/types"
+)
+
+// save state files start with a magic number and a version, followed by a gob encoded machineState. Bump the version
+// whenever machineState changes in a way that older states can't be decoded into.
+const (
+	STATE_MAGIC   = "GRTS"
+	STATE_VERSION = uint16(6)
+)
+
+// machineState is a snapshot of everything needed to resume the machine
+type machineState struct {
+	Mode         types.Mode
+	Quirks       types.Quirks
+	Memory       []uint8
+	Registers    []uint8
+	Stack        [16]uint16
+	SP           uint8
+	PC           uint16
+	Idx          uint32
+	Timer        uint32
+	DelayTimer   uint8
+	SoundTimer   uint8
+	Counter      uint64
+	Frame        uint64
+	RNG          []byte
+	Gfx          [][]uint8
+	Plane        uint8
+	Hires        bool
+	AudioPattern [16]uint8
+	Pitch        uint8
+	Megachip     megachip
+	Chip8X       chip8x
+}
+
+func (e *Emulator) snapshot() machineState {
+	st := machineState{
+		Mode:         e.cfg.Mode,
+		Quirks:       e.cfg.Quirks,
+		Memory:       append([]uint8(nil), e.memory...),
+		Registers:    append([]uint8(nil), e.registers...),
+		Stack:        e.stack,
+		SP:           e.sp,
+		PC:           e.pc,
+		Idx:          e.idx,
+		Timer:        e.timer,
+		DelayTimer:   e.delayTimer,
+		SoundTimer:   e.soundTimer,
+		Counter:      e.counter,
+		Frame:        uint64(e.frame),
+		RNG:          e.rng.State(),
+		Plane:        e.plane,
+		Hires:        e.hires,
+		AudioPattern: e.audio_pattern,
+		Pitch:        e.pitch,
+		Megachip:     e.mega.clone(),
+		Chip8X:       e.c8x,
+	}
+	for i := range e.platform.Planes {
+		st.Gfx = append(st.Gfx, append([]uint8(nil), e.gfx[i]...))
+	}
+	return st
+}
+
+func (e *Emulator) restore(st machineState) error {
+	if st.Mode != e.cfg.Mode {
+		return errors.Errorf("save state is for %s, not %s", st.Mode.String(), e.cfg.Mode.String())
+	}
+	if int(st.SP) > e.platform.StackDepth {
+		return errors.Errorf("save state stack pointer %d is deeper than the %d level stack", st.SP, e.platform.StackDepth)
+	}
+	if len(st.Memory) != len(e.memory) || len(st.Registers) != len(e.registers) || len(st.Gfx) != len(e.gfx) {
+		return errors.New("save state does not match the emulator layout")
+	}
+	for i := range e.platform.Planes {
+		if len(st.Gfx[i]) != len(e.gfx[i]) {
+			return errors.New("save state does not match the emulator layout")
+		}
+	}
+	if len(st.Megachip.Back) != len(e.mega.Back) || len(st.Megachip.Front) != len(e.mega.Front) ||
+		len(st.Megachip.Indexes) != len(e.mega.Indexes) {
+		return errors.New("save state does not match the emulator layout")
+	}
+
+	if err := e.rng.SetState(st.RNG); err != nil {
+		return errors.Wrapf(err, "save state doesn't match the %s random number generator", e.rng.Name())
+	}
+
+	e.cfg.Quirks = st.Quirks
+	copy(e.memory, st.Memory)
+	copy(e.registers, st.Registers)
+	e.stack = st.Stack
+	e.sp = st.SP
+	e.pc = st.PC
+	e.idx = st.Idx
+	e.timer = st.Timer
+	e.delayTimer = st.DelayTimer
+	e.soundTimer = st.SoundTimer
+	e.counter = st.Counter
+	e.frame = int(st.Frame)
+	for i := range e.platform.Planes {
+		copy(e.gfx[i], st.Gfx[i])
+	}
+	e.plane = st.Plane
+	e.hires = st.Hires
+	e.audio_pattern = st.AudioPattern
+	e.pitch = st.Pitch
+	e.mega = st.Megachip.clone()
+	e.c8x = st.Chip8X
+
+	e.loadAudio()
+	e.drawFlag = true
+
+	return nil
+}
+
+// SaveState writes a versioned snapshot of the full machine to w
+func (e *Emulator) SaveState(w io.Writer) error {
+	if _, err := io.WriteString(w, STATE_MAGIC); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, STATE_VERSION); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(e.snapshot())
+}
+
+// LoadState restores a snapshot written by SaveState
+func (e *Emulator) LoadState(r io.Reader) error {
+	magic := make([]byte, len(STATE_MAGIC))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return errors.Wrap(err, "unable to read save state header")
+	}
+	if !bytes.Equal(magic, []byte(STATE_MAGIC)) {
+		return errors.New("not a gorito save state")
+	}
+
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return errors.Wrap(err, "unable to read save state version")
+	}
+	if version != STATE_VERSION {
+		return errors.Errorf("unsupported save state version %d, expected %d", version, STATE_VERSION)
+	}
+
+	st := machineState{}
+	if err := gob.NewDecoder(r).Decode(&st); err != nil {
+		return errors.Wrap(err, "unable to decode save state")
+	}
+	return e.restore(st)
+}
+
+// SaveSlot saves the machine state to a numbered slot for the current rom
+func (e *Emulator) SaveSlot(slot int) error {
+	fpath := e.storage.statePath(e.rom, slot)
+	if err := os.MkdirAll(filepath.Dir(fpath), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := e.SaveState(f); err != nil {
+		return errors.Wrapf(err, "failed to save state to %s", fpath)
+	}
+	e.log.Info("saved state", "slot", slot, "file", fpath)
+	return nil
+}
+
+// LoadSlot restores the machine state from a numbered slot for the current rom
+func (e *Emulator) LoadSlot(slot int) error {
+	fpath := e.storage.statePath(e.rom, slot)
+	f, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := e.LoadState(f); err != nil {
+		return errors.Wrapf(err, "failed to load state from %s", fpath)
+	}
+	e.log.Info("loaded state", "slot", slot, "file", fpath)
+	return nil
+}
+
+// statePath returns the save state file for a rom and slot, in a directory next to the storage file
+func (s *storage) statePath(rom string, slot int) string {
+	if rom == "" {
+		rom = "unknown"
+	}
+	return filepath.Join(filepath.Dir(s.filename), "gorito-states", fmt.Sprintf("%s.%d.state", rom, slot))
+}
@@ -0,0 +1,86 @@
+package emulator
+
+import "time"
+
+const (
+	// FRAME_RATE is the rate the timers tick at, and so the rate programs expect frames to run at
+	FRAME_RATE = 60
+	// SCHEDULER_MAX_LAG is how far the clock can fall behind before the scheduler gives up catching up
+	SCHEDULER_MAX_LAG = 4 * time.Second / FRAME_RATE
+	// DEFAULT_TURBO and DEFAULT_SLOW_MOTION are the speed multipliers used while their hotkeys are held, unless
+	// configured
+	DEFAULT_TURBO       = 4.0
+	DEFAULT_SLOW_MOTION = 0.25
+)
+
+// scheduler paces the host loop and works out how many emulated frames to run on each pass. Without vsync a pass is
+// 1/60s, timed against absolute deadlines so that sleeping late doesn't add up to drift. With vsync the display's
+// Draw blocks until the vertical blank and a pass is one refresh, which may not be 60hz, so the frames owed are
+// accumulated as a fraction. The same accumulator gives whole frames for the turbo and slow motion multipliers.
+type scheduler struct {
+	vsync       bool
+	unthrottled bool          // don't wait at all
+	period      time.Duration // between passes when pacing with the clock
+	rate        float64       // emulated frames per pass at normal speed
+	next        time.Time
+	credit      float64
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// newScheduler creates a scheduler paced by the clock, or by a display refreshing at refresh hz when it's not 0
+func newScheduler(refresh int) *scheduler {
+	s := &scheduler{
+		period: time.Second / FRAME_RATE,
+		rate:   1,
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+	if refresh > 0 {
+		s.vsync = true
+		s.rate = float64(FRAME_RATE) / float64(refresh)
+	}
+	return s
+}
+
+// frames returns the number of emulated frames to run in this pass at the given speed multiplier
+func (s *scheduler) frames(multiplier float64) int {
+	s.credit += s.rate * multiplier
+	n := int(s.credit)
+	s.credit -= float64(n)
+	return n
+}
+
+// wait blocks until the next pass is due. With vsync the display has already waited.
+func (s *scheduler) wait() {
+	if s.vsync || s.unthrottled {
+		return
+	}
+
+	now := s.now()
+	if s.next.IsZero() || now.Sub(s.next) > SCHEDULER_MAX_LAG {
+		s.next = now
+	}
+	s.next = s.next.Add(s.period)
+	if d := s.next.Sub(now); d > 0 {
+		s.sleep(d)
+	}
+}
+
+// reset forgets the schedule after a pause, so that the time spent paused isn't caught up on
+func (s *scheduler) reset() {
+	s.next = time.Time{}
+	s.credit = 0
+}
+
+// speedMultiplier returns how fast frames run relative to 60hz, depending on the held hotkeys
+func (e *Emulator) speedMultiplier() float64 {
+	switch {
+	case e.turbo:
+		return e.cfg.Turbo
+	case e.slowMotion:
+		return e.cfg.SlowMotion
+	}
+	return 1
+}
@@ -0,0 +1,84 @@
+package emulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSchedulerFrames(t *testing.T) {
+	tests := []struct {
+		name       string
+		refresh    int
+		multiplier float64
+		frames     []int
+	}{
+		{"clock", 0, 1, []int{1, 1, 1, 1}},
+		{"turbo", 0, 4, []int{4, 4, 4, 4}},
+		{"slow motion", 0, 0.25, []int{0, 0, 0, 1}},
+		{"vsync at 60hz", 60, 1, []int{1, 1, 1, 1}},
+		{"vsync at 120hz", 120, 1, []int{0, 1, 0, 1}},
+		{"vsync at 30hz", 30, 1, []int{2, 2, 2, 2}},
+		{"turbo vsync at 120hz", 120, 4, []int{2, 2, 2, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScheduler(tt.refresh)
+			var frames []int
+			for range tt.frames {
+				frames = append(frames, s.frames(tt.multiplier))
+			}
+			assert.Equal(t, frames, tt.frames)
+		})
+	}
+}
+
+func TestSchedulerWait(t *testing.T) {
+	period := time.Second / FRAME_RATE
+	tests := []struct {
+		name   string
+		work   []time.Duration // time spent between each wait
+		sleeps []time.Duration
+	}{
+		{
+			"sleeps out the frame",
+			[]time.Duration{0, 0, 0},
+			[]time.Duration{period, period, period},
+		},
+		{
+			"makes up for a late frame",
+			[]time.Duration{0, 2*period + period/2, 0, 0},
+			[]time.Duration{period, 0, 0, period / 2},
+		},
+		{
+			"gives up when far behind",
+			[]time.Duration{0, 10 * period, 0},
+			[]time.Duration{period, period, period},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			var sleeps []time.Duration
+			s := newScheduler(0)
+			s.now = func() time.Time { return now }
+			s.sleep = func(d time.Duration) {
+				sleeps = append(sleeps, d)
+				now = now.Add(d)
+			}
+
+			for _, work := range tt.work {
+				now = now.Add(work)
+				before := len(sleeps)
+				s.wait()
+				if len(sleeps) == before {
+					sleeps = append(sleeps, 0)
+				}
+			}
+			assert.Equal(t, sleeps, tt.sleeps)
+		})
+	}
+}
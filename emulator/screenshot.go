@@ -0,0 +1,78 @@
+package emulator
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/screen"
+	"github.com/swensone/gorito/types"
+)
+
+// Screen returns the screen at its native resolution. Low resolution screens are 64x32 rather than doubled up to the
+// framebuffer's 128x64, unless half pixel scrolling can have moved them out of line.
+func (e *Emulator) Screen() ([]types.Color, int32, int32) {
+	gfx := e.getGfx()
+	xres, yres := e.Resolution()
+	if e.mega.Enabled || e.hires || e.cfg.Quirks.HalfPixelScroll {
+		return gfx, xres, yres
+	}
+
+	w, h := xres/2, yres/2
+	native := make([]types.Color, w*h)
+	for y := range h {
+		for x := range w {
+			native[y*w+x] = gfx[2*y*xres+2*x]
+		}
+	}
+	return native, w, h
+}
+
+// Screenshot saves the screen as a png named after the rom and the time, returning its path. It's saved in the
+// configured directory, or next to the save file, and scaled up by the configured factor.
+func (e *Emulator) Screenshot() (string, error) {
+	fpath, err := e.capturePath(".png")
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Create(fpath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	gfx, xres, yres := e.Screen()
+	if err := screen.WritePNG(f, gfx, xres, yres, e.cfg.ScreenshotScale); err != nil {
+		return "", errors.Wrapf(err, "failed to write screenshot %s", fpath)
+	}
+	e.log.Info("saved screenshot", "file", fpath)
+	return fpath, f.Close()
+}
+
+// capturePath returns a file for a screenshot or recording, named after the rom and the time, creating the directory
+// for it
+func (e *Emulator) capturePath(ext string) (string, error) {
+	dir := e.cfg.ScreenshotDir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(e.storage.filename), "gorito-screenshots")
+	}
+	dir = path.Clean(dir)
+	if strings.HasPrefix(dir, "~/") {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, dir[2:])
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	rom := e.rom
+	if rom == "" {
+		rom = "unknown"
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", rom, time.Now().Format("20060102-150405.000"), ext)), nil
+}
@@ -0,0 +1,133 @@
+package gdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+)
+
+// INTERRUPT is sent by the client outside of a packet to stop the running program
+const INTERRUPT = 0x03
+
+var errChecksum = errors.New("packet checksum mismatch")
+
+// conn frames packets on a connection to a client. Packets are read on their own goroutine so that an interrupt can
+// arrive while the emulator runs.
+type conn struct {
+	rw         io.ReadWriteCloser
+	wmu        sync.Mutex
+	noAck      atomic.Bool
+	packets    chan string
+	interrupts chan struct{}
+}
+
+func newConn(rw io.ReadWriteCloser) *conn {
+	c := &conn{
+		rw:         rw,
+		packets:    make(chan string),
+		interrupts: make(chan struct{}, 1),
+	}
+	go c.read()
+	return c
+}
+
+// read acknowledges and delivers packets until the connection closes, then closes packets
+func (c *conn) read() {
+	defer close(c.packets)
+	r := bufio.NewReader(c.rw)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+
+		// acks from the client and anything else between packets are ignored, tcp already retransmits
+		switch b {
+		case INTERRUPT:
+			select {
+			case c.interrupts <- struct{}{}:
+			default:
+			}
+		case '$':
+			data, err := readPacket(r)
+			if errors.Is(err, errChecksum) {
+				c.writeRaw("-")
+				continue
+			} else if err != nil {
+				return
+			}
+			if !c.noAck.Load() {
+				c.writeRaw("+")
+			}
+			c.packets <- data
+		}
+	}
+}
+
+// readPacket reads the rest of a packet after the $, checking its checksum and undoing any escaping
+func readPacket(r *bufio.Reader) (string, error) {
+	raw, err := r.ReadBytes('#')
+	if err != nil {
+		return "", err
+	}
+	raw = raw[:len(raw)-1]
+
+	var sum [2]byte
+	if _, err := io.ReadFull(r, sum[:]); err != nil {
+		return "", err
+	}
+	want, err := strconv.ParseUint(string(sum[:]), 16, 8)
+	if err != nil || uint8(want) != checksum(raw) {
+		return "", errChecksum
+	}
+
+	data := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '}' && i+1 < len(raw) {
+			i++
+			data = append(data, raw[i]^0x20)
+			continue
+		}
+		data = append(data, raw[i])
+	}
+	return string(data), nil
+}
+
+// writePacket frames and sends a packet, escaping the characters that can't appear in one
+func (c *conn) writePacket(data string) error {
+	var escaped []byte
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '#', '$', '}', '*':
+			escaped = append(escaped, '}', data[i]^0x20)
+		default:
+			escaped = append(escaped, data[i])
+		}
+	}
+	return c.writeRaw(fmt.Sprintf("$%s#%02x", escaped, checksum(escaped)))
+}
+
+func (c *conn) writeRaw(s string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := io.WriteString(c.rw, s)
+	return err
+}
+
+func (c *conn) Close() error {
+	return c.rw.Close()
+}
+
+// checksum is the sum of a packet's bytes, modulo 256
+func checksum(data []byte) uint8 {
+	var sum uint8
+	for _, b := range data {
+		sum += b
+	}
+	return sum
+}
@@ -0,0 +1,122 @@
+package gdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/emulator"
+)
+
+// register numbers past V0-VF, in the order gdb sees them
+const (
+	REG_I = iota + 16
+	REG_PC
+	REG_SP
+	REG_DT
+	REG_ST
+	REG_COUNT
+)
+
+// register describes one of the registers sent in a g packet
+type register struct {
+	name string
+	bits int
+	kind string // the gdb type in target.xml
+}
+
+var registers = func() []register {
+	regs := make([]register, REG_COUNT)
+	for i := range 16 {
+		regs[i] = register{name: fmt.Sprintf("v%x", i), bits: 8, kind: "uint8"}
+	}
+	// I is 24 bits on megachip, gdb wants whole bytes and the usual sizes
+	regs[REG_I] = register{name: "i", bits: 32, kind: "data_ptr"}
+	regs[REG_PC] = register{name: "pc", bits: 16, kind: "code_ptr"}
+	regs[REG_SP] = register{name: "sp", bits: 8, kind: "uint8"}
+	regs[REG_DT] = register{name: "dt", bits: 8, kind: "uint8"}
+	regs[REG_ST] = register{name: "st", bits: 8, kind: "uint8"}
+	return regs
+}()
+
+// targetXML describes the registers, so clients don't need to know about chip-8
+var targetXML = func() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?>` + "\n")
+	b.WriteString(`<!DOCTYPE target SYSTEM "gdb-target.dtd">` + "\n")
+	b.WriteString(`<target version="1.0">` + "\n")
+	b.WriteString(`  <feature name="org.gorito.chip8">` + "\n")
+	for n, r := range registers {
+		fmt.Fprintf(&b, `    <reg name="%s" bitsize="%d" type="%s" regnum="%d"/>`+"\n", r.name, r.bits, r.kind, n)
+	}
+	b.WriteString("  </feature>\n")
+	b.WriteString("</target>\n")
+	return b.String()
+}()
+
+// readRegister returns a register's value
+func readRegister(e *emulator.Emulator, n int) uint32 {
+	switch n {
+	case REG_I:
+		return e.Index()
+	case REG_PC:
+		return uint32(e.PC())
+	case REG_SP:
+		return uint32(e.SP())
+	case REG_DT:
+		dt, _ := e.Timers()
+		return uint32(dt)
+	case REG_ST:
+		_, st := e.Timers()
+		return uint32(st)
+	}
+	return uint32(e.Registers()[n])
+}
+
+// writeRegister sets a register, it must be called from the emulator's goroutine
+func writeRegister(e *emulator.Emulator, n int, val uint32) error {
+	switch n {
+	case REG_I:
+		e.SetIndex(val)
+	case REG_PC:
+		e.SetPC(uint16(val))
+	case REG_SP:
+		return e.SetSP(uint8(val))
+	case REG_DT:
+		_, st := e.Timers()
+		e.SetTimers(uint8(val), st)
+	case REG_ST:
+		dt, _ := e.Timers()
+		e.SetTimers(dt, uint8(val))
+	default:
+		e.SetRegister(uint8(n), uint8(val))
+	}
+	return nil
+}
+
+// encodeRegister writes a register's value as target byte order, little endian, hex
+func encodeRegister(b *strings.Builder, n int, val uint32) {
+	for i := range registers[n].bits / 8 {
+		fmt.Fprintf(b, "%02x", uint8(val>>(8*i)))
+	}
+}
+
+// decodeRegister parses a little endian hex value from the front of s, returning the rest of s
+func decodeRegister(s string, n int) (uint32, string, error) {
+	size := registers[n].bits / 4
+	if len(s) < size {
+		return 0, "", errors.Newf("register %s needs %d hex digits", registers[n].name, size)
+	}
+
+	var val uint32
+	for i := 0; i < size; i += 2 {
+		b, err := strconv.ParseUint(s[i:i+2], 16, 8)
+		if err != nil {
+			return 0, "", errors.Wrapf(err, "invalid value for register %s", registers[n].name)
+		}
+		val |= uint32(b) << (4 * i)
+	}
+	return val, s[size:], nil
+}
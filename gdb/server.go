@@ -0,0 +1,507 @@
+package gdb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/debugger"
+	"github.com/swensone/gorito/emulator"
+	"github.com/swensone/gorito/types"
+)
+
+// PACKET_SIZE is the largest packet the server accepts, advertised to clients in qSupported
+const PACKET_SIZE = 0x1000
+
+// Server exposes an emulator over the gdb remote serial protocol, so gdb and other front ends can inspect and change
+// registers and memory, set breakpoints and watchpoints, and step. One client is served at a time.
+type Server struct {
+	emu *emulator.Emulator
+	dbg *debugger.Debugger
+	l   net.Listener
+	log *slog.Logger
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Listen starts listening for clients on a tcp address like localhost:1234. Serve must be called to accept them.
+func Listen(addr string, emu *emulator.Emulator, dbg *debugger.Debugger, log *slog.Logger) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on %s", addr)
+	}
+	return &Server{emu: emu, dbg: dbg, l: l, log: log, done: make(chan struct{})}, nil
+}
+
+// Addr returns the address being listened on
+func (s *Server) Addr() net.Addr {
+	return s.l.Addr()
+}
+
+// Serve accepts clients one after another until the server is closed
+func (s *Server) Serve() error {
+	for {
+		nc, err := s.l.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return errors.Wrap(err, "failed to accept gdb client")
+			}
+		}
+		s.log.Info("gdb client attached", "addr", nc.RemoteAddr())
+		s.ServeConn(nc)
+		s.log.Info("gdb client detached", "addr", nc.RemoteAddr())
+	}
+}
+
+// Close stops the server, telling any attached client that the program has exited
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.l.Close()
+	})
+	return err
+}
+
+// ServeConn stops the emulator and serves a single client until it detaches or disconnects, after which the emulator
+// carries on running
+func (s *Server) ServeConn(rw io.ReadWriteCloser) {
+	ss := &session{
+		Server: s,
+		points: map[point]int{},
+	}
+
+	// the client's first packets are only read once stopped, they can't be answered before then
+	ss.drain()
+	if stop, ok := s.dbg.Stopped(); ok {
+		ss.last = ss.stopReply(stop)
+	} else {
+		s.dbg.Pause()
+		select {
+		case stop := <-s.dbg.Stops():
+			ss.last = ss.stopReply(stop)
+		case <-s.done:
+			rw.Close()
+			return
+		}
+	}
+
+	ss.c = newConn(rw)
+	defer ss.close()
+
+	for !ss.quit {
+		select {
+		case data, ok := <-ss.c.packets:
+			if !ok {
+				return
+			}
+			reply, send := ss.handle(data)
+			if send {
+				if err := ss.c.writePacket(reply); err != nil {
+					return
+				}
+			}
+		case <-s.done:
+			ss.c.writePacket("W00")
+			return
+		case <-ss.c.interrupts:
+			// already stopped
+		}
+	}
+}
+
+// point is a breakpoint or watchpoint set by the client, keyed as gdb sends them
+type point struct {
+	kind byte
+	addr uint32
+	len  int
+}
+
+// session is the state of an attached client
+type session struct {
+	*Server
+	c       *conn
+	points  map[point]int // debugger ids
+	last    string        // the last stop reply, for ?
+	swbreak bool          // the client understands swbreak stop replies
+	quit    bool
+}
+
+// close removes the client's breakpoints and watchpoints, and lets the emulator run
+func (ss *session) close() {
+	for p, id := range ss.points {
+		if p.kind == '0' || p.kind == '1' {
+			ss.dbg.RemoveBreakpoint(id)
+		} else {
+			ss.dbg.RemoveWatchpoint(id)
+		}
+	}
+	ss.dbg.Continue()
+	ss.c.Close()
+}
+
+// handle runs a single packet, returning the reply and whether to send one
+func (ss *session) handle(data string) (string, bool) {
+	if data == "" {
+		return "", true
+	}
+	args := data[1:]
+
+	switch data[0] {
+	case '?':
+		return ss.last, true
+	case 'g':
+		return ss.readRegisters(), true
+	case 'G':
+		return ss.writeRegisters(args), true
+	case 'p':
+		return ss.readRegister(args), true
+	case 'P':
+		return ss.writeRegister(args), true
+	case 'm':
+		return ss.readMemory(args), true
+	case 'M':
+		return ss.writeMemory(args), true
+	case 'c':
+		return ss.resume(args, ss.dbg.Continue), true
+	case 's':
+		return ss.resume(args, func() { ss.dbg.StepInto() }), true
+	case 'Z':
+		return ss.addPoint(args), true
+	case 'z':
+		return ss.removePoint(args), true
+	case 'H', 'T':
+		// there's only the one thread
+		return "OK", true
+	case 'D':
+		ss.quit = true
+		return "OK", true
+	case 'k':
+		// there's no killing the emulator from here, the program carries on as if detached
+		ss.quit = true
+		return "", false
+	case 'q', 'Q':
+		return ss.query(data), true
+	}
+	return "", true
+}
+
+// query answers the general query packets
+func (ss *session) query(data string) string {
+	name, args, _ := strings.Cut(data, ":")
+	switch name {
+	case "qSupported":
+		ss.swbreak = strings.Contains(args, "swbreak+")
+		return fmt.Sprintf("PacketSize=%x;qXfer:features:read+;QStartNoAckMode+;swbreak+", PACKET_SIZE)
+	case "QStartNoAckMode":
+		ss.c.noAck.Store(true)
+		return "OK"
+	case "qAttached":
+		return "1"
+	case "qC":
+		return "QC1"
+	case "qfThreadInfo":
+		return "m1"
+	case "qsThreadInfo":
+		return "l"
+	case "qXfer":
+		return ss.readFeatures(args)
+	}
+	return ""
+}
+
+// readFeatures answers qXfer:features:read:target.xml:offset,length
+func (ss *session) readFeatures(args string) string {
+	rest, ok := strings.CutPrefix(args, "features:read:target.xml:")
+	if !ok {
+		return ""
+	}
+	offset, length, err := parseRange(rest)
+	if err != nil {
+		return "E01"
+	}
+	if offset >= uint64(len(targetXML)) {
+		return "l"
+	}
+	end := min(offset+length, uint64(len(targetXML)))
+	if end == uint64(len(targetXML)) {
+		return "l" + targetXML[offset:end]
+	}
+	return "m" + targetXML[offset:end]
+}
+
+func (ss *session) readRegisters() string {
+	var b strings.Builder
+	for n := range REG_COUNT {
+		encodeRegister(&b, n, readRegister(ss.emu, n))
+	}
+	return b.String()
+}
+
+func (ss *session) writeRegisters(args string) string {
+	vals := make([]uint32, REG_COUNT)
+	for n := range REG_COUNT {
+		val, rest, err := decodeRegister(args, n)
+		if err != nil {
+			return "E01"
+		}
+		vals[n], args = val, rest
+	}
+	return ss.modify(func() error {
+		for n, val := range vals {
+			if err := writeRegister(ss.emu, n, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ss *session) readRegister(args string) string {
+	n, err := strconv.ParseUint(args, 16, 8)
+	if err != nil || n >= REG_COUNT {
+		return "E01"
+	}
+	var b strings.Builder
+	encodeRegister(&b, int(n), readRegister(ss.emu, int(n)))
+	return b.String()
+}
+
+func (ss *session) writeRegister(args string) string {
+	num, value, _ := strings.Cut(args, "=")
+	n, err := strconv.ParseUint(num, 16, 8)
+	if err != nil || n >= REG_COUNT {
+		return "E01"
+	}
+	val, _, err := decodeRegister(value, int(n))
+	if err != nil {
+		return "E01"
+	}
+	return ss.modify(func() error {
+		return writeRegister(ss.emu, int(n), val)
+	})
+}
+
+// readMemory answers m addr,length, for any address in the platform's memory
+func (ss *session) readMemory(args string) string {
+	addr, length, err := parseRange(args)
+	if err != nil {
+		return "E01"
+	}
+	if !ss.inMemory(addr, length) {
+		return "E02"
+	}
+	return hex.EncodeToString(ss.emu.ReadMemory(uint32(addr), int(length)))
+}
+
+// writeMemory answers M addr,length:data
+func (ss *session) writeMemory(args string) string {
+	where, value, _ := strings.Cut(args, ":")
+	addr, length, err := parseRange(where)
+	if err != nil {
+		return "E01"
+	}
+	data, err := hex.DecodeString(value)
+	if err != nil || uint64(len(data)) != length {
+		return "E01"
+	}
+	if !ss.inMemory(addr, length) {
+		return "E02"
+	}
+	return ss.modify(func() error {
+		ss.emu.WriteMemory(uint32(addr), data)
+		return nil
+	})
+}
+
+func (ss *session) inMemory(addr, length uint64) bool {
+	return addr+length <= uint64(ss.emu.Platform().MemorySize)
+}
+
+// modify changes the emulator's state from its own goroutine, returning OK or an error reply
+func (ss *session) modify(fn func() error) string {
+	var err error
+	if merr := ss.dbg.Modify(func() { err = fn() }); merr != nil {
+		ss.log.Error("gdb failed to change emulator state", "error", merr)
+		return "E03"
+	} else if err != nil {
+		ss.log.Error("gdb failed to change emulator state", "error", err)
+		return "E01"
+	}
+	return "OK"
+}
+
+// resume handles c and s, which may give an address to resume from, waiting for the emulator to stop again
+func (ss *session) resume(args string, run func()) string {
+	if args != "" {
+		addr, err := strconv.ParseUint(args, 16, 16)
+		if err != nil {
+			return "E01"
+		}
+		if reply := ss.modify(func() error {
+			ss.emu.SetPC(uint16(addr))
+			return nil
+		}); reply != "OK" {
+			return reply
+		}
+	}
+
+	ss.drain()
+	run()
+	ss.last = ss.wait()
+	return ss.last
+}
+
+// drain throws away stops nobody waited for
+func (ss *session) drain() {
+	for {
+		select {
+		case <-ss.dbg.Stops():
+		default:
+			return
+		}
+	}
+}
+
+// wait lets the emulator run until it stops, returning the stop reply. An interrupt from the client pauses it. If the
+// program ends or the client disconnects the session ends.
+func (ss *session) wait() string {
+	for {
+		select {
+		case stop := <-ss.dbg.Stops():
+			return ss.stopReply(stop)
+		case <-ss.c.interrupts:
+			ss.dbg.Pause()
+		case data, ok := <-ss.c.packets:
+			if !ok {
+				ss.quit = true
+				return ""
+			}
+			ss.log.Debug("gdb packet ignored while running", "packet", data)
+		case <-ss.done:
+			ss.quit = true
+			return "W00"
+		}
+	}
+}
+
+// stopReply describes a stop as a signal, and for breakpoints and watchpoints what was hit
+func (ss *session) stopReply(stop debugger.Stop) string {
+	switch stop.Reason {
+	case debugger.STOP_PAUSE:
+		return "S02"
+	case debugger.STOP_BREAKPOINT:
+		if ss.swbreak {
+			return "T05swbreak:;"
+		}
+	case debugger.STOP_WATCHPOINT:
+		kind := "awatch"
+		for p, id := range ss.points {
+			if id == stop.ID && p.kind == '2' {
+				kind = "watch"
+			} else if id == stop.ID && p.kind == '3' {
+				kind = "rwatch"
+			}
+		}
+		return fmt.Sprintf("T05%s:%x;", kind, stop.Addr)
+	case debugger.STOP_FAULT:
+		if stop.Fault != nil && stop.Fault.Kind == types.FAULT_UNKNOWN_OPCODE {
+			return "S04"
+		}
+		return "S0b"
+	}
+	return "S05"
+}
+
+// addPoint answers Z type,addr,kind. Types 0 and 1 are breakpoints, 2, 3 and 4 are write, read and access watchpoints
+// with kind as the length.
+func (ss *session) addPoint(args string) string {
+	p, err := parsePoint(args)
+	if err != nil {
+		return "E01"
+	}
+	if _, ok := ss.points[p]; ok {
+		return "OK"
+	}
+
+	switch p.kind {
+	case '0', '1':
+		if p.addr > 0xFFFF {
+			return "E02"
+		}
+		ss.points[p] = ss.dbg.AddBreakpoint(uint16(p.addr), nil)
+	case '2':
+		ss.points[p] = ss.dbg.AddWatchpoint(p.addr, p.len, debugger.WATCH_WRITE)
+	case '3':
+		ss.points[p] = ss.dbg.AddWatchpoint(p.addr, p.len, debugger.WATCH_READ)
+	case '4':
+		ss.points[p] = ss.dbg.AddWatchpoint(p.addr, p.len, debugger.WATCH_ACCESS)
+	default:
+		return ""
+	}
+	return "OK"
+}
+
+// removePoint answers z type,addr,kind
+func (ss *session) removePoint(args string) string {
+	p, err := parsePoint(args)
+	if err != nil {
+		return "E01"
+	}
+	id, ok := ss.points[p]
+	if !ok {
+		return "OK"
+	}
+	delete(ss.points, p)
+	if p.kind == '0' || p.kind == '1' {
+		ss.dbg.RemoveBreakpoint(id)
+	} else {
+		ss.dbg.RemoveWatchpoint(id)
+	}
+	return "OK"
+}
+
+// parsePoint parses the type,addr,kind arguments of Z and z
+func parsePoint(args string) (point, error) {
+	kind, rest, ok := strings.Cut(args, ",")
+	if !ok || len(kind) != 1 {
+		return point{}, errors.Newf("invalid breakpoint %s", args)
+	}
+	addr, length, err := parseRange(rest)
+	if err != nil {
+		return point{}, err
+	}
+	// the kind of a breakpoint is the instruction size, which doesn't change anything here
+	if kind[0] == '0' || kind[0] == '1' {
+		length = 0
+	}
+	return point{kind: kind[0], addr: uint32(addr), len: int(length)}, nil
+}
+
+// parseRange parses hex arguments like addr,length
+func parseRange(args string) (uint64, uint64, error) {
+	a, l, ok := strings.Cut(args, ",")
+	if !ok {
+		return 0, 0, errors.Newf("invalid range %s", args)
+	}
+	addr, err := strconv.ParseUint(a, 16, 32)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "invalid address")
+	}
+	length, err := strconv.ParseUint(l, 16, 32)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "invalid length")
+	}
+	return addr, length, nil
+}
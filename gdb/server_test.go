@@ -0,0 +1,239 @@
+package gdb
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/debugger"
+	"github.com/swensone/gorito/emulator"
+	"github.com/swensone/gorito/types"
+)
+
+// testROM saves v0 to 0x300 in a subroutine, then loops forever
+var testROM = []byte{
+	0x60, 0x05, // 200: v0 := 5
+	0xA3, 0x00, // 202: i := 0x300
+	0x22, 0x08, // 204: call 208
+	0x12, 0x06, // 206: jump 206
+	0x70, 0x01, // 208: v0 += 1
+	0xF0, 0x55, // 20A: save v0
+	0x00, 0xEE, // 20C: return
+}
+
+// exchange is a packet sent to the server and the reply expected. An empty reply isn't waited for, the next exchange
+// reads it. A packet of INTERRUPT is sent as the bare byte.
+type exchange struct {
+	send  string
+	reply string
+}
+
+func TestServer(t *testing.T) {
+	tests := []struct {
+		name      string
+		exchanges []exchange
+	}{
+		{
+			"attach",
+			[]exchange{
+				{"qSupported:multiprocess+;swbreak+", fmt.Sprintf("PacketSize=%x;qXfer:features:read+;QStartNoAckMode+;swbreak+", PACKET_SIZE)},
+				{"?", "S02"},
+				{"qAttached", "1"},
+				{"Hg0", "OK"},
+				{"vMustReplyEmpty", "<empty>"},
+			},
+		},
+		{
+			"read registers",
+			[]exchange{
+				{"g", strings.Repeat("00", 16) + "00000000" + "0002" + "000000"},
+				{"p11", "0002"},
+				{"p15", "E01"},
+			},
+		},
+		{
+			"write registers",
+			[]exchange{
+				{"P0=2a", "OK"},
+				{"P10=04030000", "OK"},
+				{"P12=11", "E01"},
+				{"G" + strings.Repeat("01", 16) + "00030000" + "0202" + "000a0b", "OK"},
+				{"g", strings.Repeat("01", 16) + "00030000" + "0202" + "000a0b"},
+			},
+		},
+		{
+			"memory",
+			[]exchange{
+				{"m200,4", "6005a300"},
+				{"M300,2:abcd", "OK"},
+				{"m300,2", "abcd"},
+				{"M300,2:ab", "E01"},
+				{"mfff,2", "E02"},
+			},
+		},
+		{
+			"step",
+			[]exchange{
+				{"s", "S05"},
+				{"s", "S05"},
+				{"p11", "0402"},
+				{"p10", "00030000"},
+			},
+		},
+		{
+			"breakpoint",
+			[]exchange{
+				{"qSupported:swbreak+", fmt.Sprintf("PacketSize=%x;qXfer:features:read+;QStartNoAckMode+;swbreak+", PACKET_SIZE)},
+				{"Z0,20a,2", "OK"},
+				{"c", "T05swbreak:;"},
+				{"p11", "0a02"},
+				{"p0", "06"},
+				{"z0,20a,2", "OK"},
+				{"s", "S05"},
+				{"p11", "0c02"},
+			},
+		},
+		{
+			"continue from an address",
+			[]exchange{
+				{"Z0,20c,2", "OK"},
+				{"c208", "S05"},
+				{"p11", "0c02"},
+				{"p0", "01"},
+			},
+		},
+		{
+			"watchpoint",
+			[]exchange{
+				{"Z2,300,1", "OK"},
+				{"c", "T05watch:300;"},
+				{"m300,1", "06"},
+			},
+		},
+		{
+			"interrupt",
+			[]exchange{
+				{"c", ""},
+				{string(rune(INTERRUPT)), "S02"},
+				{"s", "S05"},
+			},
+		},
+		{
+			"no ack mode",
+			[]exchange{
+				{"QStartNoAckMode", "OK"},
+				{"m200,2", "6005"},
+			},
+		},
+		{
+			"target description",
+			[]exchange{
+				{"qXfer:features:read:target.xml:0,15", "m" + targetXML[:0x15]},
+				{fmt.Sprintf("qXfer:features:read:target.xml:%x,1000", len(targetXML)-4), "l" + targetXML[len(targetXML)-4:]},
+			},
+		},
+		{
+			"detach",
+			[]exchange{
+				{"D", "OK"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := emulator.New(emulator.EmulatorConfig{
+				Savefile: filepath.Join(t.TempDir(), "saves.json"),
+				Mode:     types.MODE_CHIP8,
+				Quirks:   types.QuirksForMode(types.MODE_CHIP8),
+				Speed:    600,
+			}, nil, nil, nil, slog.Default())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := e.LoadROM(testROM); err != nil {
+				t.Fatal(err)
+			}
+			dbg := debugger.New(e)
+			dbg.Pause()
+
+			// step the emulator on its own goroutine, as Run does
+			stop := make(chan struct{})
+			stepped := make(chan struct{})
+			go func() {
+				defer close(stepped)
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					if err := e.Step(); err != nil {
+						t.Error(err)
+						return
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}()
+			defer func() {
+				close(stop)
+				<-stepped
+			}()
+
+			client, server := net.Pipe()
+			s := &Server{emu: e, dbg: dbg, log: slog.Default(), done: make(chan struct{})}
+			served := make(chan struct{})
+			go func() {
+				defer close(served)
+				s.ServeConn(server)
+			}()
+			defer func() {
+				client.Close()
+				<-served
+			}()
+
+			r := bufio.NewReader(client)
+			noAck := false
+			for _, ex := range tt.exchanges {
+				if ex.send == string(rune(INTERRUPT)) {
+					client.Write([]byte{INTERRUPT})
+				} else {
+					fmt.Fprintf(client, "$%s#%02x", ex.send, checksum([]byte(ex.send)))
+					if !noAck {
+						ack, _ := r.ReadByte()
+						assert.Equal(t, ack, byte('+'), ex.send)
+					}
+					noAck = noAck || ex.send == "QStartNoAckMode"
+				}
+				if ex.reply == "" {
+					continue
+				}
+
+				reply := readReply(t, r)
+				if reply == "" {
+					reply = "<empty>"
+				}
+				assert.Equal(t, reply, ex.reply, ex.send)
+			}
+		})
+	}
+}
+
+// readReply reads a packet from the server, checking its checksum
+func readReply(t *testing.T, r *bufio.Reader) string {
+	if _, err := r.ReadString('$'); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := readPacket(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
@@ -9,8 +9,10 @@ require (
 
 require (
 	github.com/cockroachdb/errors v1.11.3
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/fstanis/screenresolution v0.0.0-20190527020317-869904d15333
 	github.com/magiconair/properties v1.8.9
+	golang.org/x/term v0.24.0
 )
 
 require (
@@ -18,7 +20,6 @@ require (
 	github.com/cockroachdb/redact v1.1.5 // indirect
 	github.com/ebitengine/oto/v3 v3.3.2 // indirect
 	github.com/ebitengine/purego v0.8.0 // indirect
-	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/getsentry/sentry-go v0.27.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.2.1 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
@@ -5,7 +5,6 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/veandco/go-sdl2/sdl"
 
-	"github.com/swensone/gorito/emulator"
 	"github.com/swensone/gorito/gmath"
 	"github.com/swensone/gorito/types"
 )
@@ -55,29 +54,27 @@ func New(name string, windowwidth, windowheight int32, fullscreen bool, bgColor
 
 	renderer.Present()
 
-	// determine the pixel size based on the max square that will fit in both
-	// screen directions, and center it in both directions
-	screenWidth, screenHeight := emulator.XRES, emulator.YRES
-	pixelSize := gmath.Min(w/screenWidth, h/screenHeight)
-	xoffset := (w - pixelSize*screenWidth) / 2
-	yoffset := (h - pixelSize*screenHeight) / 2
-
 	g := &Graphics{
 		window:       window,
 		renderer:     renderer,
-		screenWidth:  screenWidth,
-		screenHeight: screenHeight,
 		windowWidth:  w,
 		windowHeight: h,
 		bgColor:      bgColor,
-		pixelSize:    pixelSize,
-		xOffset:      xoffset,
-		yOffset:      yoffset,
 	}
 
 	return g, nil
 }
 
+// resize determines the pixel size based on the max square that will fit in both
+// screen directions, and centers it in both directions
+func (g *Graphics) resize(screenWidth, screenHeight int32) {
+	g.screenWidth = screenWidth
+	g.screenHeight = screenHeight
+	g.pixelSize = gmath.Min(g.windowWidth/screenWidth, g.windowHeight/screenHeight)
+	g.xOffset = (g.windowWidth - g.pixelSize*screenWidth) / 2
+	g.yOffset = (g.windowHeight - g.pixelSize*screenHeight) / 2
+}
+
 func (g *Graphics) Close() error {
 	var merr *multierror.Error
 	if err := g.window.Destroy(); err != nil {
@@ -89,7 +86,25 @@ func (g *Graphics) Close() error {
 	return merr.ErrorOrNil()
 }
 
-func (g *Graphics) Draw(gfx []types.Color) error {
+// RefreshRate returns the refresh rate of the display the window is on. Draw waits for its vertical blank.
+func (g *Graphics) RefreshRate() int {
+	idx, err := g.window.GetDisplayIndex()
+	if err != nil {
+		return 0
+	}
+	mode, err := sdl.GetCurrentDisplayMode(idx)
+	if err != nil {
+		return 0
+	}
+	return int(mode.RefreshRate)
+}
+
+func (g *Graphics) Draw(gfx []types.Color, xres, yres int32) error {
+	// the resolution changes when megachip mode is toggled
+	if xres != g.screenWidth || yres != g.screenHeight {
+		g.resize(xres, yres)
+	}
+
 	if err := g.renderer.SetDrawColor(g.bgColor.R, g.bgColor.G, g.bgColor.B, 255); err != nil {
 		return err
 	}
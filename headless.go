@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/config"
+	"github.com/swensone/gorito/emulator"
+	"github.com/swensone/gorito/screen"
+)
+
+// machineDump is the json written by --dump at the end of a headless run
+type machineDump struct {
+	Mode     string    `json:"mode"`
+	Frames   int       `json:"frames"`
+	Finished bool      `json:"finished"`
+	V        [16]uint8 `json:"v"`
+	I        uint32    `json:"i"`
+	PC       uint16    `json:"pc"`
+	SP       uint8     `json:"sp"`
+	Stack    []uint16  `json:"stack"`
+	Delay    uint8     `json:"delay"`
+	Sound    uint8     `json:"sound"`
+	Memory   string    `json:"memory"` // hex
+}
+
+// dumpHeadless writes the screen and machine state as asked for by the config
+func dumpHeadless(emu *emulator.Emulator, cfg *config.Config) error {
+	xres, yres := emu.Resolution()
+	gfx := emu.Framebuffer()
+
+	if cfg.PNG != "" {
+		if err := writeOutput(cfg.PNG, func(w io.Writer) error {
+			return screen.WritePNG(w, gfx, xres, yres, 1)
+		}); err != nil {
+			return errors.Wrap(err, "failed to write png")
+		}
+	}
+
+	if cfg.Text != "" {
+		if err := writeOutput(cfg.Text, func(w io.Writer) error {
+			return screen.WriteText(w, gfx, xres, yres, emu.ColorMap())
+		}); err != nil {
+			return errors.Wrap(err, "failed to write text")
+		}
+	}
+
+	if cfg.Dump != "" {
+		mode := emu.Mode()
+		delay, sound := emu.Timers()
+		dump := machineDump{
+			Mode:     mode.String(),
+			Frames:   emu.Frame(),
+			Finished: emu.Finished(),
+			V:        emu.Registers(),
+			I:        emu.Index(),
+			PC:       emu.PC(),
+			SP:       emu.SP(),
+			Stack:    emu.Stack(),
+			Delay:    delay,
+			Sound:    sound,
+			Memory:   hex.EncodeToString(emu.ReadMemory(0, emu.Platform().MemorySize)),
+		}
+		if err := writeOutput(cfg.Dump, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(dump)
+		}); err != nil {
+			return errors.Wrap(err, "failed to write dump")
+		}
+	}
+
+	return nil
+}
+
+// writeOutput creates a file, or uses stdout for -, and writes to it
+func writeOutput(name string, write func(w io.Writer) error) error {
+	if name == "-" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
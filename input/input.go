@@ -0,0 +1,134 @@
+package input
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+
+	"github.com/swensone/gorito/emulator"
+)
+
+/*
+CHIP-8 has an odd hex-based keypad layout:
+1	2	3	C
+4	5	6	D
+7	8	9	E
+A	0	B	F
+
+We remap the first four columns of the standard ASCII keyboard in order to support this as best as possible.
+*/
+
+var keymap = map[int]int{
+	sdl.SCANCODE_1: 0x1, // map key 1 to 1
+	sdl.SCANCODE_2: 0x2, // map key 2 to 2
+	sdl.SCANCODE_3: 0x3, // map key 3 to 3
+	sdl.SCANCODE_4: 0xC, // map key 4 to C
+	sdl.SCANCODE_Q: 0x4, // map key Q to 4
+	sdl.SCANCODE_W: 0x5, // map key W to 5
+	sdl.SCANCODE_E: 0x6, // map key E to 6
+	sdl.SCANCODE_R: 0xD, // map key R to D
+	sdl.SCANCODE_A: 0x7, // map key A to 7
+	sdl.SCANCODE_S: 0x8, // map key S to 8
+	sdl.SCANCODE_D: 0x9, // map key D to 9
+	sdl.SCANCODE_F: 0xE, // map key F to E
+	sdl.SCANCODE_Z: 0xA, // map key Z to A
+	sdl.SCANCODE_X: 0x0, // map key X to 0
+	sdl.SCANCODE_C: 0xB, // map key C to B
+	sdl.SCANCODE_V: 0xF, // map key V to F
+}
+
+// chip-8x has a second keypad, which is mapped onto the numeric keypad in the same layout
+var keymap2 = map[int]int{
+	sdl.SCANCODE_KP_7:        0x1, // map keypad 7 to 1
+	sdl.SCANCODE_KP_8:        0x2, // map keypad 8 to 2
+	sdl.SCANCODE_KP_9:        0x3, // map keypad 9 to 3
+	sdl.SCANCODE_KP_DIVIDE:   0xC, // map keypad / to C
+	sdl.SCANCODE_KP_4:        0x4, // map keypad 4 to 4
+	sdl.SCANCODE_KP_5:        0x5, // map keypad 5 to 5
+	sdl.SCANCODE_KP_6:        0x6, // map keypad 6 to 6
+	sdl.SCANCODE_KP_MULTIPLY: 0xD, // map keypad * to D
+	sdl.SCANCODE_KP_1:        0x7, // map keypad 1 to 7
+	sdl.SCANCODE_KP_2:        0x8, // map keypad 2 to 8
+	sdl.SCANCODE_KP_3:        0x9, // map keypad 3 to 9
+	sdl.SCANCODE_KP_MINUS:    0xE, // map keypad - to E
+	sdl.SCANCODE_KP_0:        0xA, // map keypad 0 to A
+	sdl.SCANCODE_KP_PERIOD:   0x0, // map keypad . to 0
+	sdl.SCANCODE_KP_ENTER:    0xB, // map keypad enter to B
+	sdl.SCANCODE_KP_PLUS:     0xF, // map keypad + to F
+}
+
+// save states use the function keys, F1-F9 for slots 1-9 and F10 for slot 0. Pressing the key loads the slot, holding
+// shift saves to it.
+var slotmap = map[sdl.Scancode]int{
+	sdl.SCANCODE_F1:  1,
+	sdl.SCANCODE_F2:  2,
+	sdl.SCANCODE_F3:  3,
+	sdl.SCANCODE_F4:  4,
+	sdl.SCANCODE_F5:  5,
+	sdl.SCANCODE_F6:  6,
+	sdl.SCANCODE_F7:  7,
+	sdl.SCANCODE_F8:  8,
+	sdl.SCANCODE_F9:  9,
+	sdl.SCANCODE_F10: 0,
+}
+
+// Input reads the chip-8 keypad and emulator hotkeys from the sdl keyboard state
+type Input struct{}
+
+func New() *Input {
+	return &Input{}
+}
+
+func (i *Input) Poll() emulator.InputState {
+	state := emulator.InputState{}
+
+	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+		switch ke := event.(type) {
+		case *sdl.KeyboardEvent:
+			if ke.Type == sdl.KEYUP && ke.Keysym.Scancode == sdl.SCANCODE_P {
+				state.Events = append(state.Events, emulator.Event{Type: emulator.EVENT_PAUSE})
+			}
+			if ke.Type == sdl.KEYUP && ke.Keysym.Scancode == sdl.SCANCODE_F12 {
+				state.Events = append(state.Events, emulator.Event{Type: emulator.EVENT_SCREENSHOT})
+			}
+			if ke.Type == sdl.KEYUP && ke.Keysym.Scancode == sdl.SCANCODE_F11 {
+				state.Events = append(state.Events, emulator.Event{Type: emulator.EVENT_RECORD})
+			}
+			if slot, ok := slotmap[ke.Keysym.Scancode]; ok && ke.Type == sdl.KEYUP {
+				if ke.Keysym.Mod&sdl.KMOD_SHIFT != 0 {
+					state.Events = append(state.Events, emulator.Event{Type: emulator.EVENT_SAVE_STATE, Arg: slot})
+				} else {
+					state.Events = append(state.Events, emulator.Event{Type: emulator.EVENT_LOAD_STATE, Arg: slot})
+				}
+			}
+		case *sdl.QuitEvent:
+			state.Events = append(state.Events, emulator.Event{Type: emulator.EVENT_QUIT})
+		}
+	}
+
+	keyState := sdl.GetKeyboardState()
+
+	for key, mapped := range keymap {
+		state.Keys[mapped] = keyState[key] == 1
+	}
+	for key, mapped := range keymap2 {
+		state.Keys2[mapped] = keyState[key] == 1
+	}
+
+	// rewind for as long as backspace is held
+	if keyState[sdl.SCANCODE_BACKSPACE] == 1 {
+		state.Events = append(state.Events, emulator.Event{Type: emulator.EVENT_REWIND})
+	}
+
+	// fast forward while tab is held, and slow down while backquote is
+	if keyState[sdl.SCANCODE_TAB] == 1 {
+		state.Events = append(state.Events, emulator.Event{Type: emulator.EVENT_TURBO})
+	}
+	if keyState[sdl.SCANCODE_GRAVE] == 1 {
+		state.Events = append(state.Events, emulator.Event{Type: emulator.EVENT_SLOW_MOTION})
+	}
+
+	if keyState[sdl.SCANCODE_ESCAPE] == 1 {
+		state.Events = append(state.Events, emulator.Event{Type: emulator.EVENT_QUIT})
+	}
+
+	return state
+}
@@ -5,83 +5,252 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/cockroachdb/errors"
 	"github.com/veandco/go-sdl2/sdl"
 
+	"github.com/swensone/gorito/api"
 	"github.com/swensone/gorito/audio"
 	"github.com/swensone/gorito/config"
+	"github.com/swensone/gorito/debugger"
 	"github.com/swensone/gorito/emulator"
+	"github.com/swensone/gorito/gdb"
 	"github.com/swensone/gorito/graphics"
+	"github.com/swensone/gorito/input"
 	"github.com/swensone/gorito/types"
 )
 
+// subcommands run instead of the emulator when named as the first argument, e.g. gorito disasm game.ch8
+var commands = map[string]func(args []string) int{
+	"disasm": disasmCommand,
+	"asm":    asmCommand,
+	"debug":  debugCommand,
+	"run":    runCommand,
+	"test":   testCommand,
+}
+
 func main() {
-	cfg, err := config.Parse()
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+	os.Exit(runCommand(os.Args[1:]))
+}
+
+// runCommand runs a rom, in a window or headless: gorito [run] [flags] [rom]
+func runCommand(args []string) int {
+	cfg, err := config.Parse(args)
 	if err != nil {
 		slog.Default().Error("error parsing config", slog.Any("error", err))
-		os.Exit(1)
+		return 1
 	}
 
 	log := getLogger(cfg.Level)
 	log.Debug("configuration", "cfg", cfg)
 
-	log.Debug("initializing sdl")
-	if err := initSDL(); err != nil {
-		slog.Error("failed to init sdl", slog.Any("error", err))
-		os.Exit(1)
+	cfg.Mode = modeForROM(cfg.ROM, cfg.Mode)
+	quirks := cfg.Quirks.Apply(types.QuirksForMode(cfg.Mode))
+
+	// instructions per frame is another way of giving the speed
+	if cfg.IPF > 0 {
+		cfg.Speed = cfg.IPF * emulator.FRAME_RATE
 	}
-	defer sdl.Quit()
 
-	// if the extension matches a mode, use it
-	romext := filepath.Ext(cfg.ROM)
-	if romext == ".xo8" {
-		cfg.Mode = types.MODE_XOCHIP
-	} else if romext == ".sc8" {
-		cfg.Mode = types.MODE_SUPERCHIP
+	// pick a seed for the random numbers unless one is given, a movie uses the one it was recorded with
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
+	// a movie plays back on the machine it was recorded on
+	if cfg.MoviePlay != "" {
+		header, err := emulator.ReadMovieHeader(cfg.MoviePlay)
+		if err != nil {
+			slog.Error("failed to read movie", slog.Any("error", err))
+			return 1
+		}
+		cfg.Mode, quirks, cfg.Speed, cfg.Seed, cfg.RNG = header.Mode, header.Quirks, header.Speed, header.Seed, header.RNG
 	}
 
-	// create a title for the display window
-	screenName := fmt.Sprintf("gorito - mode %s - %s", cfg.Mode.String(), emulator.RomName(cfg.ROM))
+	// log the seed so that runs can be repeated
+	log.Info("random number seed", "seed", cfg.Seed)
+	rng, err := newRNG(cfg.RNG, cfg.VIP)
+	if err != nil {
+		log.Error("failed to create random number generator", "error", err)
+		return 1
+	}
 
-	// create our graphics service
-	log.Debug("initializing graphics")
 	colorMap := map[uint8]types.Color{
 		0: cfg.BG,
 		1: cfg.FG1,
 		2: cfg.FG2,
 		3: cfg.FG3,
 	}
-	display, err := graphics.New(screenName, cfg.Width, cfg.Height, cfg.Fullscreen, cfg.BG)
-	if err != nil {
-		log.Error("failed to create graphics renderer", slog.Any("error", err))
+
+	// headless runs have no display, audio or keyboard, so sdl isn't needed
+	var display emulator.Display
+	var sound emulator.Audio
+	var keys emulator.Input
+	if !cfg.Headless {
+		log.Debug("initializing sdl")
+		if err := initSDL(); err != nil {
+			slog.Error("failed to init sdl", slog.Any("error", err))
+			return 1
+		}
+		defer sdl.Quit()
+
+		// create a title for the display window
+		screenName := fmt.Sprintf("gorito - mode %s - %s", cfg.Mode.String(), emulator.RomName(cfg.ROM))
+
+		// create our graphics service
+		log.Debug("initializing graphics")
+		g, err := graphics.New(screenName, cfg.Width, cfg.Height, cfg.Fullscreen, cfg.BG)
+		if err != nil {
+			log.Error("failed to create graphics renderer", slog.Any("error", err))
+			return 1
+		}
+		defer g.Close()
+		display = g
+
+		// create our audio service
+		log.Debug("initializing audio")
+		a := audio.New(20)
+		defer a.Close()
+		sound = a
+
+		keys = input.New()
 	}
-	defer display.Close()
 
-	// create our audio service
-	log.Debug("initializing audio")
-	audio := audio.New(20)
-	defer audio.Close()
+	// keys pressed through the api are merged with the keyboard's
+	var apiInput *api.Input
+	if cfg.API != "" {
+		apiInput = api.NewInput(keys)
+		keys = apiInput
+	}
 
 	emu, err := emulator.New(
 		emulator.EmulatorConfig{
-			Savefile:   cfg.Savefile,
-			Mode:       cfg.Mode,
-			Speed:      cfg.Speed,
-			ColorMap:   colorMap,
-			LogOpcodes: cfg.Opcodes,
+			Savefile:        cfg.Savefile,
+			Mode:            cfg.Mode,
+			Quirks:          quirks,
+			Speed:           cfg.Speed,
+			ColorMap:        colorMap,
+			LogOpcodes:      cfg.Opcodes,
+			RewindFrames:    int(cfg.Rewind),
+			Faults:          cfg.Faults,
+			MovieRecord:     cfg.MovieRecord,
+			MoviePlay:       cfg.MoviePlay,
+			RNG:             rng,
+			Seed:            cfg.Seed,
+			VSync:           cfg.VSync,
+			Turbo:           cfg.Turbo,
+			SlowMotion:      cfg.SlowMotion,
+			Unthrottled:     cfg.Headless,
+			Frames:          int(cfg.Frames),
+			ScreenshotDir:   cfg.Screenshots,
+			ScreenshotScale: cfg.ScreenshotScale,
+			Record:          cfg.Record,
+			RecordFormat:    cfg.RecordFormat,
+			WAV:             cfg.WAV,
 		},
 		display,
-		audio,
+		sound,
+		keys,
 		log,
 	)
 	if err != nil {
 		log.Error("failure while creating cpu emulator", "error", err)
-		os.Exit(1)
+		return 1
+	}
+
+	// hold the rom on its first instruction until gdb attaches and continues
+	if cfg.GDB != "" {
+		dbg := debugger.New(emu)
+		dbg.Pause()
+		server, err := gdb.Listen(cfg.GDB, emu, dbg, log)
+		if err != nil {
+			log.Error("failed to start gdb server", "error", err)
+			return 1
+		}
+		defer server.Close()
+		log.Info("waiting for gdb", "addr", server.Addr())
+		go func() {
+			if err := server.Serve(); err != nil {
+				log.Error("gdb server stopped", "error", err)
+			}
+		}()
+	}
+
+	if cfg.API != "" {
+		server, err := api.Listen(cfg.API, emu, apiInput, log)
+		if err != nil {
+			log.Error("failed to start api server", "error", err)
+			return 1
+		}
+		defer server.Close()
+		log.Info("serving api", "addr", server.Addr())
+		go func() {
+			if err := server.Serve(); err != nil {
+				log.Error("api server stopped", "error", err)
+			}
+		}()
 	}
 
+	status := 0
 	if err := emu.Run(cfg.ROM); err != nil {
 		log.Error("error returned from cpu run", "error", err)
+		status = 1
 	}
+
+	// dump what the program left behind, even if it failed, so that ci can show what went wrong
+	if cfg.Headless {
+		log.Info("headless run finished", "frames", emu.Frame())
+		if err := dumpHeadless(emu, cfg); err != nil {
+			log.Error("failed to write headless output", "error", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// modeForROM returns the mode matching the rom's extension, or mode if the extension doesn't name one
+func modeForROM(rom string, mode types.Mode) types.Mode {
+	romext := filepath.Ext(rom)
+	if romext == ".xo8" {
+		return types.MODE_XOCHIP
+	} else if romext == ".sc8" {
+		return types.MODE_SUPERCHIP
+	} else if romext == ".mc8" {
+		return types.MODE_MEGACHIP
+	} else if romext == ".c8x" {
+		return types.MODE_CHIP8X
+	}
+	return mode
+}
+
+// newRNG creates the named random number generator, the vip generator reads from an image of the vip interpreter
+func newRNG(name, vip string) (emulator.RNG, error) {
+	switch name {
+	case "pcg":
+		return emulator.NewPCG(), nil
+	case "vip":
+		if vip == "" {
+			return nil, errors.New("the vip generator needs an image of the vip interpreter, see --vip")
+		}
+		interpreter, err := os.ReadFile(vip)
+		if err != nil {
+			return nil, err
+		}
+		return emulator.NewVIP(interpreter)
+	}
+	return nil, errors.Newf("unknown random number generator %s", name)
+}
+
+// replaceExt swaps the extension of a file name
+func replaceExt(name, ext string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ext
 }
 
 func initSDL() error {
@@ -95,7 +264,7 @@ func initSDL() error {
 }
 
 func getLogger(level slog.Level) *slog.Logger {
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		AddSource: true,
 		Level:     level,
 	})
@@ -0,0 +1,172 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/types"
+)
+
+var (
+	bg = types.Color{R: 0x08, G: 0x08, B: 0x08}
+	fg = types.Color{R: 0x1e, G: 0x81, B: 0xb0}
+)
+
+// record writes 12 frames of a 4x2 screen. The first 6 frames are blank with the sound playing, then a pixel moves
+// along the top row for 3 frames and stays put for the rest.
+func record(t *testing.T, fpath string, scale int) {
+	r, err := New(fpath, scale, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 12 {
+		gfx := []types.Color{bg, bg, bg, bg, bg, bg, bg, bg}
+		if i >= 6 {
+			gfx[min(i-6, 2)] = fg
+		}
+		if i < 6 {
+			r.Sound().Play()
+		} else {
+			r.Sound().Stop()
+		}
+		if err := r.Frame(gfx, 4, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	tests := []struct {
+		name  string
+		ext   string
+		scale int
+		check func(t *testing.T, data []byte)
+	}{
+		{
+			"gif",
+			".gif",
+			2,
+			func(t *testing.T, data []byte) {
+				anim, err := gif.DecodeAll(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				// the second moving frame is dropped for being too short
+				assert.Equal(t, len(anim.Image), 3)
+				assert.Equal(t, anim.Delay, []int{10, 2, 8})
+				assert.Equal(t, anim.Config.Width, 8)
+				assert.Equal(t, len(anim.Image[1].Palette), 2)
+				assert.Equal(t, anim.LoopCount, 0)
+				assert.Equal(t, color.RGBAModel.Convert(anim.Image[0].At(0, 0)), color.Color(color.RGBA{R: bg.R, G: bg.G, B: bg.B, A: 0xFF}))
+				assert.Equal(t, color.RGBAModel.Convert(anim.Image[2].At(4, 0)), color.Color(color.RGBA{R: fg.R, G: fg.G, B: fg.B, A: 0xFF}))
+			},
+		},
+		{
+			"apng",
+			".png",
+			1,
+			func(t *testing.T, data []byte) {
+				// players without apng support show the first frame
+				img, err := png.Decode(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, img.Bounds().Dx(), 4)
+
+				chunks, err := pngChunks(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var kinds []string
+				var delays []uint16
+				for _, c := range chunks {
+					kinds = append(kinds, c.kind)
+					if c.kind == "fcTL" {
+						delays = append(delays, binary.BigEndian.Uint16(c.data[20:]))
+					}
+				}
+				assert.Equal(t, kinds, []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"})
+				assert.Equal(t, binary.BigEndian.Uint32(chunks[1].data), uint32(4))
+				assert.Equal(t, delays, []uint16{6, 1, 1, 4})
+			},
+		},
+		{
+			"y4m",
+			".y4m",
+			1,
+			func(t *testing.T, data []byte) {
+				header := "YUV4MPEG2 W4 H2 F60:1 Ip A1:1 C444\n"
+				assert.Equal(t, string(data[:len(header)]), header)
+				assert.Equal(t, len(data), len(header)+12*(len("FRAME\n")+3*8))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fpath := filepath.Join(dir, "clip"+tt.ext)
+			record(t, fpath, tt.scale)
+
+			data, err := os.ReadFile(fpath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, data)
+
+			// the sound plays for the first half
+			wav, err := os.ReadFile(filepath.Join(dir, "clip.wav"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, string(wav[:4]), "RIFF")
+			assert.Equal(t, binary.LittleEndian.Uint32(wav[4:]), uint32(len(wav)-8))
+			assert.Equal(t, binary.LittleEndian.Uint32(wav[40:]), uint32(12*SAMPLES_PER_FRAME*2))
+			samples := wav[WAV_HEADER_SIZE:]
+			assert.Equal(t, int16(binary.LittleEndian.Uint16(samples)), int16(math.Round(-math.MaxInt16*BEEP_LEVEL)))
+			assert.Equal(t, samples[len(samples)/2:], make([]byte, len(samples)/2))
+		})
+	}
+}
+
+func TestNewUnknownFormat(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "clip.mp4"), 1, nil)
+	assert.Matches(t, fmt.Sprint(err), "extension should be")
+}
+
+func TestGIFManyColors(t *testing.T) {
+	// more colors than a gif palette holds fall back to a fixed palette
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(i), uint8(i>>8), 0x80, 0xFF
+	}
+
+	var buf bytes.Buffer
+	g := &gifEncoder{w: &buf}
+	if err := g.frame(img); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.close(); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(anim.Image), 1)
+	assert.Equal(t, len(anim.Image[0].Palette), 256)
+}
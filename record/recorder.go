@@ -0,0 +1,113 @@
+package record
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/swensone/gorito/types"
+)
+
+// formats by file extension
+var formats = map[string]string{
+	".gif":  "gif",
+	".png":  "apng",
+	".apng": "apng",
+	".y4m":  "y4m",
+}
+
+// Extension returns the file extension for a format, gif, apng or y4m
+func Extension(format string) (string, error) {
+	for ext, f := range formats {
+		if f == format && ext != ".apng" {
+			return ext, nil
+		}
+	}
+	return "", errors.Errorf("unknown recording format %s, expected gif, apng or y4m", format)
+}
+
+// Recorder records the screen to a video file and the sound to a wav file next to it. Frames are scaled up by a whole
+// number, and any that change resolution part way through are resized to match the first.
+type Recorder struct {
+	file  *os.File
+	video videoEncoder
+	sound *Sound
+	scale int
+
+	width, height int
+}
+
+// New starts recording to fpath, in the format for its extension. audio is passed on to by the Sound the emulator
+// should be given while recording.
+func New(fpath string, scale int, audio Audio) (*Recorder, error) {
+	format, ok := formats[strings.ToLower(filepath.Ext(fpath))]
+	if !ok {
+		return nil, errors.Errorf("unable to record to %s, the extension should be .gif, .png, .apng or .y4m", fpath)
+	}
+
+	file, err := os.Create(fpath)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{file: file, scale: max(scale, 1)}
+	switch format {
+	case "gif":
+		r.video = &gifEncoder{w: file}
+	case "apng":
+		r.video = &apngEncoder{w: file}
+	case "y4m":
+		r.video = &y4mEncoder{w: file}
+	}
+
+	if r.sound, err = CreateSound(strings.TrimSuffix(fpath, filepath.Ext(fpath))+".wav", audio); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Sound returns the audio to give the emulator while recording
+func (r *Recorder) Sound() *Sound {
+	return r.sound
+}
+
+// Frame records the screen, xres*yres colors row by row, and the sound for a frame
+func (r *Recorder) Frame(gfx []types.Color, xres, yres int32) error {
+	if r.width == 0 {
+		r.width, r.height = int(xres)*r.scale, int(yres)*r.scale
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, r.width, r.height))
+	for y := range r.height {
+		row := y * int(yres) / r.height * int(xres)
+		for x := range r.width {
+			c := gfx[row+x*int(xres)/r.width]
+			i := img.PixOffset(x, y)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, 0xFF
+		}
+	}
+
+	if err := r.video.frame(img); err != nil {
+		return errors.Wrap(err, "failed to record video")
+	}
+	return errors.Wrap(r.sound.Frame(), "failed to record sound")
+}
+
+// Close finishes the recording
+func (r *Recorder) Close() error {
+	var merr *multierror.Error
+	if err := r.video.close(); err != nil {
+		merr = multierror.Append(merr, err)
+	}
+	if err := r.file.Close(); err != nil {
+		merr = multierror.Append(merr, err)
+	}
+	if err := r.sound.Close(); err != nil {
+		merr = multierror.Append(merr, err)
+	}
+	return merr.ErrorOrNil()
+}
@@ -0,0 +1,140 @@
+package record
+
+import (
+	"io"
+	"math"
+	"os"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/swensone/gorito/synth"
+)
+
+const (
+	// SAMPLES_PER_FRAME is the audio for one 60hz frame
+	SAMPLES_PER_FRAME = SAMPLE_RATE / 60
+	// BEEP_LEVEL is the level of the beeper in the recording, samples are recorded at full scale
+	BEEP_LEVEL = 0.25
+)
+
+// Audio is the emulator's audio interface, see emulator.Audio
+type Audio interface {
+	Play()
+	Stop()
+	LoadPattern(pattern [16]uint8)
+	SetPitch(pitch uint8)
+	PlaySample(rate int, samples []uint8, loop bool)
+	StopSample()
+}
+
+// Sound records what the emulator plays to a wav file while passing it on to another Audio. The emulator runs faster
+// or slower than real time, so the sound is rendered a frame at a time from the state at the end of each frame rather
+// than as it's played. It's generated by the same synth as the speaker.
+type Sound struct {
+	inner Audio
+	wav   *wavWriter
+	file  *os.File // closed with the sound when it was created by CreateSound
+	synth *synth.Synth
+	buf   []float64
+	out   []int16
+}
+
+// NewSound records to w, passing everything on to inner, which may be nil
+func NewSound(inner Audio, w io.WriteSeeker) (*Sound, error) {
+	wav, err := newWAVWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	return &Sound{
+		inner: inner,
+		wav:   wav,
+		synth: synth.New(SAMPLE_RATE, BEEP_LEVEL),
+		buf:   make([]float64, SAMPLES_PER_FRAME),
+		out:   make([]int16, SAMPLES_PER_FRAME),
+	}, nil
+}
+
+// CreateSound records to a new wav file at fpath, passing everything on to inner, which may be nil
+func CreateSound(fpath string, inner Audio) (*Sound, error) {
+	f, err := os.Create(fpath)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewSound(inner, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.file = f
+	return s, nil
+}
+
+// Inner returns the Audio sound is passed on to
+func (s *Sound) Inner() Audio {
+	return s.inner
+}
+
+func (s *Sound) Play() {
+	s.synth.Play()
+	if s.inner != nil {
+		s.inner.Play()
+	}
+}
+
+func (s *Sound) Stop() {
+	s.synth.Stop()
+	if s.inner != nil {
+		s.inner.Stop()
+	}
+}
+
+func (s *Sound) LoadPattern(pattern [16]uint8) {
+	s.synth.LoadPattern(pattern)
+	if s.inner != nil {
+		s.inner.LoadPattern(pattern)
+	}
+}
+
+func (s *Sound) SetPitch(pitch uint8) {
+	s.synth.SetPitch(pitch)
+	if s.inner != nil {
+		s.inner.SetPitch(pitch)
+	}
+}
+
+func (s *Sound) PlaySample(rate int, samples []uint8, loop bool) {
+	s.synth.PlaySample(rate, samples, loop)
+	if s.inner != nil {
+		s.inner.PlaySample(rate, samples, loop)
+	}
+}
+
+func (s *Sound) StopSample() {
+	s.synth.StopSample()
+	if s.inner != nil {
+		s.inner.StopSample()
+	}
+}
+
+// Frame renders a frame of sound
+func (s *Sound) Frame() error {
+	s.synth.Render(s.buf)
+	for i, v := range s.buf {
+		s.out[i] = int16(math.Round(v * math.MaxInt16))
+	}
+	return s.wav.write(s.out)
+}
+
+// Close finishes the wav file
+func (s *Sound) Close() error {
+	var merr *multierror.Error
+	if err := s.wav.close(); err != nil {
+		merr = multierror.Append(merr, err)
+	}
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+	return merr.ErrorOrNil()
+}
@@ -0,0 +1,354 @@
+package record
+
+import (
+	"bytes"
+	"compress/lzw"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"github.com/cockroachdb/errors"
+)
+
+// FRAME_RATE is the rate frames are given to the encoders at
+const FRAME_RATE = 60
+
+// videoEncoder writes frames, which are all the same size, given at FRAME_RATE
+type videoEncoder interface {
+	frame(img *image.RGBA) error
+	close() error
+}
+
+// held tracks the frame being shown and for how long, so that encoders which support frame delays can write a
+// frame once for as long as it's unchanged
+type held struct {
+	img    *image.RGBA
+	start  int // frame it was first shown
+	frames int // frames seen in total
+}
+
+// same returns true if img is the frame being held, counting it if so
+func (h *held) same(img *image.RGBA) bool {
+	if h.img != nil && bytes.Equal(h.img.Pix, img.Pix) {
+		h.frames++
+		return true
+	}
+	return false
+}
+
+// hold starts holding img
+func (h *held) hold(img *image.RGBA) {
+	h.img = img
+	h.start = h.frames
+	h.frames++
+}
+
+// gifEncoder streams an animated gif, writing each frame once the next one replaces it and its delay is known. Each
+// frame has its own exact palette unless it has more than 256 colors.
+type gifEncoder struct {
+	w       io.Writer
+	started bool
+	held
+}
+
+// centiseconds returns the time of a frame in gif delay units
+func centiseconds(frame int) int {
+	return (frame*100 + FRAME_RATE/2) / FRAME_RATE
+}
+
+func (g *gifEncoder) frame(img *image.RGBA) error {
+	if g.same(img) {
+		return nil
+	}
+	if g.img != nil {
+		// players slow down frames shorter than 2/100s, so frames that change faster than that are dropped
+		if centiseconds(g.frames)-centiseconds(g.start) < 2 {
+			g.img = img
+			g.frames++
+			return nil
+		}
+		if err := g.emit(); err != nil {
+			return err
+		}
+	}
+	g.hold(img)
+	return nil
+}
+
+// emit writes the held frame, after the file header if it's the first
+func (g *gifEncoder) emit() error {
+	var buf bytes.Buffer
+	b := g.img.Bounds()
+	if !g.started {
+		// logical screen without a global color table, and the netscape extension to loop forever
+		buf.WriteString("GIF89a")
+		binary.Write(&buf, binary.LittleEndian, [2]uint16{uint16(b.Dx()), uint16(b.Dy())})
+		buf.Write([]byte{0x00, 0x00, 0x00})
+		buf.Write([]byte{0x21, 0xFF, 0x0B})
+		buf.WriteString("NETSCAPE2.0")
+		buf.Write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+		g.started = true
+	}
+
+	p := paletted(g.img)
+	bits := 1
+	for 1<<bits < len(p.Palette) {
+		bits++
+	}
+
+	// graphic control extension with the delay, then the image descriptor and its local color table
+	delay := centiseconds(g.frames) - centiseconds(g.start)
+	buf.Write([]byte{0x21, 0xF9, 0x04, 0x00, byte(delay), byte(delay >> 8), 0x00, 0x00})
+	buf.WriteByte(0x2C)
+	binary.Write(&buf, binary.LittleEndian, [4]uint16{0, 0, uint16(b.Dx()), uint16(b.Dy())})
+	buf.WriteByte(0x80 | byte(bits-1))
+	for i := range 1 << bits {
+		var r, gr, bl uint32
+		if i < len(p.Palette) {
+			r, gr, bl, _ = p.Palette[i].RGBA()
+		}
+		buf.Write([]byte{byte(r >> 8), byte(gr >> 8), byte(bl >> 8)})
+	}
+
+	// lzw compressed pixels, in sub-blocks of up to 255 bytes
+	litWidth := max(bits, 2)
+	buf.WriteByte(byte(litWidth))
+	var data bytes.Buffer
+	lw := lzw.NewWriter(&data, lzw.LSB, litWidth)
+	if _, err := lw.Write(p.Pix); err != nil {
+		return err
+	}
+	if err := lw.Close(); err != nil {
+		return err
+	}
+	for data.Len() > 0 {
+		block := data.Next(255)
+		buf.WriteByte(byte(len(block)))
+		buf.Write(block)
+	}
+	buf.WriteByte(0x00)
+
+	_, err := g.w.Write(buf.Bytes())
+	return err
+}
+
+func (g *gifEncoder) close() error {
+	if g.img == nil {
+		return errors.New("no frames were recorded")
+	}
+	if err := g.emit(); err != nil {
+		return err
+	}
+	_, err := g.w.Write([]byte{0x3B})
+	return err
+}
+
+// paletted converts img to a paletted image, with the exact colors when there are few enough of them
+func paletted(img *image.RGBA) *image.Paletted {
+	var colors color.Palette
+	seen := map[color.RGBA]bool{}
+	for i := 0; i < len(img.Pix) && len(colors) <= 256; i += 4 {
+		c := color.RGBA{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2], A: img.Pix[i+3]}
+		if !seen[c] {
+			seen[c] = true
+			colors = append(colors, c)
+		}
+	}
+	if len(colors) > 256 {
+		colors = palette.Plan9
+	}
+
+	p := image.NewPaletted(img.Bounds(), colors)
+	draw.Draw(p, p.Bounds(), img, image.Point{}, draw.Src)
+	return p
+}
+
+// apngEncoder streams an animated png. Each frame is encoded as a png and its image data moved into the animation's
+// chunks. The frame count in the animation control chunk is filled in by close.
+type apngEncoder struct {
+	w        io.WriteSeeker
+	sequence uint32
+	count    uint32
+	held
+}
+
+const (
+	PNG_SIGNATURE = "\x89PNG\r\n\x1a\n"
+	// ACTL_OFFSET is where the animation control chunk is written, after the signature and the header chunk
+	ACTL_OFFSET = 8 + 12 + 13
+)
+
+func (a *apngEncoder) frame(img *image.RGBA) error {
+	if a.same(img) {
+		return nil
+	}
+	if a.img != nil {
+		if err := a.emit(); err != nil {
+			return err
+		}
+	}
+	a.hold(img)
+	return nil
+}
+
+func (a *apngEncoder) emit() error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, a.img); err != nil {
+		return err
+	}
+	chunks, err := pngChunks(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if a.count == 0 {
+		if _, err := io.WriteString(a.w, PNG_SIGNATURE); err != nil {
+			return err
+		}
+		if err := writeChunk(a.w, "IHDR", chunks[0].data); err != nil {
+			return err
+		}
+		if err := a.actl(); err != nil {
+			return err
+		}
+	}
+
+	// frame control: sequence, size, offset, delay and the dispose and blend ops, which are both none/source
+	b := a.img.Bounds()
+	fctl := binary.BigEndian.AppendUint32(nil, a.next())
+	fctl = binary.BigEndian.AppendUint32(fctl, uint32(b.Dx()))
+	fctl = binary.BigEndian.AppendUint32(fctl, uint32(b.Dy()))
+	fctl = binary.BigEndian.AppendUint64(fctl, 0)
+	fctl = binary.BigEndian.AppendUint16(fctl, uint16(a.frames-a.start))
+	fctl = binary.BigEndian.AppendUint16(fctl, FRAME_RATE)
+	fctl = append(fctl, 0, 0)
+	if err := writeChunk(a.w, "fcTL", fctl); err != nil {
+		return err
+	}
+
+	// the first frame is the default image and keeps its data chunks, the rest are numbered
+	for _, c := range chunks {
+		if c.kind != "IDAT" {
+			continue
+		}
+		if a.count == 0 {
+			err = writeChunk(a.w, "IDAT", c.data)
+		} else {
+			err = writeChunk(a.w, "fdAT", append(binary.BigEndian.AppendUint32(nil, a.next()), c.data...))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	a.count++
+	return nil
+}
+
+func (a *apngEncoder) next() uint32 {
+	a.sequence++
+	return a.sequence - 1
+}
+
+// actl writes the animation control chunk with the frames so far, looping forever
+func (a *apngEncoder) actl() error {
+	return writeChunk(a.w, "acTL", binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, a.count), 0))
+}
+
+func (a *apngEncoder) close() error {
+	if a.img == nil {
+		return errors.New("no frames were recorded")
+	}
+	if err := a.emit(); err != nil {
+		return err
+	}
+	if err := writeChunk(a.w, "IEND", nil); err != nil {
+		return err
+	}
+	if _, err := a.w.Seek(ACTL_OFFSET, io.SeekStart); err != nil {
+		return err
+	}
+	if err := a.actl(); err != nil {
+		return err
+	}
+	_, err := a.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+// pngChunks splits an encoded png into its chunks
+func pngChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, []byte(PNG_SIGNATURE)) {
+		return nil, errors.New("not a png")
+	}
+	var chunks []pngChunk
+	for data = data[len(PNG_SIGNATURE):]; len(data) >= 12; {
+		n := int(binary.BigEndian.Uint32(data))
+		if len(data) < 12+n {
+			return nil, errors.New("truncated png chunk")
+		}
+		chunks = append(chunks, pngChunk{kind: string(data[4:8]), data: data[8 : 8+n]})
+		data = data[12+n:]
+	}
+	if len(chunks) == 0 || chunks[0].kind != "IHDR" {
+		return nil, errors.New("png doesn't start with a header")
+	}
+	return chunks, nil
+}
+
+func writeChunk(w io.Writer, kind string, data []byte) error {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	buf = append(buf, kind...)
+	buf = append(buf, data...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf[4:]))
+	_, err := w.Write(buf)
+	return err
+}
+
+// y4mEncoder writes uncompressed 4:4:4 video for piping into other tools. Every frame is written, the format has no
+// delays.
+type y4mEncoder struct {
+	w      io.Writer
+	header bool
+	buf    []byte
+}
+
+func (y *y4mEncoder) frame(img *image.RGBA) error {
+	b := img.Bounds()
+	if !y.header {
+		if _, err := fmt.Fprintf(y.w, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C444\n", b.Dx(), b.Dy(), FRAME_RATE); err != nil {
+			return err
+		}
+		y.header = true
+	}
+
+	n := b.Dx() * b.Dy()
+	if len(y.buf) != 3*n {
+		y.buf = make([]byte, 3*n)
+	}
+	for i := range n {
+		yy, cb, cr := color.RGBToYCbCr(img.Pix[4*i], img.Pix[4*i+1], img.Pix[4*i+2])
+		y.buf[i], y.buf[n+i], y.buf[2*n+i] = yy, cb, cr
+	}
+	if _, err := io.WriteString(y.w, "FRAME\n"); err != nil {
+		return err
+	}
+	_, err := y.w.Write(y.buf)
+	return err
+}
+
+func (y *y4mEncoder) close() error {
+	if !y.header {
+		return errors.New("no frames were recorded")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package record
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// SAMPLE_RATE matches the speaker's
+	SAMPLE_RATE = 48000
+	// WAV_HEADER_SIZE is the size of the riff header, the fmt chunk and the data chunk's header
+	WAV_HEADER_SIZE = 44
+)
+
+// wavWriter writes 16 bit mono pcm. The sizes in the header aren't known until the end, so they're filled in by
+// close.
+type wavWriter struct {
+	w       io.WriteSeeker
+	samples int
+}
+
+func newWAVWriter(w io.WriteSeeker) (*wavWriter, error) {
+	ww := &wavWriter{w: w}
+	if err := ww.header(); err != nil {
+		return nil, errors.Wrap(err, "failed to write wav header")
+	}
+	return ww, nil
+}
+
+func (ww *wavWriter) header() error {
+	size := uint32(ww.samples * 2)
+	h := make([]byte, 0, WAV_HEADER_SIZE)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, WAV_HEADER_SIZE-8+size)
+	h = append(h, "WAVEfmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 16)            // fmt chunk size
+	h = binary.LittleEndian.AppendUint16(h, 1)             // pcm
+	h = binary.LittleEndian.AppendUint16(h, 1)             // mono
+	h = binary.LittleEndian.AppendUint32(h, SAMPLE_RATE)   // sample rate
+	h = binary.LittleEndian.AppendUint32(h, SAMPLE_RATE*2) // bytes per second
+	h = binary.LittleEndian.AppendUint16(h, 2)             // bytes per sample
+	h = binary.LittleEndian.AppendUint16(h, 16)            // bits per sample
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, size)
+	_, err := ww.w.Write(h)
+	return err
+}
+
+func (ww *wavWriter) write(samples []int16) error {
+	buf := make([]byte, 0, len(samples)*2)
+	for _, s := range samples {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(s))
+	}
+	if _, err := ww.w.Write(buf); err != nil {
+		return err
+	}
+	ww.samples += len(samples)
+	return nil
+}
+
+// close fills in the header, leaving the writer at the end
+func (ww *wavWriter) close() error {
+	if _, err := ww.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := ww.header(); err != nil {
+		return err
+	}
+	_, err := ww.w.Seek(0, io.SeekEnd)
+	return err
+}
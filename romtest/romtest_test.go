@@ -0,0 +1,133 @@
+package romtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/types"
+)
+
+func TestRun(t *testing.T) {
+	key := uint8(5)
+	chip8 := types.Mode(types.MODE_CHIP8)
+	press := []KeyEvent{{Frame: 3, Press: &key}, {Frame: 5, Release: &key}}
+
+	tests := []struct {
+		name     string
+		spec     Spec
+		failures []string
+		err      string
+	}{
+		{
+			"passes",
+			Spec{
+				ROM:    "keypress.8o",
+				Mode:   &chip8,
+				Frames: 10,
+				Input:  press,
+				Expect: Expect{
+					Registers: map[string]uint32{"v1": 5, "I": 0x20E},
+					Memory:    map[string]string{"0x20C": "0005"},
+					Screen:    "keypress.png",
+				},
+			},
+			nil,
+			"",
+		},
+		{
+			"registers and memory differ",
+			Spec{
+				ROM:    "keypress.8o",
+				Mode:   &chip8,
+				Frames: 10,
+				Expect: Expect{
+					Registers: map[string]uint32{"v1": 5, "vx": 0},
+					Memory:    map[string]string{"0x20C": "0005", "0x10000": "00"},
+				},
+			},
+			[]string{
+				"register v1: got 0x0, want 0x5",
+				"unknown register vx",
+				"memory at 0x10000 is out of range",
+				"memory at 0x20C: got 0000, want 0005",
+			},
+			"",
+		},
+		{
+			"screen differs",
+			Spec{
+				ROM:    "keypress.8o",
+				Mode:   &chip8,
+				Frames: 10,
+				Expect: Expect{Screen: "keypress.png"},
+			},
+			[]string{
+				"screen at 0,0: got 000000, want FFFFFF",
+				"screen at 1,0: got 000000, want FFFFFF",
+				"screen at 2,0: got 000000, want FFFFFF",
+				"screen at 3,0: got 000000, want FFFFFF",
+				"screen at 4,0: got 000000, want FFFFFF",
+				"screen: 51 more pixels differ",
+				"screen written to actual.png",
+			},
+			"",
+		},
+		{
+			"missing rom",
+			Spec{ROM: "missing.ch8", Frames: 10},
+			nil,
+			"no such file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// copy the testdata so that failing screens are written out of the tree
+			dir := t.TempDir()
+			for _, name := range []string{"keypress.8o", "keypress.png"} {
+				data, err := os.ReadFile(filepath.Join("testdata", name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tt.spec.Dir = dir
+
+			r := Run(&tt.spec, Options{})
+			if tt.err != "" {
+				assert.Matches(t, r.Err.Error(), tt.err)
+				return
+			}
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+			for i, f := range r.Failures {
+				r.Failures[i] = strings.ReplaceAll(f, filepath.Join(dir, "keypress."), "")
+			}
+			assert.Equal(t, r.Failures, tt.failures)
+			assert.Equal(t, r.Passed(), len(tt.failures) == 0)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	s, err := Load(filepath.Join("testdata", "keypress.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Name, "keypress")
+	assert.Equal(t, s.Mode.String(), "chip-8")
+	assert.Equal(t, len(s.Input), 2)
+	assert.Equal(t, *s.Input[0].Press, uint8(5))
+
+	r := Run(s, Options{})
+	assert.Equal(t, r.Err, nil)
+	assert.Equal(t, r.Failures, []string(nil))
+	assert.Equal(t, r.Frames, 10)
+}
@@ -0,0 +1,273 @@
+package romtest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/swensone/gorito/emulator"
+	"github.com/swensone/gorito/screen"
+	"github.com/swensone/gorito/types"
+)
+
+// MAX_PIXEL_DIFFS is the number of differing pixels listed when the screen doesn't match
+const MAX_PIXEL_DIFFS = 5
+
+// colors the screen is drawn in, so that golden images don't depend on anyone's config
+var colors = map[uint8]types.Color{
+	0: {R: 0x00, G: 0x00, B: 0x00},
+	1: {R: 0xFF, G: 0xFF, B: 0xFF},
+	2: {R: 0xAA, G: 0xAA, B: 0xAA},
+	3: {R: 0x55, G: 0x55, B: 0x55},
+}
+
+// Result is the outcome of running a spec. A spec that couldn't be run has Err set, one that ran but didn't match has
+// a list of Failures.
+type Result struct {
+	Name     string
+	Frames   int
+	Failures []string
+	Err      error
+}
+
+// Passed returns true if the spec ran and everything matched
+func (r *Result) Passed() bool {
+	return r.Err == nil && len(r.Failures) == 0
+}
+
+// Options change how specs are run
+type Options struct {
+	// Update writes the screen to the golden image instead of comparing with it
+	Update bool
+	// Log gets the emulator's log, which is discarded when nil
+	Log *slog.Logger
+}
+
+// Run runs a spec and checks the result
+func Run(s *Spec, opts Options) *Result {
+	r := &Result{Name: s.Name}
+	emu, err := s.run(opts)
+	if emu != nil {
+		r.Frames = emu.Frame()
+	}
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	r.Failures = append(r.Failures, s.checkRegisters(emu)...)
+	r.Failures = append(r.Failures, s.checkMemory(emu)...)
+	failures, err := s.checkScreen(emu, opts.Update)
+	r.Failures = append(r.Failures, failures...)
+	r.Err = err
+	return r
+}
+
+// run creates an emulator for the spec and runs it, returning it even if the run fails part way through
+func (s *Spec) run(opts Options) (*emulator.Emulator, error) {
+	mode := types.Mode(types.MODE_SUPERCHIP)
+	if s.Mode != nil {
+		mode = *s.Mode
+	}
+	speed := s.Speed
+	if speed == 0 {
+		speed = 600
+	}
+	log := opts.Log
+	if log == nil {
+		log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	// keep the persistent flags away from the user's saves
+	dir, err := os.MkdirTemp("", "romtest")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	emu, err := emulator.New(emulator.EmulatorConfig{
+		Savefile: filepath.Join(dir, "saves.json"),
+		Mode:     mode,
+		Quirks:   s.Quirks.Apply(types.QuirksForMode(mode)),
+		Speed:    speed,
+		ColorMap: colors,
+		Seed:     s.Seed,
+	}, nil, nil, nil, log)
+	if err != nil {
+		return nil, err
+	}
+	if err := emu.LoadProgram(s.path(s.ROM)); err != nil {
+		return nil, err
+	}
+
+	for emu.Frame() < s.Frames && !emu.Finished() {
+		for _, ev := range s.Input {
+			if ev.Frame != emu.Frame() {
+				continue
+			}
+			if ev.Press != nil {
+				emu.SetKey(*ev.Press, true)
+			}
+			if ev.Release != nil {
+				emu.SetKey(*ev.Release, false)
+			}
+		}
+		if err := emu.RunFrame(); err != nil {
+			return emu, errors.Wrapf(err, "failed at frame %d", emu.Frame())
+		}
+	}
+	return emu, nil
+}
+
+func (s *Spec) checkRegisters(emu *emulator.Emulator) []string {
+	v := emu.Registers()
+	delay, sound := emu.Timers()
+	regs := map[string]uint32{
+		"i":  emu.Index(),
+		"pc": uint32(emu.PC()),
+		"sp": uint32(emu.SP()),
+		"dt": uint32(delay),
+		"st": uint32(sound),
+	}
+	for x, val := range v {
+		regs[fmt.Sprintf("v%x", x)] = uint32(val)
+	}
+
+	var failures []string
+	for _, name := range sortedKeys(s.Expect.Registers) {
+		want := s.Expect.Registers[name]
+		got, ok := regs[strings.ToLower(name)]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("unknown register %s", name))
+			continue
+		}
+		if got != want {
+			failures = append(failures, fmt.Sprintf("register %s: got 0x%X, want 0x%X", name, got, want))
+		}
+	}
+	return failures
+}
+
+func (s *Spec) checkMemory(emu *emulator.Emulator) []string {
+	var failures []string
+	for _, key := range sortedKeys(s.Expect.Memory) {
+		addr, err := strconv.ParseUint(key, 0, 32)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid memory address %s", key))
+			continue
+		}
+		want, err := hex.DecodeString(s.Expect.Memory[key])
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid bytes for memory at 0x%03X", addr))
+			continue
+		}
+		if int(addr)+len(want) > emu.Platform().MemorySize {
+			failures = append(failures, fmt.Sprintf("memory at 0x%03X is out of range", addr))
+			continue
+		}
+
+		got := emu.ReadMemory(uint32(addr), len(want))
+		if !bytes.Equal(got, want) {
+			failures = append(failures, fmt.Sprintf("memory at 0x%03X: got %X, want %X", addr, got, want))
+		}
+	}
+	return failures
+}
+
+// checkScreen compares the screen with the golden image, or writes the golden image when updating. When they don't
+// match the screen is written next to the golden image with an .actual.png extension.
+func (s *Spec) checkScreen(emu *emulator.Emulator, update bool) ([]string, error) {
+	if s.Expect.Screen == "" {
+		return nil, nil
+	}
+	golden := s.path(s.Expect.Screen)
+	xres, yres := emu.Resolution()
+	img := screen.Image(emu.Framebuffer(), xres, yres, 1)
+
+	if update {
+		return nil, writePNG(golden, img)
+	}
+
+	f, err := os.Open(golden)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open golden screen, run with update to create it")
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read golden screen %s", golden)
+	}
+
+	failures := diffImages(img, want)
+	if len(failures) > 0 {
+		actual := strings.TrimSuffix(golden, filepath.Ext(golden)) + ".actual.png"
+		if err := writePNG(actual, img); err != nil {
+			return failures, err
+		}
+		failures = append(failures, fmt.Sprintf("screen written to %s", actual))
+	}
+	return failures, nil
+}
+
+// diffImages lists the differences between the screen and the golden image
+func diffImages(got *image.RGBA, want image.Image) []string {
+	if got.Bounds().Size() != want.Bounds().Size() {
+		return []string{fmt.Sprintf("screen: got %dx%d, want %dx%d", got.Bounds().Dx(), got.Bounds().Dy(),
+			want.Bounds().Dx(), want.Bounds().Dy())}
+	}
+
+	var failures []string
+	count := 0
+	wb := want.Bounds()
+	for y := range got.Bounds().Dy() {
+		for x := range got.Bounds().Dx() {
+			g := got.RGBAAt(x, y)
+			wr, wg, wbl, _ := want.At(wb.Min.X+x, wb.Min.Y+y).RGBA()
+			w := [3]uint8{uint8(wr >> 8), uint8(wg >> 8), uint8(wbl >> 8)}
+			if [3]uint8{g.R, g.G, g.B} == w {
+				continue
+			}
+			count++
+			if count <= MAX_PIXEL_DIFFS {
+				failures = append(failures, fmt.Sprintf("screen at %d,%d: got %02X%02X%02X, want %02X%02X%02X", x, y,
+					g.R, g.G, g.B, w[0], w[1], w[2]))
+			}
+		}
+	}
+	if count > MAX_PIXEL_DIFFS {
+		failures = append(failures, fmt.Sprintf("screen: %d more pixels differ", count-MAX_PIXEL_DIFFS))
+	}
+	return failures
+}
+
+func writePNG(fpath string, img image.Image) error {
+	f, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
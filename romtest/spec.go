@@ -0,0 +1,94 @@
+package romtest
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	kjson "github.com/knadh/koanf/parsers/json"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+
+	"github.com/swensone/gorito/types"
+)
+
+// Spec is a regression test for a rom: it's run with scripted input for a number of frames, and then the machine is
+// checked against what's expected. Specs are usually written as yaml, see Load, but can be built in go for table
+// driven tests. Paths are relative to Dir.
+//
+//	rom: pong.ch8
+//	mode: chip-8
+//	quirks:
+//	  vf_reset: true
+//	frames: 120
+//	input:
+//	  - frame: 10
+//	    press: 0x5
+//	  - frame: 20
+//	    release: 0x5
+//	expect:
+//	  registers:
+//	    v0: 0x10
+//	    pc: 0x210
+//	  memory:
+//	    0x300: "0a0b0c"
+//	  screen: pong.png
+type Spec struct {
+	Name   string               `json:"name"`
+	ROM    string               `json:"rom"`
+	Mode   *types.Mode          `json:"mode"` // superchip when not set
+	Quirks types.QuirkOverrides `json:"quirks"`
+	Speed  uint32               `json:"speed"` // instructions per second, 600 when not set
+	Seed   int64                `json:"seed"`
+	Frames int                  `json:"frames"` // stops early if the program exits
+	Input  []KeyEvent           `json:"input"`
+	Expect Expect               `json:"expect"`
+	Dir    string               `json:"-"`
+}
+
+// KeyEvent presses or releases a key at the start of a frame
+type KeyEvent struct {
+	Frame   int    `json:"frame"`
+	Press   *uint8 `json:"press"`
+	Release *uint8 `json:"release"`
+}
+
+// Expect is the state of the machine once the spec has run. Registers are named v0-vf, i, pc, sp, dt and st, and
+// memory is a map of addresses to the hex bytes expected there.
+type Expect struct {
+	Registers map[string]uint32 `json:"registers"`
+	Memory    map[string]string `json:"memory"`
+	Screen    string            `json:"screen"` // golden png
+}
+
+// Load reads a yaml spec, named after its file unless it has a name
+func Load(fpath string) (*Spec, error) {
+	k := koanf.New(".")
+	if err := k.Load(file.Provider(fpath), yaml.Parser()); err != nil {
+		return nil, errors.Wrapf(err, "unable to read spec %s", fpath)
+	}
+	data, err := k.Marshal(kjson.Parser())
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Spec{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, errors.Wrapf(err, "invalid spec %s", fpath)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(fpath), filepath.Ext(fpath))
+	}
+	s.Dir = filepath.Dir(fpath)
+	return s, nil
+}
+
+// path resolves a path in the spec
+func (s *Spec) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(s.Dir, p)
+}
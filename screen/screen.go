@@ -0,0 +1,59 @@
+package screen
+
+import (
+	"bufio"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/swensone/gorito/types"
+)
+
+// Image converts a framebuffer of xres*yres colors, row by row, into an image with each pixel scaled up to a
+// scale*scale block
+func Image(gfx []types.Color, xres, yres int32, scale int) *image.RGBA {
+	scale = max(scale, 1)
+	w, h := int(xres), int(yres)
+	img := image.NewRGBA(image.Rect(0, 0, w*scale, h*scale))
+	for y := range h * scale {
+		for x := range w * scale {
+			c := gfx[(y/scale)*w+x/scale]
+			img.SetRGBA(x, y, color.RGBA{R: c.R, G: c.G, B: c.B, A: 0xFF})
+		}
+	}
+	return img
+}
+
+// WritePNG writes the framebuffer as a png, see Image
+func WritePNG(w io.Writer, gfx []types.Color, xres, yres int32, scale int) error {
+	return png.Encode(w, Image(gfx, xres, yres, scale))
+}
+
+// TEXT_DIGITS are written for colors 1 up, chip-8x uses colors past F
+const TEXT_DIGITS = ".123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// WriteText writes the framebuffer as one line of text per row. Each pixel is written as the digit of its index in
+// colors, with . for color 0 and ? for colors that aren't in the map, such as megachip's.
+func WriteText(w io.Writer, gfx []types.Color, xres, yres int32, colors map[uint8]types.Color) error {
+	index := make(map[types.Color]byte, len(colors))
+	for i, c := range colors {
+		// the lowest index wins if colors share a value, so the background stays the background
+		if j, ok := index[c]; !ok || i < j {
+			index[c] = i
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for y := range int(yres) {
+		for x := range int(xres) {
+			if i, ok := index[gfx[y*int(xres)+x]]; ok && int(i) < len(TEXT_DIGITS) {
+				bw.WriteByte(TEXT_DIGITS[i])
+			} else {
+				bw.WriteByte('?')
+			}
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
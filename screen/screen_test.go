@@ -0,0 +1,76 @@
+package screen
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/types"
+)
+
+var (
+	bg  = types.Color{R: 0x08, G: 0x08, B: 0x08}
+	fg1 = types.Color{R: 0x1e, G: 0x81, B: 0xb0}
+	fg2 = types.Color{R: 0xea, G: 0xb6, B: 0x76}
+)
+
+func TestWriteText(t *testing.T) {
+	tests := []struct {
+		name   string
+		gfx    []types.Color
+		colors map[uint8]types.Color
+		text   string
+	}{
+		{
+			"background and foreground",
+			[]types.Color{bg, fg1, fg1, bg, bg, fg2},
+			map[uint8]types.Color{0: bg, 1: fg1, 2: fg2},
+			".11\n..2\n",
+		},
+		{
+			"unknown colors",
+			[]types.Color{bg, fg1, fg2, bg, bg, bg},
+			map[uint8]types.Color{0: bg, 1: fg1},
+			".1?\n...\n",
+		},
+		{
+			"shared colors use the lowest index",
+			[]types.Color{bg, fg1, bg, bg, bg, bg},
+			map[uint8]types.Color{0: bg, 1: fg1, 17: bg},
+			".1.\n...\n",
+		},
+		{
+			"colors past F",
+			[]types.Color{bg, fg1, bg, bg, bg, bg},
+			map[uint8]types.Color{0: bg, 16: fg1},
+			".G.\n...\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteText(&buf, tt.gfx, 3, 2, tt.colors); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, buf.String(), tt.text)
+		})
+	}
+}
+
+func TestImage(t *testing.T) {
+	gfx := []types.Color{bg, fg1, fg2, bg}
+	for _, scale := range []int{0, 1, 3} {
+		img := Image(gfx, 2, 2, scale)
+		size := max(scale, 1) * 2
+		assert.Equal(t, img.Bounds().Dx(), size)
+		assert.Equal(t, img.Bounds().Dy(), size)
+
+		// the bottom right pixel of each block has the block's color
+		for i, c := range gfx {
+			x, y := (i%2+1)*size/2-1, (i/2+1)*size/2-1
+			assert.Equal(t, img.RGBAAt(x, y).R, c.R)
+		}
+	}
+}
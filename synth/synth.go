@@ -0,0 +1,106 @@
+package synth
+
+import "math"
+
+const (
+	// PATTERN_BITS is the length of an xo-chip audio pattern
+	PATTERN_BITS = 128
+	// DEFAULT_PITCH plays the default pattern, a square wave, at about 440hz
+	DEFAULT_PITCH = 247
+)
+
+// DEFAULT_PATTERN is a square wave, half off and half on
+var DEFAULT_PATTERN = [16]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// Synth generates the emulator's sound, the beeper and megachip's samples, for the speaker and for recordings. The
+// beeper plays a 1 bit pattern at a pitch as on xo-chip, every other mode is given a square wave pattern by the
+// emulator. It isn't safe for concurrent use.
+type Synth struct {
+	rate  float64 // output samples per second
+	level float64 // amplitude of the beeper, samples always play at full scale
+
+	playing bool
+	pattern [16]uint8
+	bitrate float64 // pattern bits per second
+	bit     float64 // position in the pattern
+
+	sample     []uint8
+	sampleStep float64 // sample position per output sample
+	samplePos  float64
+	loop       bool
+}
+
+// New creates a synth generating rate samples per second with the beeper at level, up to 1, playing the default pattern
+func New(rate int, level float64) *Synth {
+	s := &Synth{rate: float64(rate), level: level, pattern: DEFAULT_PATTERN}
+	s.SetPitch(DEFAULT_PITCH)
+	return s
+}
+
+// Play starts the beeper
+func (s *Synth) Play() {
+	s.playing = true
+}
+
+// Stop stops the beeper
+func (s *Synth) Stop() {
+	s.playing = false
+}
+
+// LoadPattern sets the 128 bit pattern the beeper plays, most significant bit first
+func (s *Synth) LoadPattern(pattern [16]uint8) {
+	s.pattern = pattern
+}
+
+// SetPitch sets the rate the pattern is played at, 4000*2^((pitch-64)/48) bits per second
+func (s *Synth) SetPitch(pitch uint8) {
+	s.bitrate = 4000 * math.Exp2((float64(pitch)-64)/48)
+}
+
+// PlaySample plays 8 bit unsigned samples recorded at rate, replacing any sample already playing
+func (s *Synth) PlaySample(rate int, samples []uint8, loop bool) {
+	s.StopSample()
+	if rate == 0 || len(samples) == 0 {
+		return
+	}
+	s.sample = samples
+	s.sampleStep = float64(rate) / s.rate
+	s.loop = loop
+}
+
+// StopSample stops the sample started by PlaySample
+func (s *Synth) StopSample() {
+	s.sample = nil
+	s.samplePos = 0
+}
+
+// Render fills buf with the next samples, from -1 to 1
+func (s *Synth) Render(buf []float64) {
+	step := s.bitrate / s.rate
+	for i := range buf {
+		var v float64
+		if s.playing {
+			bit := int(s.bit)
+			if s.pattern[bit/8]&(0x80>>(bit%8)) != 0 {
+				v = s.level
+			} else {
+				v = -s.level
+			}
+			s.bit = math.Mod(s.bit+step, PATTERN_BITS)
+		}
+
+		if s.sample != nil {
+			if int(s.samplePos) >= len(s.sample) && s.loop {
+				s.samplePos = 0
+			}
+			if int(s.samplePos) < len(s.sample) {
+				v += (float64(s.sample[int(s.samplePos)]) - 128) / 128
+				s.samplePos += s.sampleStep
+			} else {
+				s.StopSample()
+			}
+		}
+
+		buf[i] = max(min(v, 1), -1)
+	}
+}
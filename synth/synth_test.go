@@ -0,0 +1,74 @@
+package synth
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestRender(t *testing.T) {
+	hi := 127.0 / 128
+	tests := []struct {
+		name  string
+		setup func(s *Synth)
+		out   []float64
+	}{
+		{
+			"stopped",
+			func(s *Synth) {},
+			[]float64{0, 0, 0, 0},
+		},
+		{
+			"pattern at pitch 64",
+			func(s *Synth) {
+				s.LoadPattern([16]uint8{0xa0})
+				s.SetPitch(64)
+				s.Play()
+			},
+			[]float64{1, 1, -1, -1, 1, 1, -1, -1, -1, -1},
+		},
+		{
+			"pattern an octave up",
+			func(s *Synth) {
+				s.LoadPattern([16]uint8{0xa0})
+				s.SetPitch(112)
+				s.Play()
+			},
+			[]float64{1, -1, 1, -1, -1},
+		},
+		{
+			"sample",
+			func(s *Synth) {
+				s.PlaySample(4000, []uint8{255, 0, 128}, false)
+			},
+			[]float64{hi, hi, -1, -1, 0, 0, 0, 0},
+		},
+		{
+			"looped sample",
+			func(s *Synth) {
+				s.PlaySample(8000, []uint8{255, 0}, true)
+			},
+			[]float64{hi, -1, hi, -1, hi},
+		},
+		{
+			"sample mixed with the beeper",
+			func(s *Synth) {
+				s.LoadPattern([16]uint8{0xff})
+				s.SetPitch(112)
+				s.Play()
+				s.PlaySample(8000, []uint8{255, 0}, true)
+			},
+			[]float64{1, 0, 1, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(8000, 1)
+			tt.setup(s)
+			out := make([]float64, len(tt.out))
+			s.Render(out)
+			assert.Equal(t, out, tt.out)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package tui
+
+import (
+	"sync"
+
+	"github.com/swensone/gorito/types"
+)
+
+// Display keeps a copy of the latest frame for the terminal ui to render
+type Display struct {
+	mu   sync.Mutex
+	gfx  []types.Color
+	xres int32
+	yres int32
+}
+
+func NewDisplay() *Display {
+	return &Display{}
+}
+
+func (d *Display) Draw(gfx []types.Color, xres, yres int32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.gfx = append(d.gfx[:0], gfx...)
+	d.xres, d.yres = xres, yres
+	return nil
+}
+
+// Frame returns a copy of the latest frame and its resolution
+func (d *Display) Frame() ([]types.Color, int32, int32) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]types.Color{}, d.gfx...), d.xres, d.yres
+}
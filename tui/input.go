@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"sync"
+	"time"
+
+	"github.com/swensone/gorito/emulator"
+)
+
+// KEY_HOLD is how long a keypad key stays down after a key press, terminals only report presses and key repeats
+const KEY_HOLD = 200 * time.Millisecond
+
+// keymap maps the first four columns of the keyboard onto the hex keypad, like the sdl input
+var keymap = map[rune]int{
+	'1': 0x1, '2': 0x2, '3': 0x3, '4': 0xC,
+	'q': 0x4, 'w': 0x5, 'e': 0x6, 'r': 0xD,
+	'a': 0x7, 's': 0x8, 'd': 0x9, 'f': 0xE,
+	'z': 0xA, 'x': 0x0, 'c': 0xB, 'v': 0xF,
+}
+
+// Input feeds key presses from the terminal to the emulator
+type Input struct {
+	mu   sync.Mutex
+	held [16]time.Time
+	quit bool
+}
+
+func NewInput() *Input {
+	return &Input{}
+}
+
+// press holds the keypad key mapped to r, returning false if r isn't mapped
+func (i *Input) press(r rune) bool {
+	k, ok := keymap[r]
+	if !ok {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.held[k] = time.Now().Add(KEY_HOLD)
+	return true
+}
+
+// Quit asks the emulator to stop at its next poll
+func (i *Input) Quit() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.quit = true
+}
+
+func (i *Input) Poll() emulator.InputState {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var state emulator.InputState
+	now := time.Now()
+	for k, until := range i.held {
+		state.Keys[k] = now.Before(until)
+	}
+	if i.quit {
+		state.Events = append(state.Events, emulator.Event{Type: emulator.EVENT_QUIT})
+	}
+	return state
+}
@@ -0,0 +1,231 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/swensone/gorito/disasm"
+	"github.com/swensone/gorito/types"
+)
+
+const (
+	REVERSE = "\x1b[7m"
+	BOLD    = "\x1b[1m"
+	RESET   = "\x1b[0m"
+)
+
+// width returns the number of columns s takes up, ignoring escape sequences
+func width(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		if s[i] == 0x1b {
+			i = skipEscape(s, i)
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return n
+}
+
+// skipEscape returns the index after the escape sequence starting at i
+func skipEscape(s string, i int) int {
+	i++
+	if i < len(s) && s[i] == '[' {
+		i++
+		for i < len(s) && (s[i] < 0x40 || s[i] > 0x7E) {
+			i++
+		}
+	}
+	return min(i+1, len(s))
+}
+
+// truncate cuts s to w columns, keeping escape sequences intact
+func truncate(s string, w int) string {
+	if width(s) <= w {
+		return s
+	}
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(s) && n < w; {
+		if s[i] == 0x1b {
+			next := skipEscape(s, i)
+			b.WriteString(s[i:next])
+			i = next
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteString(s[i : i+size])
+		i += size
+		n++
+	}
+	b.WriteString(RESET)
+	return b.String()
+}
+
+// pad cuts or pads s to exactly w columns
+func pad(s string, w int) string {
+	s = truncate(s, w)
+	return s + strings.Repeat(" ", w-width(s))
+}
+
+// columns lays panes out side by side, each padded to its width. A width of 0 leaves the pane as it is.
+func columns(widths []int, panes ...[]string) []string {
+	rows := 0
+	for _, p := range panes {
+		rows = max(rows, len(p))
+	}
+	lines := make([]string, rows)
+	for row := range lines {
+		var b strings.Builder
+		for i, p := range panes {
+			line := ""
+			if row < len(p) {
+				line = p[row]
+			}
+			if widths[i] > 0 {
+				line = pad(line, widths[i])
+			}
+			b.WriteString(line)
+		}
+		lines[row] = strings.TrimRight(b.String(), " ")
+	}
+	return lines
+}
+
+// braille dot bits for each pixel of a 2x4 cell, indexed by [y][x]
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// renderFramebuffer draws the frame with braille characters, each covering 2x4 pixels. Pixels that aren't the
+// background color are set. Frames wider than 128 pixels, from megachip, are scaled down to fit.
+func renderFramebuffer(gfx []types.Color, xres, yres int32, bg types.Color) []string {
+	if len(gfx) == 0 {
+		return nil
+	}
+	step := max(int(xres)/128, 1)
+	cols := int(xres) / step / 2
+	rows := (int(yres)/step + 3) / 4
+
+	lines := make([]string, rows)
+	for row := range rows {
+		var b strings.Builder
+		for col := range cols {
+			cell := rune(0x2800)
+			for y := range 4 {
+				for x := range 2 {
+					px, py := (col*2+x)*step, (row*4+y)*step
+					if py < int(yres) && gfx[py*int(xres)+px] != bg {
+						cell |= brailleDots[y][x]
+					}
+				}
+			}
+			b.WriteRune(cell)
+		}
+		lines[row] = b.String()
+	}
+	return lines
+}
+
+// disassemblyWindow returns the first address and the number of bytes of memory needed to disassemble lines
+// instructions around pc
+func disassemblyWindow(pc uint16, lines int) (uint32, int) {
+	return uint32(max(int(pc)-2*(lines/4), 0)), lines*4 + 4
+}
+
+// renderDisassembly lists instructions around pc, marking pc with > and breakpoints with *, and highlighting the
+// cursor. mem holds the memory from disassemblyWindow.
+func renderDisassembly(d *disasm.Disassembler, mem []byte, pc, cursor uint16, breakpoints map[uint16]bool, lines int) []string {
+	// instructions before pc are assumed to be 2 bytes, which may be wrong around 4 byte instructions, but pc itself
+	// is always disassembled from the right place
+	start, _ := disassemblyWindow(pc, lines)
+	addr := int(start)
+	var out []string
+	for len(out) < lines && addr+1-int(start) < len(mem) {
+		if addr < int(pc) && addr+2 > int(pc) {
+			addr = int(pc)
+		}
+		raw, text, length := d.Instruction(mem, addr-int(start))
+
+		marker := " "
+		if addr == int(pc) {
+			marker = ">"
+		}
+		bp := " "
+		if breakpoints[uint16(addr)] {
+			bp = "*"
+		}
+		line := fmt.Sprintf("%s%s %04X  %-9s  %s", marker, bp, addr, raw, text)
+		if addr == int(cursor) {
+			line = REVERSE + line + RESET
+		}
+		out = append(out, line)
+
+		if addr < int(pc) {
+			length = 2
+		}
+		addr += length
+	}
+	return out
+}
+
+// renderRegisters shows V0-VF, I, PC, SP, the timers and the stack, which holds up to depth return addresses
+func renderRegisters(regs [16]uint8, idx uint32, pc uint16, sp uint8, stack []uint16, depth int, dt, st uint8) []string {
+	var lines []string
+	for row := range 4 {
+		var cells []string
+		for col := range 4 {
+			r := row*4 + col
+			cells = append(cells, fmt.Sprintf("V%X %02X", r, regs[r]))
+		}
+		lines = append(lines, strings.Join(cells, "  "))
+	}
+	lines = append(lines,
+		fmt.Sprintf("I  %04X  PC %04X  SP %d/%d", idx, pc, sp, depth),
+		fmt.Sprintf("DT %02X    ST %02X", dt, st),
+	)
+
+	entries := make([]string, depth)
+	for i := range entries {
+		entries[i] = "----"
+		if i < len(stack) {
+			entries[i] = fmt.Sprintf("%04X", stack[i])
+		}
+	}
+	for i := 0; i < depth; i += 4 {
+		prefix := "      "
+		if i == 0 {
+			prefix = "stack "
+		}
+		lines = append(lines, prefix+strings.Join(entries[i:min(i+4, depth)], " "))
+	}
+	return lines
+}
+
+// renderMemory shows mem as rows of 16 bytes starting at addr, with the printable characters alongside
+func renderMemory(mem []byte, addr uint32) []string {
+	var lines []string
+	for row := 0; row < len(mem); row += 16 {
+		data := mem[row:min(row+16, len(mem))]
+		var hex, text strings.Builder
+		for i, b := range data {
+			if i == 8 {
+				hex.WriteByte(' ')
+			}
+			fmt.Fprintf(&hex, "%02X ", b)
+			if b >= 0x20 && b < 0x7F {
+				text.WriteByte(b)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%04X  %-49s %s", addr+uint32(row), hex.String(), text.String()))
+	}
+	return lines
+}
@@ -0,0 +1,72 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/swensone/gorito/disasm"
+	"github.com/swensone/gorito/types"
+)
+
+func TestRenderFramebuffer(t *testing.T) {
+	bg := types.Color{}
+	fg := types.Color{R: 1}
+
+	// a 128x64 frame with the top left pixel, a 2x4 block at the right and the bottom right pixel set
+	gfx := make([]types.Color, 128*64)
+	gfx[0] = fg
+	for y := range 4 {
+		gfx[y*128+126] = fg
+		gfx[y*128+127] = fg
+	}
+	gfx[63*128+127] = fg
+
+	lines := renderFramebuffer(gfx, 128, 64, bg)
+	assert.Equal(t, len(lines), 16)
+	assert.Equal(t, []rune(lines[0])[0], rune(0x2801))
+	assert.Equal(t, []rune(lines[0])[63], rune(0x28FF))
+	assert.Equal(t, []rune(lines[15])[63], rune(0x2880))
+	assert.Equal(t, []rune(lines[8])[8], rune(0x2800))
+}
+
+func TestRenderDisassembly(t *testing.T) {
+	// 200: v0 := 1, 202: i := long 0x0300, 206: jump 0x206
+	mem := []byte{0x60, 0x01, 0xF0, 0x00, 0x03, 0x00, 0x12, 0x06}
+	lines := renderDisassembly(disasm.New(types.MODE_XOCHIP), mem, 0x202, 0x206, map[uint16]bool{0x200: true}, 4)
+
+	assert.Equal(t, lines, []string{
+		" * 0200  6001       v0 := 0x01",
+		">  0202  F000 0300  i := long 0x300",
+		REVERSE + "   0206  1206       jump 0x206" + RESET,
+	})
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []key
+	}{
+		{"runes", "sn", []key{'s', 'n'}},
+		{"arrows", "\x1b[A\x1bOB", []key{keyUp, keyDown}},
+		{"pages", "\x1b[5~\x1b[6~", []key{keyPageUp, keyPageDown}},
+		{"escape", "\x1b", []key{keyEscape}},
+		{"unknown sequence dropped", "\x1b[1;5Cq", []key{'q'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, parseKeys([]byte(tt.data)), tt.want)
+		})
+	}
+}
+
+func TestColumns(t *testing.T) {
+	lines := columns([]int{6, 0}, []string{"ab", REVERSE + "abcdefgh" + RESET}, []string{"x", "y", "z"})
+	assert.Equal(t, lines, []string{
+		"ab    x",
+		REVERSE + "abcdef" + RESET + "y",
+		"      z",
+	})
+}
@@ -0,0 +1,132 @@
+package tui
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/term"
+)
+
+// keys other than runes are negative
+type key rune
+
+const (
+	keyUp key = -(iota + 1)
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyEscape
+)
+
+// escape sequences for the special keys
+var sequences = map[string]key{
+	"\x1b[A":  keyUp,
+	"\x1bOA":  keyUp,
+	"\x1b[B":  keyDown,
+	"\x1bOB":  keyDown,
+	"\x1b[5~": keyPageUp,
+	"\x1b[6~": keyPageDown,
+}
+
+// terminal puts the terminal into raw mode on the alternate screen
+type terminal struct {
+	in    int
+	out   *bufio.Writer
+	state *term.State
+}
+
+func openTerminal() (*terminal, error) {
+	in := int(os.Stdin.Fd())
+	if !term.IsTerminal(in) {
+		return nil, errors.New("the debugger needs to run in a terminal")
+	}
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to put the terminal into raw mode")
+	}
+
+	t := &terminal{in: in, out: bufio.NewWriter(os.Stdout), state: state}
+	// switch to the alternate screen and hide the cursor
+	t.out.WriteString("\x1b[?1049h\x1b[?25l")
+	return t, t.out.Flush()
+}
+
+func (t *terminal) Close() error {
+	t.out.WriteString("\x1b[?25h\x1b[?1049l")
+	t.out.Flush()
+	return term.Restore(t.in, t.state)
+}
+
+// Size returns the width and height of the terminal, with a fallback if it can't be found
+func (t *terminal) Size() (int, int) {
+	w, h, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 80, 24
+	}
+	return w, h
+}
+
+// Draw replaces the screen contents with lines, cut to the terminal size
+func (t *terminal) Draw(lines []string) error {
+	w, h := t.Size()
+	t.out.WriteString("\x1b[H")
+	for i, line := range lines[:min(len(lines), h)] {
+		if i > 0 {
+			t.out.WriteString("\r\n")
+		}
+		t.out.WriteString(truncate(line, w))
+		t.out.WriteString("\x1b[K")
+	}
+	t.out.WriteString("\x1b[J")
+	return t.out.Flush()
+}
+
+// readKeys reads key presses from r until it fails
+func readKeys(r io.Reader, keys chan<- key) {
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+// parseKeys splits a read from the terminal into keys. An escape on its own is the escape key, unknown escape
+// sequences are dropped.
+func parseKeys(data []byte) []key {
+	var keys []key
+	for len(data) > 0 {
+		if data[0] != 0x1b {
+			r, size := utf8.DecodeRune(data)
+			keys = append(keys, key(r))
+			data = data[size:]
+			continue
+		}
+
+		if len(data) == 1 {
+			keys = append(keys, keyEscape)
+			break
+		}
+		// control sequences end with a byte from @ to ~, after any parameters
+		end := 2
+		if data[1] == '[' {
+			for end < len(data) && (data[end] < 0x40 || data[end] > 0x7E) {
+				end++
+			}
+		}
+		end = min(end+1, len(data))
+		if k, ok := sequences[string(data[:end])]; ok {
+			keys = append(keys, k)
+		}
+		data = data[end:]
+	}
+	return keys
+}
@@ -0,0 +1,209 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/swensone/gorito/debugger"
+	"github.com/swensone/gorito/disasm"
+	"github.com/swensone/gorito/emulator"
+	"github.com/swensone/gorito/types"
+)
+
+const (
+	DISASM_LINES = 18 // instructions shown around pc
+	DISASM_WIDTH = 40
+	MEMORY_ROWS  = 10 // rows of 16 bytes in the memory view
+	REGS_WIDTH   = 34
+	REFRESH      = time.Second / 15 // redraw rate while running
+)
+
+const help = "s step  n next  o out  c continue  p pause  b break  g run to  up/down cursor  pgup/pgdn memory  i memory at I  k keypad  q quit"
+
+// UI is a full screen terminal debugger. It drives the debugger from key presses, and shows the machine state
+// whenever execution is stopped. The emulator runs in its own goroutine.
+type UI struct {
+	emu     *emulator.Emulator
+	dbg     *debugger.Debugger
+	display *Display
+	input   *Input
+	disasm  *disasm.Disassembler
+	bg      types.Color
+
+	cursor    uint16 // address highlighted in the disassembly
+	memAddr   uint32 // first address in the memory view
+	memFollow bool   // move the memory view to I on every stop
+	keypad    bool   // send keys to the chip-8 keypad rather than the debugger
+	status    string
+}
+
+// New creates a ui for an emulator using display and input, with the debugger already attached. bg is the background
+// color, any other color is drawn as a set pixel.
+func New(emu *emulator.Emulator, dbg *debugger.Debugger, display *Display, input *Input, mode types.Mode, bg types.Color) *UI {
+	return &UI{
+		emu:       emu,
+		dbg:       dbg,
+		display:   display,
+		input:     input,
+		disasm:    disasm.New(mode),
+		bg:        bg,
+		memFollow: true,
+	}
+}
+
+// Run takes over the terminal until done delivers the result of the emulator's Run, which it returns
+func (u *UI) Run(done <-chan error) error {
+	t, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	keys := make(chan key, 16)
+	go readKeys(os.Stdin, keys)
+	ticker := time.NewTicker(REFRESH)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Draw(u.render()); err != nil {
+			return err
+		}
+
+		select {
+		case err := <-done:
+			return err
+		case k, ok := <-keys:
+			if !ok {
+				u.input.Quit()
+				keys = nil
+				continue
+			}
+			u.handleKey(k)
+		case s := <-u.dbg.Stops():
+			u.stopped(s)
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopped moves the views to the new stop
+func (u *UI) stopped(s debugger.Stop) {
+	u.cursor = s.PC
+	if u.memFollow {
+		u.memAddr = u.emu.Index() &^ 0xF
+	}
+
+	switch s.Reason {
+	case debugger.STOP_BREAKPOINT:
+		u.status = fmt.Sprintf("breakpoint %d at %04X", s.ID, s.PC)
+	case debugger.STOP_WATCHPOINT:
+		access := "read"
+		if s.Write {
+			access = "write"
+		}
+		u.status = fmt.Sprintf("watchpoint %d: %s of %04X", s.ID, access, s.Addr)
+	case debugger.STOP_CLASS:
+		u.status = fmt.Sprintf("%s instruction at %04X", s.Class.String(), s.PC)
+	case debugger.STOP_FAULT:
+		u.status = "fault: " + s.Fault.Error()
+	default:
+		u.status = fmt.Sprintf("%s at %04X", s.Reason.String(), s.PC)
+	}
+}
+
+func (u *UI) handleKey(k key) {
+	if u.keypad {
+		if k == keyEscape || k == 'k' {
+			u.keypad = false
+			return
+		}
+		u.input.press(rune(k))
+		return
+	}
+
+	var err error
+	_, stopped := u.dbg.Stopped()
+	switch k {
+	case 'q':
+		u.input.Quit()
+		u.dbg.Continue()
+	case 's':
+		err = u.dbg.StepInto()
+	case 'n':
+		err = u.dbg.StepOver()
+	case 'o':
+		err = u.dbg.StepOut()
+	case 'c':
+		u.status = "running"
+		u.dbg.Continue()
+	case 'p', ' ':
+		u.dbg.Pause()
+	case 'b':
+		if u.dbg.ToggleBreakpoint(u.cursor) {
+			u.status = fmt.Sprintf("breakpoint set at %04X", u.cursor)
+		} else {
+			u.status = fmt.Sprintf("breakpoint cleared at %04X", u.cursor)
+		}
+	case 'g':
+		u.status = fmt.Sprintf("running to %04X", u.cursor)
+		u.dbg.RunTo(u.cursor)
+	case keyUp:
+		u.cursor -= 2
+	case keyDown:
+		u.cursor += 2
+	case keyPageUp:
+		u.memFollow = false
+		u.memAddr -= MEMORY_ROWS * 16
+	case keyPageDown:
+		u.memFollow = false
+		u.memAddr += MEMORY_ROWS * 16
+	case 'i':
+		u.memFollow = true
+		if stopped {
+			u.memAddr = u.emu.Index() &^ 0xF
+		}
+	case 'k':
+		u.keypad = true
+	}
+	if err != nil {
+		u.status = err.Error()
+	}
+}
+
+// render draws the whole screen. The machine state is only read while the debugger has execution stopped, while
+// running only the screen is shown.
+func (u *UI) render() []string {
+	gfx, xres, yres := u.display.Frame()
+	screen := renderFramebuffer(gfx, xres, yres, u.bg)
+
+	var code, regs, mem []string
+	if _, ok := u.dbg.Stopped(); ok {
+		breakpoints := map[uint16]bool{}
+		for _, bp := range u.dbg.Breakpoints() {
+			breakpoints[bp.Addr] = true
+		}
+		start, n := disassemblyWindow(u.emu.PC(), DISASM_LINES)
+		code = renderDisassembly(u.disasm, u.emu.ReadMemory(start, n), u.emu.PC(), u.cursor, breakpoints, DISASM_LINES)
+
+		dt, st := u.emu.Timers()
+		regs = renderRegisters(u.emu.Registers(), u.emu.Index(), u.emu.PC(), u.emu.SP(), u.emu.Stack(),
+			u.emu.Platform().StackDepth, dt, st)
+		mem = renderMemory(u.emu.ReadMemory(u.memAddr, MEMORY_ROWS*16), u.memAddr)
+	} else {
+		code = []string{"running, p to pause"}
+	}
+
+	lines := []string{BOLD + "gorito debugger" + RESET}
+	lines = append(lines, columns([]int{DISASM_WIDTH, 0}, code, screen)...)
+	lines = append(lines, "")
+	lines = append(lines, columns([]int{REGS_WIDTH, 0}, regs, mem)...)
+	lines = append(lines, "")
+
+	status := u.status
+	if u.keypad {
+		status = "keypad: 1234 qwer asdf zxcv, esc to leave"
+	}
+	lines = append(lines, REVERSE+status+RESET, help)
+	return lines
+}
@@ -0,0 +1,96 @@
+package types
+
+import (
+	"github.com/cockroachdb/errors"
+)
+
+// FaultKind identifies the cause of a cpu fault
+type FaultKind int
+
+const (
+	FAULT_STACK_OVERFLOW  FaultKind = iota // 2NNN with the stack full
+	FAULT_STACK_UNDERFLOW                  // 00EE with the stack empty
+	FAULT_MEMORY_BOUNDS                    // an access past the end of the platform's memory, which wraps around
+	FAULT_UNKNOWN_OPCODE                   // an opcode the platform doesn't implement
+	FAULT_ROM_SIZE                         // a rom too large for the platform's memory, always returned from loading
+)
+
+var faultkindmap = map[FaultKind]string{
+	FAULT_STACK_OVERFLOW:  "stack_overflow",
+	FAULT_STACK_UNDERFLOW: "stack_underflow",
+	FAULT_MEMORY_BOUNDS:   "memory_bounds",
+	FAULT_UNKNOWN_OPCODE:  "unknown_opcode",
+	FAULT_ROM_SIZE:        "rom_size",
+}
+
+func (k FaultKind) String() string {
+	return faultkindmap[k]
+}
+
+func (k FaultKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *FaultKind) UnmarshalText(data []byte) error {
+	for kind, s := range faultkindmap {
+		if s == string(data) {
+			*k = kind
+			return nil
+		}
+	}
+	return errors.Errorf("unknown fault kind: %s", data)
+}
+
+// FaultPolicy selects what the emulator does when a fault is raised
+type FaultPolicy int
+
+const (
+	FAULT_HALT     FaultPolicy = iota // stop and return the fault from Step/Run
+	FAULT_CONTINUE                    // log the fault and carry on with the next instruction
+	FAULT_BREAK                       // log the fault and pause, breaking into the debugger if one is attached
+)
+
+var faultpolicymap = map[FaultPolicy]string{
+	FAULT_HALT:     "halt",
+	FAULT_CONTINUE: "continue",
+	FAULT_BREAK:    "break",
+}
+
+func (p FaultPolicy) String() string {
+	return faultpolicymap[p]
+}
+
+func (p FaultPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *FaultPolicy) UnmarshalText(data []byte) error {
+	for policy, s := range faultpolicymap {
+		if s == string(data) {
+			*p = policy
+			return nil
+		}
+	}
+	return errors.Errorf("unknown fault policy: %s", data)
+}
+
+// defaultFaultPolicies keep the behaviour from before faults were reported, broken stacks stop the program while bad
+// opcodes and memory accesses are only logged
+var defaultFaultPolicies = map[FaultKind]FaultPolicy{
+	FAULT_STACK_OVERFLOW:  FAULT_HALT,
+	FAULT_STACK_UNDERFLOW: FAULT_HALT,
+	FAULT_MEMORY_BOUNDS:   FAULT_CONTINUE,
+	FAULT_UNKNOWN_OPCODE:  FAULT_CONTINUE,
+	FAULT_ROM_SIZE:        FAULT_HALT,
+}
+
+// FaultPolicies holds the policies set in the config file or on the command line, keyed by fault kind
+type FaultPolicies map[FaultKind]FaultPolicy
+
+// Policy returns the policy for a fault kind, falling back to the default when it hasn't been set
+func (p FaultPolicies) Policy(k FaultKind) FaultPolicy {
+	if policy, ok := p[k]; ok {
+		return policy
+	}
+	return defaultFaultPolicies[k]
+}
@@ -12,12 +12,24 @@ const (
 	MODE_CHIP8 = iota
 	MODE_SUPERCHIP
 	MODE_XOCHIP
+	MODE_CHIP48
+	MODE_SCHIP10
+	MODE_SCHIP11
+	MODE_SCHPC
+	MODE_MEGACHIP
+	MODE_CHIP8X
 )
 
 var modemap = map[Mode]string{
 	MODE_CHIP8:     "chip-8",
 	MODE_SUPERCHIP: "superchip",
 	MODE_XOCHIP:    "xo-chip",
+	MODE_CHIP48:    "chip-48",
+	MODE_SCHIP10:   "schip-1.0",
+	MODE_SCHIP11:   "schip-1.1",
+	MODE_SCHPC:     "schpc",
+	MODE_MEGACHIP:  "megachip",
+	MODE_CHIP8X:    "chip-8x",
 }
 
 func ModeFromString(s string) (Mode, error) {
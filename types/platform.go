@@ -0,0 +1,174 @@
+package types
+
+// Font selects the shapes used for the built in 4x5 hex font
+type Font int
+
+const (
+	FONT_CHIP48 Font = iota // the font used by chip-48, superchip and most modern interpreters
+	FONT_VIP                // the font from the cosmac vip interpreter
+)
+
+// Platform describes the machine emulated by a mode
+type Platform struct {
+	Quirks     Quirks
+	StackDepth int  // number of nested subroutine calls
+	MemorySize int  // bytes of addressable memory
+	Hires      bool // supports the superchip 128x64 high resolution mode, the big font and the rpl flags
+	Scroll     bool // supports the superchip 1.1 scrolling instructions
+	XOChip     bool // supports the xo-chip instructions
+	Font       Font // shapes of the 4x5 hex font
+	Flags      int  // number of RPL user flags available to FX75/FX85
+	Planes     int  // number of bit planes, xo-chip draws in color using two
+	Megachip   bool // supports the megachip 256x192 indexed color mode
+	Chip8X     bool // supports the chip-8x color zones and second keypad
+	Start      int  // address programs are loaded and started at
+}
+
+var platformmap = map[Mode]Platform{
+	// the original cosmac vip interpreter
+	MODE_CHIP8: {
+		Quirks: Quirks{
+			ShiftUsesVY:    true,
+			MemIncrementI:  true,
+			VFReset:        true,
+			DisplayWait:    true,
+			KeyWaitRelease: true,
+		},
+		StackDepth: 12,
+		MemorySize: 4 * 1024,
+		Font:       FONT_VIP,
+		Planes:     1,
+		Start:      0x200,
+	},
+	// chip-48 on the hp48, shifts and jumps changed and I is incremented by one less on load and store
+	MODE_CHIP48: {
+		Quirks: Quirks{
+			MemIncrementI:   true,
+			MemIncrementByX: true,
+			JumpWithVX:      true,
+			KeyWaitRelease:  true,
+		},
+		StackDepth: 16,
+		MemorySize: 4 * 1024,
+		Planes:     1,
+		Start:      0x200,
+	},
+	// superchip 1.0 adds high resolution and the big font on top of chip-48
+	MODE_SCHIP10: {
+		Quirks: Quirks{
+			MemIncrementI:   true,
+			MemIncrementByX: true,
+			JumpWithVX:      true,
+			DisplayWait:     true,
+			Lores8x16:       true,
+			KeyWaitRelease:  true,
+			HalfPixelScroll: true,
+		},
+		StackDepth: 16,
+		MemorySize: 4 * 1024,
+		Hires:      true,
+		Flags:      8,
+		Planes:     1,
+		Start:      0x200,
+	},
+	// superchip 1.1 adds scrolling, stops incrementing I and counts collisions per row in high resolution
+	MODE_SCHIP11: {
+		Quirks: Quirks{
+			JumpWithVX:      true,
+			DisplayWait:     true,
+			Lores8x16:       true,
+			KeyWaitRelease:  true,
+			HalfPixelScroll: true,
+			CollisionRows:   true,
+		},
+		StackDepth: 16,
+		MemorySize: 4 * 1024,
+		Hires:      true,
+		Scroll:     true,
+		Flags:      8,
+		Planes:     1,
+		Start:      0x200,
+	},
+	// the modern schip compatible behaviour (schpc) used by newer superchip games
+	MODE_SCHPC: {
+		Quirks: Quirks{
+			JumpWithVX:     true,
+			Lores16x16:     true,
+			KeyWaitRelease: true,
+		},
+		StackDepth: 16,
+		MemorySize: 4 * 1024,
+		Hires:      true,
+		Scroll:     true,
+		Flags:      8,
+		Planes:     1,
+		Start:      0x200,
+	},
+	// generic modern superchip, matching what most superchip test suites expect
+	MODE_SUPERCHIP: {
+		Quirks: Quirks{
+			JumpWithVX:     true,
+			KeyWaitRelease: true,
+		},
+		StackDepth: 16,
+		MemorySize: 4 * 1024,
+		Hires:      true,
+		Scroll:     true,
+		Flags:      8,
+		Planes:     1,
+		Start:      0x200,
+	},
+	MODE_XOCHIP: {
+		Quirks: Quirks{
+			ShiftUsesVY:    true,
+			MemIncrementI:  true,
+			SpriteWrap:     true,
+			Lores16x16:     true,
+			KeyWaitRelease: true,
+		},
+		StackDepth: 16,
+		MemorySize: 64 * 1024,
+		Hires:      true,
+		Scroll:     true,
+		XOChip:     true,
+		Flags:      16,
+		Planes:     2,
+		Start:      0x200,
+	},
+	// chip-8x is the cosmac vip interpreter for the vp-590 color board, its larger interpreter moves programs to 0x300
+	MODE_CHIP8X: {
+		Quirks: Quirks{
+			ShiftUsesVY:    true,
+			MemIncrementI:  true,
+			VFReset:        true,
+			DisplayWait:    true,
+			KeyWaitRelease: true,
+		},
+		StackDepth: 12,
+		MemorySize: 4 * 1024,
+		Font:       FONT_VIP,
+		Planes:     1,
+		Chip8X:     true,
+		Start:      0x300,
+	},
+	// megachip extends superchip with a 256x192 indexed color display, sampled audio and 24 bit addressing
+	MODE_MEGACHIP: {
+		Quirks: Quirks{
+			JumpWithVX:     true,
+			KeyWaitRelease: true,
+		},
+		StackDepth: 16,
+		MemorySize: 16 * 1024 * 1024,
+		Hires:      true,
+		Scroll:     true,
+		Flags:      8,
+		Planes:     1,
+		Start:      0x200,
+		Megachip:   true,
+	},
+}
+
+// PlatformForMode returns the machine description for a mode
+func PlatformForMode(m Mode) Platform {
+	return platformmap[m]
+}
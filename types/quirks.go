@@ -0,0 +1,61 @@
+package types
+
+// Quirks are the behaviours that differ between chip-8 implementations
+type Quirks struct {
+	ShiftUsesVY     bool `json:"shift_vy"`          // 8XY6/8XYE shift VY into VX instead of shifting VX in place
+	MemIncrementI   bool `json:"mem_increment_i"`   // FX55/FX65 leave I pointing past the last register
+	MemIncrementByX bool `json:"mem_increment_x"`   // FX55/FX65 increment I by X rather than X+1
+	VFReset         bool `json:"vf_reset"`          // 8XY1/8XY2/8XY3 reset VF to 0
+	JumpWithVX      bool `json:"jump_vx"`           // BNNN works as BXNN, jumping to XNN plus VX
+	DisplayWait     bool `json:"display_wait"`      // DXYN waits for the vertical blank before drawing in low resolution
+	SpriteWrap      bool `json:"sprite_wrap"`       // sprites wrap around the screen edges instead of being clipped
+	Lores16x16      bool `json:"lores_16x16"`       // DXY0 draws a 16x16 sprite in low resolution mode
+	Lores8x16       bool `json:"lores_8x16"`        // DXY0 draws an 8x16 sprite in low resolution mode
+	KeyWaitRelease  bool `json:"key_wait_release"`  // FX0A completes when the key is released instead of pressed
+	HalfPixelScroll bool `json:"half_pixel_scroll"` // scrolling in low resolution moves by high resolution pixels
+	CollisionRows   bool `json:"collision_rows"`    // VF counts the colliding or clipped rows in high resolution
+}
+
+// QuirksForMode returns the default quirks for a mode
+func QuirksForMode(m Mode) Quirks {
+	return PlatformForMode(m).Quirks
+}
+
+// QuirkOverrides holds quirks set explicitly in the config file or on the command line, nil fields keep the value from
+// the mode's preset
+type QuirkOverrides struct {
+	ShiftUsesVY     *bool `json:"shift_vy,omitempty"`
+	MemIncrementI   *bool `json:"mem_increment_i,omitempty"`
+	MemIncrementByX *bool `json:"mem_increment_x,omitempty"`
+	VFReset         *bool `json:"vf_reset,omitempty"`
+	JumpWithVX      *bool `json:"jump_vx,omitempty"`
+	DisplayWait     *bool `json:"display_wait,omitempty"`
+	SpriteWrap      *bool `json:"sprite_wrap,omitempty"`
+	Lores16x16      *bool `json:"lores_16x16,omitempty"`
+	Lores8x16       *bool `json:"lores_8x16,omitempty"`
+	KeyWaitRelease  *bool `json:"key_wait_release,omitempty"`
+	HalfPixelScroll *bool `json:"half_pixel_scroll,omitempty"`
+	CollisionRows   *bool `json:"collision_rows,omitempty"`
+}
+
+// Apply returns q with any overridden quirks replaced
+func (o *QuirkOverrides) Apply(q Quirks) Quirks {
+	set := func(dst *bool, src *bool) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&q.ShiftUsesVY, o.ShiftUsesVY)
+	set(&q.MemIncrementI, o.MemIncrementI)
+	set(&q.MemIncrementByX, o.MemIncrementByX)
+	set(&q.VFReset, o.VFReset)
+	set(&q.JumpWithVX, o.JumpWithVX)
+	set(&q.DisplayWait, o.DisplayWait)
+	set(&q.SpriteWrap, o.SpriteWrap)
+	set(&q.Lores16x16, o.Lores16x16)
+	set(&q.Lores8x16, o.Lores8x16)
+	set(&q.KeyWaitRelease, o.KeyWaitRelease)
+	set(&q.HalfPixelScroll, o.HalfPixelScroll)
+	set(&q.CollisionRows, o.CollisionRows)
+	return q
+}